@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"nadleeh/pkg/common"
 	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/history"
+	"nadleeh/pkg/workflow/lint"
 	"nadleeh/pkg/workflow/runner"
+	"nadleeh/pkg/workflow/scheduler"
+	"nadleeh/pkg/workflow/schema"
+	"nadleeh/pkg/workflow/webhook"
 
 	log "github.com/sirupsen/logrus"
 
@@ -13,9 +19,11 @@ import (
 	"nadleeh/pkg/encrypt"
 
 	"os"
+	"os/signal"
 	"path"
 	"runtime"
 	"strconv"
+	"syscall"
 	"time"
 )
 
@@ -52,10 +60,33 @@ func setupLog() *os.File {
 		},
 	})
 	log.SetOutput(io.MultiWriter(logFile, os.Stdout))
+	// the run history records the log file of the run
+	common.MustSetEnv("NADLEEH_LOG_FILE", logFilePath)
 	log.SetLevel(log.InfoLevel)
 	return logFile
 }
 
+// notifyContext returns a context which is cancelled once nadleeh receives SIGINT or SIGTERM, the running step is
+// cancelled and the post steps still run. A second signal kills nadleeh right away.
+func notifyContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Warnf("received %v, cancel the workflow, send it again to exit right away", sig)
+			signal.Stop(signals)
+			cancel(fmt.Errorf("received %v", sig))
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
+
 func main() {
 	if logFile := setupLog(); logFile != nil {
 		defer logFile.Close()
@@ -68,14 +99,21 @@ func main() {
 				log.SetLevel(log.DebugLevel)
 			}
 			log.Debug("args: ", args.Args)
-			argEnv := argument.CreateArgsEnv(args.Args)
-			runner.RunWorkflow(core.NewWorkflowArgsFromRunArgs(args), argEnv)
+			argEnv, err := argument.CreateArgsEnv(args.Args)
+			if err != nil {
+				log.Fatal(err)
+			}
+			ctx, stop := notifyContext()
+			defer stop()
+			runner.RunWorkflow(ctx, core.NewWorkflowArgsFromRunArgs(args), argEnv)
 		},
 		WfHandler: func(args *argument.WorkflowArgs) {
 			if argument.Verbose {
 				log.SetLevel(log.DebugLevel)
 			}
-			runner.RunWorkflowConfig(args)
+			ctx, stop := notifyContext()
+			defer stop()
+			runner.RunWorkflowConfig(ctx, args)
 		},
 		KeypairHandler: func(args *argument.KeypairArgs) {
 			if argument.Verbose {
@@ -89,6 +127,40 @@ func main() {
 			}
 			encrypt.Encrypt(args)
 		},
+		RunsHandler: func(args *argument.RunsArgs) {
+			if argument.Verbose {
+				log.SetLevel(log.DebugLevel)
+			}
+			history.HandleRuns(args)
+		},
+		SchedulerHandler: func(args *argument.SchedulerArgs) {
+			if argument.Verbose {
+				log.SetLevel(log.DebugLevel)
+			}
+			ctx, stop := notifyContext()
+			defer stop()
+			scheduler.HandleScheduler(ctx, args)
+		},
+		ServeHandler: func(args *argument.ServeArgs) {
+			if argument.Verbose {
+				log.SetLevel(log.DebugLevel)
+			}
+			ctx, stop := notifyContext()
+			defer stop()
+			webhook.HandleServe(ctx, args)
+		},
+		ValidateHandler: func(args *argument.ValidateArgs) {
+			if argument.Verbose {
+				log.SetLevel(log.DebugLevel)
+			}
+			schema.HandleValidate(args)
+		},
+		LintHandler: func(args *argument.LintArgs) {
+			if argument.Verbose {
+				log.SetLevel(log.DebugLevel)
+			}
+			lint.HandleLint(args)
+		},
 	}
 
 	rootCmd := argument.NewNadleehCliParser(handlers)
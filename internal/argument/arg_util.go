@@ -1,14 +1,50 @@
 package argument
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 
 	"github.com/zhaojunlucky/golib/pkg/env"
 )
 
-// CreateArgsEnv creates an env.Env from a slice of argument strings in the format "key=value"
-func CreateArgsEnv(args []string) env.Env {
+// reportFormats are the formats of --report
+var reportFormats = []string{"json", "junit"}
+
+// validateReports validates the reports in the format "format=path"
+func validateReports(reports []string) error {
+	for _, report := range reports {
+		format, path, found := strings.Cut(report, "=")
+		if !found || len(strings.TrimSpace(path)) == 0 || !slices.Contains(reportFormats, strings.ToLower(strings.TrimSpace(format))) {
+			return fmt.Errorf("invalid report %s, it must be json=path or junit=path", report)
+		}
+	}
+	return nil
+}
+
+// ArgsEnv is the env which gives the args as a json object, e.g. the webhook server passes the args of the request
+// by it since the command line of a process can be read by any user
+const ArgsEnv = "NADLEEH_ARGS"
+
+// EncodeArgsEnv returns the ArgsEnv entry of the args for the env of a nadleeh process
+func EncodeArgsEnv(args map[string]string) string {
+	// a map of strings always marshals
+	data, _ := json.Marshal(args)
+	return fmt.Sprintf("%s=%s", ArgsEnv, data)
+}
+
+// CreateArgsEnv creates an env.Env from the args given by ArgsEnv and a slice of argument strings in the format
+// "key=value", the argument strings override the args of ArgsEnv. ArgsEnv is unset, so the steps don't inherit it.
+func CreateArgsEnv(args []string) (env.Env, error) {
+	envArgs := make(map[string]string)
+	if value, ok := os.LookupEnv(ArgsEnv); ok {
+		_ = os.Unsetenv(ArgsEnv)
+		if err := json.Unmarshal([]byte(value), &envArgs); err != nil {
+			return nil, fmt.Errorf("invalid args of env %s: %w", ArgsEnv, err)
+		}
+	}
 	argMap := make(map[string]string)
 	for _, argLine := range args {
 		key, value, found := strings.Cut(argLine, "=")
@@ -24,5 +60,10 @@ func CreateArgsEnv(args []string) env.Env {
 			argMap[key] = value
 		}
 	}
-	return env.NewReadEnv(env.NewEmptyReadEnv(), argMap)
+	for key, value := range envArgs {
+		if _, ok := argMap[key]; !ok {
+			argMap[key] = value
+		}
+	}
+	return env.NewReadEnv(env.NewEmptyReadEnv(), argMap), nil
 }
@@ -0,0 +1,28 @@
+package argument
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func addLintCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
+	lintArgs := &LintArgs{}
+
+	lintCmd := &cobra.Command{
+		Use:   "lint <workflow-file>...",
+		Short: "Compile the scripts and expressions of the workflow files and report the problems",
+		Long: `Compile the bash and javascript of the steps and every ${{ }} expression of the workflow files without
+running them. Lint reports the references of undefined args, envs and step ids, the args of checks.args which
+are never used and the plaintext values which look like secrets.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			lintArgs.Files = args
+			if handlers != nil && handlers.LintHandler != nil {
+				handlers.LintHandler(lintArgs)
+			}
+		},
+	}
+
+	lintCmd.Flags().BoolVar(&lintArgs.Strict, "strict", false, "Fail on warnings as well")
+
+	rootCmd.AddCommand(lintCmd)
+}
@@ -1,6 +1,8 @@
 package argument
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
@@ -9,15 +11,19 @@ type RunArgs struct {
 	File        string
 	Provider    string
 	Check       bool
+	Plan        bool
 	Usage       bool
 	Args        []string
 	PrivateFile string
+	Reports     []string
+	Resume      string
 }
 
 // WorkflowArgs holds arguments for the wf command
 type WorkflowArgs struct {
 	ConfigFile string
 	Args       []string
+	Reports    []string
 }
 
 // KeypairArgs holds arguments for the keypair command
@@ -33,12 +39,57 @@ type EncryptArgs struct {
 	Str    string
 }
 
+// RunsArgs holds arguments for the runs commands
+type RunsArgs struct {
+	Command   string
+	RunId     string
+	Limit     int
+	Json      bool
+	Step      string
+	Keep      int
+	OlderThan time.Duration
+}
+
+// SchedulerArgs holds arguments for the scheduler command
+type SchedulerArgs struct {
+	Dir string
+	// PrivateFile is passed to the scheduled workflow files, a wf config file has its own
+	PrivateFile string
+	List        bool
+}
+
+// ServeArgs holds arguments for the serve command
+type ServeArgs struct {
+	Dir        string
+	Addr       string
+	SecretFile string
+	TokenFile  string
+}
+
+// ValidateArgs holds arguments for the validate command
+type ValidateArgs struct {
+	Files       []string
+	PrintSchema bool
+}
+
+// LintArgs holds arguments for the lint command
+type LintArgs struct {
+	Files []string
+	// Strict fails the lint on warnings as well
+	Strict bool
+}
+
 // CommandHandlers holds the handler functions for each command
 type CommandHandlers struct {
-	RunHandler     func(args *RunArgs)
-	WfHandler      func(args *WorkflowArgs)
-	KeypairHandler func(args *KeypairArgs)
-	EncryptHandler func(args *EncryptArgs)
+	RunHandler       func(args *RunArgs)
+	WfHandler        func(args *WorkflowArgs)
+	KeypairHandler   func(args *KeypairArgs)
+	EncryptHandler   func(args *EncryptArgs)
+	RunsHandler      func(args *RunsArgs)
+	SchedulerHandler func(args *SchedulerArgs)
+	ServeHandler     func(args *ServeArgs)
+	ValidateHandler  func(args *ValidateArgs)
+	LintHandler      func(args *LintArgs)
 }
 
 // Verbose is a global flag for verbose logging
@@ -59,6 +110,11 @@ func NewNadleehCliParser(handlers *CommandHandlers) *cobra.Command {
 	addEncryptCmd(rootCmd, handlers)
 	addKeypairCmd(rootCmd, handlers)
 	addWfCmd(rootCmd, handlers)
+	addRunsCmd(rootCmd, handlers)
+	addSchedulerCmd(rootCmd, handlers)
+	addServeCmd(rootCmd, handlers)
+	addValidateCmd(rootCmd, handlers)
+	addLintCmd(rootCmd, handlers)
 
 	return rootCmd
 }
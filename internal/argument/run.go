@@ -17,12 +17,18 @@ func addRunCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
 		Use:   "run",
 		Short: "Run the given workflow file",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(runArgs.File) == 0 && len(runArgs.Resume) == 0 {
+				return fmt.Errorf("required flag \"file\" not set, it's optional only with --resume")
+			}
 			// Validate args
 			for _, arg := range runArgs.Args {
 				if !re.MatchString(arg) {
 					return fmt.Errorf("invalid argument %s", arg)
 				}
 			}
+			if err := validateReports(runArgs.Reports); err != nil {
+				return err
+			}
 			// Validate private file if provided
 			if runArgs.PrivateFile != "" {
 				fi, err := os.Stat(runArgs.PrivateFile)
@@ -45,11 +51,14 @@ func addRunCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
 	runCmd.Flags().StringVarP(&runArgs.File, "file", "f", "", "Run the workflow file")
 	runCmd.Flags().StringVarP(&runArgs.Provider, "provider", "p", "", "The workflow provider (e.g., github)")
 	runCmd.Flags().BoolVarP(&runArgs.Check, "check", "c", false, "Only check the workflow")
+	runCmd.Flags().BoolVar(&runArgs.Plan, "plan", false, "Only show the jobs and steps which would run, no step is run")
 	runCmd.Flags().BoolVar(&runArgs.Usage, "usage", false, "Show usage")
 	runCmd.Flags().StringArrayVarP(&runArgs.Args, "arg", "a", nil, "Arguments variables")
 	runCmd.Flags().StringVar(&runArgs.PrivateFile, "private", "", "Private key file to decrypt the encrypted data")
+	runCmd.Flags().StringArrayVar(&runArgs.Reports, "report", nil, "Write the run report, json=path or junit=path")
+	runCmd.Flags().StringVar(&runArgs.Resume, "resume", "", "Resume the failed run of the id, the passed steps are not run again")
 
-	_ = runCmd.MarkFlagRequired("file")
+	runCmd.MarkFlagsMutuallyExclusive("check", "plan", "resume")
 
 	runCmd.Flags().Lookup("provider").NoOptDefVal = "github"
 
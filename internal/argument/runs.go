@@ -0,0 +1,78 @@
+package argument
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	RunsList  = "list"
+	RunsShow  = "show"
+	RunsLogs  = "logs"
+	RunsPrune = "prune"
+)
+
+func addRunsCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
+	runsArgs := &RunsArgs{}
+
+	handle := func(command string) func(cmd *cobra.Command, args []string) {
+		return func(cmd *cobra.Command, args []string) {
+			runsArgs.Command = command
+			if len(args) > 0 {
+				runsArgs.RunId = args[0]
+			}
+			if handlers != nil && handlers.RunsHandler != nil {
+				handlers.RunsHandler(runsArgs)
+			}
+		}
+	}
+
+	runsCmd := &cobra.Command{
+		Use:   "runs",
+		Short: "Inspect the workflow run history",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the workflow runs, the latest run first",
+		Args:  cobra.NoArgs,
+		Run:   handle(RunsList),
+	}
+	listCmd.Flags().IntVarP(&runsArgs.Limit, "limit", "n", 20, "The max number of runs to list, 0 lists all runs")
+
+	showCmd := &cobra.Command{
+		Use:   "show <run-id>",
+		Short: "Show the status of a workflow run",
+		Args:  cobra.ExactArgs(1),
+		Run:   handle(RunsShow),
+	}
+	showCmd.Flags().BoolVar(&runsArgs.Json, "json", false, "Show the run record as json")
+
+	logsCmd := &cobra.Command{
+		Use:   "logs <run-id>",
+		Short: "Show the step logs of a workflow run",
+		Args:  cobra.ExactArgs(1),
+		Run:   handle(RunsLogs),
+	}
+	logsCmd.Flags().StringVar(&runsArgs.Step, "step", "", "Only show the logs of the steps whose name contains the value")
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the old workflow runs",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if runsArgs.Keep < 0 && runsArgs.OlderThan <= 0 {
+				return fmt.Errorf("either --keep or --older-than is required")
+			}
+			return nil
+		},
+		Run: handle(RunsPrune),
+	}
+	pruneCmd.Flags().IntVar(&runsArgs.Keep, "keep", -1, "Keep the latest runs")
+	pruneCmd.Flags().DurationVar(&runsArgs.OlderThan, "older-than", time.Duration(0), "Remove the runs older than the duration, e.g. 720h")
+
+	runsCmd.AddCommand(listCmd, showCmd, logsCmd, pruneCmd)
+	rootCmd.AddCommand(runsCmd)
+}
@@ -0,0 +1,47 @@
+package argument
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func addSchedulerCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
+	schedulerArgs := &SchedulerArgs{}
+
+	schedulerCmd := &cobra.Command{
+		Use:   "scheduler <dir>",
+		Short: "Run the workflows and wf config files of the directory at their on.schedule cron and on.watch changes",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			fi, err := os.Stat(args[0])
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() {
+				return fmt.Errorf("%s must be a directory", args[0])
+			}
+			if schedulerArgs.PrivateFile != "" {
+				if fi, err = os.Stat(schedulerArgs.PrivateFile); err != nil {
+					return err
+				}
+				if fi.IsDir() {
+					return fmt.Errorf("private file must be a valid file")
+				}
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			schedulerArgs.Dir = args[0]
+			if handlers != nil && handlers.SchedulerHandler != nil {
+				handlers.SchedulerHandler(schedulerArgs)
+			}
+		},
+	}
+
+	schedulerCmd.Flags().StringVar(&schedulerArgs.PrivateFile, "private", "", "Private key file passed to the scheduled workflows")
+	schedulerCmd.Flags().BoolVar(&schedulerArgs.List, "list", false, "Only list the scheduled workflows and their next run")
+
+	rootCmd.AddCommand(schedulerCmd)
+}
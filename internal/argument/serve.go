@@ -0,0 +1,60 @@
+package argument
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func addServeCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
+	serveArgs := &ServeArgs{}
+
+	serveCmd := &cobra.Command{
+		Use:   "serve <dir>",
+		Short: "Serve webhooks which run the wf config files of the directory",
+		Long: "Serve webhooks which run the wf config files of the directory. POST /hooks/<name> runs <name>.yml with " +
+			"the args of the json body {\"args\": {...}} and returns the run id, GET /runs/<id> returns the run. The " +
+			"POST requests are verified by the X-Hub-Signature-256 HMAC of the body or the bearer token. A signature " +
+			"is replayable unless the request has the X-Nadleeh-Timestamp header of the unix seconds, then the HMAC " +
+			"is of <timestamp>.<body>, it's accepted once within 5 minutes of the timestamp. GET requests are verified " +
+			"by the bearer token, or by the HMAC of the empty body if there's no token.",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			fi, err := os.Stat(args[0])
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() {
+				return fmt.Errorf("%s must be a directory", args[0])
+			}
+			if serveArgs.SecretFile == "" && serveArgs.TokenFile == "" {
+				return fmt.Errorf("secret file or token file is required to verify the requests")
+			}
+			for _, file := range []string{serveArgs.SecretFile, serveArgs.TokenFile} {
+				if file == "" {
+					continue
+				}
+				if fi, err = os.Stat(file); err != nil {
+					return err
+				}
+				if fi.IsDir() {
+					return fmt.Errorf("%s must be a valid file", file)
+				}
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			serveArgs.Dir = args[0]
+			if handlers != nil && handlers.ServeHandler != nil {
+				handlers.ServeHandler(serveArgs)
+			}
+		},
+	}
+
+	serveCmd.Flags().StringVar(&serveArgs.Addr, "addr", ":8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveArgs.SecretFile, "secret-file", "", "File of the HMAC secret which verifies the X-Hub-Signature-256 header")
+	serveCmd.Flags().StringVar(&serveArgs.TokenFile, "token-file", "", "File of the bearer token which verifies the Authorization header")
+
+	rootCmd.AddCommand(serveCmd)
+}
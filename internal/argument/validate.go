@@ -0,0 +1,32 @@
+package argument
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func addValidateCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
+	validateArgs := &ValidateArgs{}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate <workflow-file>...",
+		Short: "Validate the workflow files against the workflow JSON Schema",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !validateArgs.PrintSchema {
+				return fmt.Errorf("requires at least 1 workflow file")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			validateArgs.Files = args
+			if handlers != nil && handlers.ValidateHandler != nil {
+				handlers.ValidateHandler(validateArgs)
+			}
+		},
+	}
+
+	validateCmd.Flags().BoolVar(&validateArgs.PrintSchema, "schema", false, "Print the workflow JSON Schema")
+
+	rootCmd.AddCommand(validateCmd)
+}
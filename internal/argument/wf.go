@@ -22,7 +22,7 @@ func addWfCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
 					return fmt.Errorf("invalid argument %s", arg)
 				}
 			}
-			return nil
+			return validateReports(wfArgs.Reports)
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			wfArgs.ConfigFile = args[0]
@@ -33,6 +33,7 @@ func addWfCmd(rootCmd *cobra.Command, handlers *CommandHandlers) {
 	}
 
 	wfCmd.Flags().StringArrayVarP(&wfArgs.Args, "arg", "a", nil, "Arguments variables")
+	wfCmd.Flags().StringArrayVar(&wfArgs.Reports, "report", nil, "Write the run report, json=path or junit=path")
 
 	rootCmd.AddCommand(wfCmd)
 }
@@ -0,0 +1,30 @@
+package common
+
+import "regexp"
+
+// MaskedValue replaces the values of the secrets
+const MaskedValue = "***"
+
+// secretNamePattern matches the names of the args and envs which hold secrets
+var secretNamePattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private|key)`)
+
+// IsSecretName returns whether the arg or env of the name holds a secret
+func IsSecretName(name string) bool {
+	return secretNamePattern.MatchString(name)
+}
+
+// MaskSecrets returns a copy of the values with the values of the secret names masked
+func MaskSecrets(values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	masked := make(map[string]string, len(values))
+	for key, value := range values {
+		if IsSecretName(key) {
+			masked[key] = MaskedValue
+		} else {
+			masked[key] = value
+		}
+	}
+	return masked
+}
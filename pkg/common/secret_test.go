@@ -0,0 +1,16 @@
+package common
+
+import "testing"
+
+func TestMaskSecrets(t *testing.T) {
+	masked := MaskSecrets(map[string]string{"db": "postgres", "api_token": "abc", "DB_PASSWORD": "secret", "ssh-key": "key"})
+	expected := map[string]string{"db": "postgres", "api_token": MaskedValue, "DB_PASSWORD": MaskedValue, "ssh-key": MaskedValue}
+	for key, value := range expected {
+		if masked[key] != value {
+			t.Errorf("Expected %s=%s, got %s", key, value, masked[key])
+		}
+	}
+	if MaskSecrets(nil) != nil {
+		t.Error("Expected nil for no values")
+	}
+}
@@ -0,0 +1,194 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxYears is how far Next looks for a matching time, an expression like 0 0 30 2 * never matches
+const maxYears = 5
+
+// macros are the named expressions which are expanded before parsing
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// field is the definition of a cron field, names are the names of the values starting at min
+type field struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var (
+	minuteField  = field{name: "minute", min: 0, max: 59}
+	hourField    = field{name: "hour", min: 0, max: 23}
+	dayField     = field{name: "day of month", min: 1, max: 31}
+	monthField   = field{name: "month", min: 1, max: 12, names: monthNames}
+	weekdayField = field{name: "day of week", min: 0, max: 7, names: weekdayNames}
+)
+
+// Schedule is a parsed cron expression with the fields minute, hour, day of month, month and day of week. Each
+// field is a bit set of the matching values.
+type Schedule struct {
+	expr    string
+	minute  uint64
+	hour    uint64
+	day     uint64
+	month   uint64
+	weekday uint64
+	// dayAny and weekdayAny are whether the day fields match all the days, e.g. *, */1, ? or 0-6, the day matches
+	// either field if both are restricted
+	dayAny     bool
+	weekdayAny bool
+}
+
+// Parse parses a standard 5 fields cron expression like "0 3 * * 1-5". The fields support * or ?, lists, ranges, steps
+// and the names of months and days of week, the day of week 7 is Sunday as well. The macros like @daily are
+// supported too.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := macros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron %q, it must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+	s := &Schedule{expr: expr}
+	var err error
+	for i, target := range []*uint64{&s.minute, &s.hour, &s.day, &s.month, &s.weekday} {
+		def := []field{minuteField, hourField, dayField, monthField, weekdayField}[i]
+		if *target, err = def.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid cron %q: %w", expr, err)
+		}
+	}
+	// Sunday is either 0 or 7
+	if s.weekday&(1<<7) != 0 {
+		s.weekday |= 1
+	}
+	s.dayAny = s.day == dayField.all()
+	s.weekdayAny = s.weekday|1<<7 == weekdayField.all()
+	return s, nil
+}
+
+func (f field) parse(value string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		itemBits, err := f.parseItem(item)
+		if err != nil {
+			return 0, err
+		}
+		bits |= itemBits
+	}
+	return bits, nil
+}
+
+// all returns the bit set of all the values of the field
+func (f field) all() uint64 {
+	return 1<<(f.max+1) - 1<<f.min
+}
+
+// parseItem parses *, a value or a range with an optional step, e.g. */15, 5, 1-5 or 10/5 which is 10-max/5. ? is
+// the same as *.
+func (f field) parseItem(item string) (uint64, error) {
+	rangeExpr, stepExpr, hasStep := strings.Cut(item, "/")
+	step := 1
+	if hasStep {
+		var err error
+		if step, err = strconv.Atoi(stepExpr); err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid %s step %q", f.name, stepExpr)
+		}
+	}
+	start, end := f.min, f.max
+	if rangeExpr != "*" && rangeExpr != "?" {
+		low, high, isRange := strings.Cut(rangeExpr, "-")
+		var err error
+		if start, err = f.parseValue(low); err != nil {
+			return 0, err
+		}
+		switch {
+		case isRange:
+			if end, err = f.parseValue(high); err != nil {
+				return 0, err
+			}
+		case !hasStep:
+			end = start
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid %s range %q", f.name, rangeExpr)
+		}
+	}
+	var bits uint64
+	for value := start; value <= end; value += step {
+		bits |= 1 << value
+	}
+	return bits, nil
+}
+
+func (f field) parseValue(value string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(value, name) {
+			return f.min + i, nil
+		}
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < f.min || number > f.max {
+		return 0, fmt.Errorf("invalid %s %q, it must be %d-%d", f.name, value, f.min, f.max)
+	}
+	return number, nil
+}
+
+// Next returns the first time after t which matches the schedule in the location of t, it's zero if no time matches
+// in the next years
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxYears, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches returns whether the day of t matches, like cron the day matches either day field if both are restricted
+func (s *Schedule) dayMatches(t time.Time) bool {
+	day := has(s.day, t.Day())
+	weekday := has(s.weekday, int(t.Weekday()))
+	if s.dayAny || s.weekdayAny {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<value) != 0
+}
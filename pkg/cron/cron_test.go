@@ -0,0 +1,110 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		for _, expr := range []string{
+			"0 3 * * *", "*/15 * * * *", "0 0-23/2 * * *", "30 4 1,15 * mon-fri", "0 12 * jan,jul sun",
+			"5/10 * * * 7", "@daily", "@Hourly", "0 0 ? * mon",
+		} {
+			if _, err := Parse(expr); err != nil {
+				t.Errorf("Unexpected error of %q: %v", expr, err)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for expr, expected := range map[string]string{
+			"0 3 * *":       "5 fields",
+			"60 * * * *":    "invalid minute \"60\"",
+			"0 24 * * *":    "invalid hour",
+			"0 0 0 * *":     "invalid day of month",
+			"0 0 * 13 *":    "invalid month",
+			"0 0 * * 8":     "invalid day of week",
+			"*/0 * * * *":   "invalid minute step",
+			"0 5-1 * * *":   "invalid hour range",
+			"0 0 * foo *":   "invalid month \"foo\"",
+			"@every 5m":     "5 fields",
+			"0 0 * * mon-x": "invalid day of week",
+		} {
+			_, err := Parse(expr)
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("Expected error %q of %q, got %v", expected, expr, err)
+			}
+		}
+	})
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// 2024-01-31 is a Wednesday
+	from := time.Date(2024, 1, 31, 10, 30, 15, 0, time.UTC)
+	for expr, expected := range map[string]string{
+		"0 3 * * *":       "2024-02-01 03:00",
+		"*/15 * * * *":    "2024-01-31 10:45",
+		"31 10 * * *":     "2024-01-31 10:31",
+		"30 10 * * *":     "2024-02-01 10:30",
+		"0 0 1 * *":       "2024-02-01 00:00",
+		"0 9 * * mon":     "2024-02-05 09:00",
+		"0 9 * * 7":       "2024-02-04 09:00",
+		"0 0 29 2 *":      "2024-02-29 00:00",
+		"0 0 31 * *":      "2024-03-31 00:00",
+		"0 0 1 * fri":     "2024-02-01 00:00",
+		"0 0 15 * wed":    "2024-02-07 00:00",
+		"@yearly":         "2025-01-01 00:00",
+		"0 12 * jul *":    "2024-07-01 12:00",
+		"10/20 8-9 * * *": "2024-02-01 08:10",
+		"0 0 */1 * mon":   "2024-02-05 00:00",
+		"0 0 ? * mon":     "2024-02-05 00:00",
+		"0 0 1-31 * mon":  "2024-02-05 00:00",
+		"0 0 15 * 0-6":    "2024-02-15 00:00",
+		"0 0 15 * */1":    "2024-02-15 00:00",
+		"0 0 15 * 1-7":    "2024-02-15 00:00",
+		"0 0 15 * ?":      "2024-02-15 00:00",
+	} {
+		schedule, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Unexpected error of %q: %v", expr, err)
+		}
+		if next := schedule.Next(from).Format("2006-01-02 15:04"); next != expected {
+			t.Errorf("Expected next of %q to be %s, got %s", expr, expected, next)
+		}
+	}
+
+	t.Run("AnyDay", func(t *testing.T) {
+		for expr, expected := range map[string][2]bool{
+			"0 0 * * *":      {true, true},
+			"0 0 */1 * 1":    {true, false},
+			"0 0 ? * 1":      {true, false},
+			"0 0 1 * 0-6":    {false, true},
+			"0 0 1 * */1":    {false, true},
+			"0 0 1 * 1-7":    {false, true},
+			"0 0 1-30 * 1-6": {false, false},
+		} {
+			schedule, _ := Parse(expr)
+			if actual := [2]bool{schedule.dayAny, schedule.weekdayAny}; actual != expected {
+				t.Errorf("Expected day and weekday any of %q to be %v, got %v", expr, expected, actual)
+			}
+		}
+	})
+
+	t.Run("NeverMatches", func(t *testing.T) {
+		schedule, _ := Parse("0 0 30 2 *")
+		if next := schedule.Next(from); !next.IsZero() {
+			t.Errorf("Expected no next time, got %v", next)
+		}
+	})
+
+	t.Run("Location", func(t *testing.T) {
+		loc := time.FixedZone("UTC+8", 8*3600)
+		schedule, _ := Parse("0 3 * * *")
+		next := schedule.Next(from.In(loc))
+		if next.Location() != loc || next.Format("2006-01-02 15:04") != "2024-02-01 03:00" {
+			t.Errorf("Expected next in the location of the time, got %v", next)
+		}
+	})
+}
@@ -1,41 +1,127 @@
 package file
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// lockPollInterval is how often LockContext tries to acquire a lock which is held by another process
+const lockPollInterval = 200 * time.Millisecond
+
 type FileLock struct {
 	lockFile string
 	fd       *os.File
+	// owned is whether the owner is written to the lock file, it's cleared once the lock is released
+	owned bool
 }
 
 func (l *FileLock) Lock() error {
-	err := os.MkdirAll(filepath.Dir(l.lockFile), os.ModePerm)
-	if err != nil {
-		log.Errorf("failed to create lock file dir %s", l.lockFile)
+	if err := l.open(); err != nil {
 		return err
 	}
-	lockFile, err := os.Create(l.lockFile)
+	err := syscall.Flock(int(l.fd.Fd()), syscall.LOCK_EX)
 	if err != nil {
-		log.Errorf("failed to create lock file: %v", err)
+		l.close()
+		log.Errorf("failed to acquire lock: %v", err)
 		return err
 	}
-	l.fd = lockFile
-	err = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX)
+	return nil
+}
+
+// TryLock acquires the lock without blocking, it returns false if the lock is held by another process
+func (l *FileLock) TryLock() (bool, error) {
+	if err := l.open(); err != nil {
+		return false, err
+	}
+	err := syscall.Flock(int(l.fd.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
 	if err != nil {
 		l.close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
 		log.Errorf("failed to acquire lock: %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// LockContext acquires the lock, it waits until the lock is released by another process or ctx is done
+func (l *FileLock) LockContext(ctx context.Context) error {
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	for {
+		locked, err := l.TryLock()
+		if err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to acquire lock %s: %w", l.lockFile, context.Cause(ctx))
+		case <-ticker.C:
+		}
+	}
+}
+
+// LockTimeout acquires the lock, it waits at most timeout until the lock is released by another process
+func (l *FileLock) LockTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return l.LockContext(ctx)
+}
+
+// WriteOwner writes the owner of the acquired lock to the lock file, e.g. the pid of the process. The other processes
+// read it by ReadOwner while the lock is held, it's cleared once the lock is released.
+func (l *FileLock) WriteOwner(owner string) error {
+	if l.fd == nil {
+		return fmt.Errorf("lock %s isn't acquired", l.lockFile)
+	}
+	if err := l.fd.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.fd.WriteAt([]byte(owner), 0); err != nil {
 		return err
 	}
+	l.owned = true
 	return nil
 }
 
+// ReadOwner returns the owner written to the lock file, it's empty if the lock is released or the owner isn't written
+func (l *FileLock) ReadOwner() (string, error) {
+	lockFile, err := os.Open(l.lockFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer lockFile.Close()
+	data, err := io.ReadAll(lockFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
 func (l *FileLock) Unlock() error {
 	if l.fd != nil {
+		if l.owned {
+			if err := l.fd.Truncate(0); err != nil {
+				log.Warnf("failed to clear owner of lock %s: %v", l.lockFile, err)
+			}
+			l.owned = false
+		}
 		err := syscall.Flock(int(l.fd.Fd()), syscall.LOCK_UN)
 		if err != nil {
 			log.Errorf("failed to release lock: %v", err)
@@ -46,6 +132,22 @@ func (l *FileLock) Unlock() error {
 	return nil
 }
 
+// open opens the lock file, the content isn't truncated since it's the owner of the lock held by another process
+func (l *FileLock) open() error {
+	err := os.MkdirAll(filepath.Dir(l.lockFile), os.ModePerm)
+	if err != nil {
+		log.Errorf("failed to create lock file dir %s", l.lockFile)
+		return err
+	}
+	lockFile, err := os.OpenFile(l.lockFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		log.Errorf("failed to create lock file: %v", err)
+		return err
+	}
+	l.fd = lockFile
+	return nil
+}
+
 func (l *FileLock) close() error {
 	if l.fd != nil {
 		err := l.fd.Close()
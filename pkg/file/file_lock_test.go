@@ -289,6 +289,81 @@ func TestFileLock_ConcurrentAccess(t *testing.T) {
 	os.Remove(lockFile)
 }
 
+func TestFileLock_TryLock(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "try.lock")
+	fl1 := NewFileLock(lockFile)
+	fl2 := NewFileLock(lockFile)
+
+	locked, err := fl1.TryLock()
+	if err != nil || !locked {
+		t.Fatalf("Expected TryLock() to acquire the free lock, got %v, %v", locked, err)
+	}
+	locked, err = fl2.TryLock()
+	if err != nil || locked {
+		t.Fatalf("Expected TryLock() not to acquire the held lock, got %v, %v", locked, err)
+	}
+	if fl2.fd != nil {
+		t.Error("Expected fd to be closed when the lock isn't acquired")
+	}
+
+	fl1.Unlock()
+	locked, err = fl2.TryLock()
+	if err != nil || !locked {
+		t.Fatalf("Expected TryLock() to acquire the released lock, got %v, %v", locked, err)
+	}
+	fl2.Unlock()
+}
+
+func TestFileLock_LockTimeout(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "timeout.lock")
+	fl1 := NewFileLock(lockFile)
+	if err := fl1.Lock(); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	fl2 := NewFileLock(lockFile)
+	start := time.Now()
+	if err := fl2.LockTimeout(300 * time.Millisecond); err == nil {
+		t.Fatal("Expected LockTimeout() to fail while the lock is held")
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Expected LockTimeout() to wait for the timeout, waited %v", elapsed)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { fl1.Unlock() })
+	if err := fl2.LockTimeout(5 * time.Second); err != nil {
+		t.Fatalf("Expected LockTimeout() to acquire the released lock, got %v", err)
+	}
+	fl2.Unlock()
+}
+
+func TestFileLock_Owner(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "owner.lock")
+	fl1 := NewFileLock(lockFile)
+	if err := fl1.WriteOwner("1"); err == nil {
+		t.Error("Expected WriteOwner() to fail before the lock is acquired")
+	}
+	if err := fl1.Lock(); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if err := fl1.WriteOwner("1234"); err != nil {
+		t.Fatalf("Unexpected WriteOwner() error: %v", err)
+	}
+
+	fl2 := NewFileLock(lockFile)
+	if locked, _ := fl2.TryLock(); locked {
+		t.Fatal("Expected the lock to be held")
+	}
+	if owner, err := fl2.ReadOwner(); err != nil || owner != "1234" {
+		t.Errorf("Expected owner 1234 to be kept by TryLock(), got %q, %v", owner, err)
+	}
+
+	fl1.Unlock()
+	if owner, err := fl2.ReadOwner(); err != nil || owner != "" {
+		t.Errorf("Expected owner to be cleared after Unlock(), got %q, %v", owner, err)
+	}
+}
+
 func TestFileLock_EdgeCases(t *testing.T) {
 	t.Run("EmptyLockFile", func(t *testing.T) {
 		fl := NewFileLock("")
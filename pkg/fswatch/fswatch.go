@@ -0,0 +1,19 @@
+package fswatch
+
+const (
+	// Create is a file created in or moved into the watched directory
+	Create = "create"
+	// Write is a file written in the watched directory
+	Write = "write"
+	// Remove is a file removed from or moved out of the watched directory
+	Remove = "remove"
+)
+
+// Ops are the supported ops of the events
+var Ops = []string{Create, Write, Remove}
+
+// Event is a change of a file in a watched directory
+type Event struct {
+	Path string
+	Op   string
+}
@@ -0,0 +1,142 @@
+package fswatch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM
+
+// Watcher watches the files of directories by inotify, the subdirectories aren't watched
+type Watcher struct {
+	Events chan Event
+	Errors chan error
+
+	file *os.File
+	mu   sync.Mutex
+	dirs map[int32]string
+	// closing stops sending the events which aren't received, done is closed once the events are closed
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// NewWatcher creates a watcher, Close must be called to release it
+func NewWatcher() (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init inotify: %w", err)
+	}
+	w := &Watcher{
+		Events:  make(chan Event, 64),
+		Errors:  make(chan error, 1),
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		dirs:    make(map[int32]string),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.read()
+	return w, nil
+}
+
+// Add watches the files of the directory
+func (w *Watcher) Add(dir string) error {
+	dir = filepath.Clean(dir)
+	conn, err := w.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var wd int
+	var addErr error
+	err = conn.Control(func(fd uintptr) {
+		wd, addErr = syscall.InotifyAddWatch(int(fd), dir, watchMask|syscall.IN_ONLYDIR)
+	})
+	if err = errors.Join(err, addErr); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	w.mu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+// Close stops watching, the events channel is closed once the watcher stops
+func (w *Watcher) Close() error {
+	close(w.closing)
+	err := w.file.Close()
+	<-w.done
+	return err
+}
+
+func (w *Watcher) read() {
+	defer close(w.done)
+	defer close(w.Events)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.sendError(fmt.Errorf("failed to read inotify events: %w", err))
+			}
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+			offset += syscall.SizeofInotifyEvent + int(raw.Len)
+			w.handle(raw, strings.TrimRight(string(nameBytes), "\x00"))
+		}
+	}
+}
+
+func (w *Watcher) handle(raw *syscall.InotifyEvent, name string) {
+	if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+		w.sendError(fmt.Errorf("inotify events overflowed, some changes are lost"))
+		return
+	}
+	w.mu.Lock()
+	dir, ok := w.dirs[raw.Wd]
+	if raw.Mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, raw.Wd)
+	}
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+	if raw.Mask&syscall.IN_IGNORED != 0 {
+		w.sendError(fmt.Errorf("%s isn't watched anymore, it's removed or unmounted", dir))
+		return
+	}
+	if len(name) == 0 || raw.Mask&syscall.IN_ISDIR != 0 {
+		return
+	}
+	var op string
+	switch {
+	case raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		op = Create
+	case raw.Mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0:
+		op = Write
+	case raw.Mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		op = Remove
+	default:
+		return
+	}
+	select {
+	case w.Events <- Event{Path: filepath.Join(dir, name), Op: op}:
+	case <-w.closing:
+	}
+}
+
+// sendError sends the error unless an error isn't received yet
+func (w *Watcher) sendError(err error) {
+	select {
+	case w.Errors <- err:
+	default:
+	}
+}
@@ -0,0 +1,93 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	watcher, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer watcher.Close()
+	if err = watcher.Add(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	next := func(t *testing.T) Event {
+		t.Helper()
+		select {
+		case event := <-watcher.Events:
+			return event
+		case err := <-watcher.Errors:
+			t.Fatalf("Unexpected error: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected an event")
+		}
+		return Event{}
+	}
+
+	file := filepath.Join(dir, "upload.csv")
+	if err = os.WriteFile(file, []byte("a,b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(t); event != (Event{Path: file, Op: Create}) {
+		t.Errorf("Expected create of %s, got %+v", file, event)
+	}
+	if event := next(t); event != (Event{Path: file, Op: Write}) {
+		t.Errorf("Expected write of %s, got %+v", file, event)
+	}
+	// the write is closed
+	next(t)
+
+	moved := filepath.Join(dir, "moved.csv")
+	if err = os.Rename(file, moved); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(t); event != (Event{Path: file, Op: Remove}) {
+		t.Errorf("Expected remove of %s, got %+v", file, event)
+	}
+	if event := next(t); event != (Event{Path: moved, Op: Create}) {
+		t.Errorf("Expected create of %s, got %+v", moved, event)
+	}
+
+	// the subdirectories aren't reported
+	if err = os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(moved); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(t); event != (Event{Path: moved, Op: Remove}) {
+		t.Errorf("Expected remove of %s, got %+v", moved, event)
+	}
+
+	t.Run("NotDir", func(t *testing.T) {
+		if err := watcher.Add(filepath.Join(dir, "not-exist")); err == nil {
+			t.Error("Expected an error of a missing dir")
+		}
+	})
+}
+
+func TestWatcher_Close(t *testing.T) {
+	dir := t.TempDir()
+	watcher, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = watcher.Add(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// the events aren't received
+	for i := 0; i < 100; i++ {
+		_ = os.WriteFile(filepath.Join(dir, "file"), []byte("a"), 0644)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_ = watcher.Close()
+	for range watcher.Events {
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package fswatch
+
+import "errors"
+
+// Watcher watches the files of directories, it's only supported on linux
+type Watcher struct {
+	Events chan Event
+	Errors chan error
+}
+
+// NewWatcher returns an error since the file watch is only supported on linux
+func NewWatcher() (*Watcher, error) {
+	return nil, errors.New("file watch is only supported on linux")
+}
+
+func (w *Watcher) Add(dir string) error {
+	return errors.New("file watch is only supported on linux")
+}
+
+func (w *Watcher) Close() error {
+	return nil
+}
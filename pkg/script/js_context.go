@@ -1,7 +1,9 @@
 package script
 
 import (
+	"context"
 	"fmt"
+	"nadleeh/pkg/common"
 	"nadleeh/pkg/file"
 
 	"github.com/dop251/goja/parser"
@@ -15,6 +17,7 @@ import (
 	"reflect"
 	"slices"
 	"strings"
+	"sync"
 )
 import "github.com/dop251/goja"
 
@@ -34,12 +37,16 @@ type JSContext struct {
 	JSSecCtx      JSSecureContext
 	scriptProgram map[string]*jsScriptProgram
 	count         int
+	// mu guards scriptProgram and count, jobs may compile and run scripts concurrently
+	mu *sync.RWMutex
 }
 
 var unAllowedEnvKeys = []string{"secure", "env", "http", "core", "file", "ssh"}
 
 func (js *JSContext) Compile(script string) error {
 	script = strings.TrimSpace(script)
+	js.mu.Lock()
+	defer js.mu.Unlock()
 	sp := js.scriptProgram[script]
 	if sp != nil {
 		return sp.err
@@ -71,6 +78,8 @@ func (js *JSContext) getFileKey(jsFile string) (string, error) {
 
 func (js *JSContext) CompileFile(jsFile string) (string, error) {
 	fileKey, err := js.getFileKey(jsFile)
+	js.mu.Lock()
+	defer js.mu.Unlock()
 	if err != nil {
 		js.scriptProgram[fileKey] = &jsScriptProgram{
 			err: err,
@@ -113,9 +122,15 @@ func (js *JSContext) CompileFile(jsFile string) (string, error) {
 	return fileKey, nil
 }
 
+func (js *JSContext) getProgram(key string) *jsScriptProgram {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	return js.scriptProgram[key]
+}
+
 func (js *JSContext) runWithProgram(vm *goja.Runtime, script string) (goja.Value, error) {
 	script = strings.TrimSpace(script)
-	sp := js.scriptProgram[script]
+	sp := js.getProgram(script)
 	if sp != nil {
 		if sp.err != nil {
 			return nil, sp.err
@@ -128,12 +143,17 @@ func (js *JSContext) runWithProgram(vm *goja.Runtime, script string) (goja.Value
 }
 
 func (js *JSContext) RunFile(env env.Env, jsFile string, variables map[string]interface{}) (int, string, error) {
+	return js.RunFileContext(context.Background(), env, jsFile, variables)
+}
+
+// RunFileContext runs the javascript file, the script is interrupted once ctx is done
+func (js *JSContext) RunFileContext(ctx context.Context, env env.Env, jsFile string, variables map[string]interface{}) (int, string, error) {
 	fileKey, err := js.CompileFile(jsFile)
 	if err != nil {
 		return 1, "", err
 	}
 
-	st := js.scriptProgram[fileKey]
+	st := js.getProgram(fileKey)
 	if st == nil || st.err != nil {
 		return 2, "", fmt.Errorf("invalud file %s", jsFile)
 	}
@@ -141,6 +161,8 @@ func (js *JSContext) RunFile(env env.Env, jsFile string, variables map[string]in
 	jsVm := NewJSVm()
 	defer jsVm.Shutdown()
 	vm := jsVm.Vm
+	stop := interruptOnDone(ctx, jsVm)
+	defer stop()
 	vm.Set("env", env)
 	vm.Set("secure", &js.JSSecCtx)
 
@@ -152,10 +174,7 @@ func (js *JSContext) RunFile(env env.Env, jsFile string, variables map[string]in
 	}
 
 	val, err := vm.RunProgram(st.program)
-	output := ""
-	if val != nil && val != goja.Undefined() && val != goja.Null() {
-		output = val.String()
-	}
+	output := exportOutput(val)
 	if err != nil {
 		return 1, output, err
 	}
@@ -164,9 +183,16 @@ func (js *JSContext) RunFile(env env.Env, jsFile string, variables map[string]in
 }
 
 func (js *JSContext) Run(env env.Env, script string, variables map[string]interface{}) (int, string, error) {
+	return js.RunContext(context.Background(), env, script, variables)
+}
+
+// RunContext runs the javascript, the script is interrupted once ctx is done
+func (js *JSContext) RunContext(ctx context.Context, env env.Env, script string, variables map[string]interface{}) (int, string, error) {
 	jsVm := NewJSVm()
 	defer jsVm.Shutdown()
 	vm := jsVm.Vm
+	stop := interruptOnDone(ctx, jsVm)
+	defer stop()
 
 	vm.Set("env", env)
 	vm.Set("secure", &js.JSSecCtx)
@@ -179,10 +205,7 @@ func (js *JSContext) Run(env env.Env, script string, variables map[string]interf
 	}
 
 	val, err := js.runWithProgram(vm, script)
-	output := ""
-	if val != nil && val != goja.Undefined() && val != goja.Null() {
-		output = val.String()
-	}
+	output := exportOutput(val)
 	if err != nil {
 		_ = file.LogStrWithLineNo("JS", script)
 		return 1, output, err
@@ -190,6 +213,28 @@ func (js *JSContext) Run(env env.Env, script string, variables map[string]interf
 	return 0, output, nil
 }
 
+// exportOutput returns the script result as a string, objects and arrays are returned as JSON
+func exportOutput(val goja.Value) string {
+	if val == nil || val == goja.Undefined() || val == goja.Null() {
+		return ""
+	}
+	if obj, ok := val.(*goja.Object); ok && (obj.ClassName() == "Object" || obj.ClassName() == "Array") {
+		if data, err := obj.MarshalJSON(); err == nil {
+			return string(data)
+		}
+	}
+	return val.String()
+}
+
+// interruptOnDone interrupts the vm once ctx is done, the returned function stops the watching. The ssh clients are
+// closed as well since an ssh command blocking the script isn't interrupted by the vm.
+func interruptOnDone(ctx context.Context, jsVm *JSVm) func() bool {
+	return context.AfterFunc(ctx, func() {
+		jsVm.Vm.Interrupt(fmt.Errorf("script is terminated: %w", ctx.Err()))
+		jsVm.ssh.Close()
+	})
+}
+
 func (js *JSContext) Eval(env env.Env, expression string, variables map[string]interface{}) (goja.Value, error) {
 	jsVm := NewJSVm()
 	defer jsVm.Shutdown()
@@ -304,11 +349,19 @@ func NewJSContext(secCtx *encrypt.SecureContext) JSContext {
 	return JSContext{
 		JSSecCtx:      JSSecureContext{secureCtx: secCtx},
 		scriptProgram: make(map[string]*jsScriptProgram),
+		mu:            &sync.RWMutex{},
 	}
 }
 
 type JSSecureContext struct {
 	secureCtx *encrypt.SecureContext
+	// masked is true if decrypt returns the masked value instead of the secret
+	masked bool
+}
+
+// MaskSecrets makes decrypt return the masked value, so a workflow is shown without revealing its secrets
+func (js *JSSecureContext) MaskSecrets() {
+	js.masked = true
 }
 
 func (js *JSSecureContext) IsEncrypted(str string) bool {
@@ -316,6 +369,10 @@ func (js *JSSecureContext) IsEncrypted(str string) bool {
 }
 
 func (js *JSSecureContext) Decrypt(str string) (*string, error) {
+	if js.masked {
+		masked := common.MaskedValue
+		return &masked, nil
+	}
 	val, err := js.secureCtx.DecryptStr(str)
 	if err != nil {
 		return nil, err
@@ -1,10 +1,13 @@
 package script
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"nadleeh/pkg/encrypt"
 
@@ -844,6 +847,15 @@ func TestJSSecureContext(t *testing.T) {
 		
 		// If we reach here, the methods exist and are callable
 	})
+
+	t.Run("MaskSecrets", func(t *testing.T) {
+		masked := JSSecureContext{secureCtx: &secCtx}
+		masked.MaskSecrets()
+		val, err := masked.Decrypt("ENC(c2VjcmV0)")
+		if err != nil || *val != "***" {
+			t.Errorf("Expected masked secret, got %v %v", val, err)
+		}
+	})
 }
 
 func TestUnAllowedEnvKeys(t *testing.T) {
@@ -936,3 +948,60 @@ func TestJSContext_runWithProgram(t *testing.T) {
 		}
 	})
 }
+
+func TestJSContext_RunContext(t *testing.T) {
+	jsCtx := NewJSContext(&encrypt.SecureContext{})
+
+	t.Run("InterruptOnTimeout", func(t *testing.T) {
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		exitCode, _, err := jsCtx.RunContext(timeoutCtx, newMockEnv(), "while (true) {}", nil)
+		if err == nil {
+			t.Fatal("Expected error for interrupted script")
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded error, got %v", err)
+		}
+		if exitCode != 1 {
+			t.Errorf("Expected exit code 1, got %d", exitCode)
+		}
+	})
+
+	t.Run("FinishBeforeTimeout", func(t *testing.T) {
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		exitCode, output, err := jsCtx.RunContext(timeoutCtx, newMockEnv(), "1 + 1", nil)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if exitCode != 0 || output != "2" {
+			t.Errorf("Expected exit code 0 and output 2, got %d and %s", exitCode, output)
+		}
+	})
+}
+
+func TestJSContext_RunObjectOutput(t *testing.T) {
+	jsCtx := NewJSContext(&encrypt.SecureContext{})
+
+	t.Run("Object", func(t *testing.T) {
+		_, output, err := jsCtx.Run(newMockEnv(), "({path: '/tmp/backup.tar', size: 10})", nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if output != `{"path":"/tmp/backup.tar","size":10}` {
+			t.Errorf("Expected JSON output, got %s", output)
+		}
+	})
+
+	t.Run("Array", func(t *testing.T) {
+		_, output, err := jsCtx.Run(newMockEnv(), "[1, 'a']", nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if output != `[1,"a"]` {
+			t.Errorf("Expected JSON output, got %s", output)
+		}
+	})
+}
@@ -1,9 +1,15 @@
 package script
 
-import "errors"
+import (
+	"errors"
+	"sync"
+)
 
+// NSSSHManager dials the ssh clients of a script and closes them once the script ends or is interrupted
 type NSSSHManager struct {
+	mu      sync.Mutex
 	clients []*NSSSHClient
+	closed  bool
 }
 
 func (s *NSSSHManager) Dial(host string, port int, username string, options map[string]any) (*NSSSHClient, error) {
@@ -33,14 +39,27 @@ func (s *NSSSHManager) Dial(host string, port int, username string, options map[
 	if err != nil {
 		return nil, err
 	}
-	
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		client.Close()
+		return nil, errors.New("ssh manager is closed, the script is terminated")
+	}
 	// Track client for cleanup
 	s.clients = append(s.clients, client)
 	return client, nil
 }
 
+// Close closes the dialed clients and their sessions, it may be called concurrently with the script once the script
+// is interrupted. No client can be dialed afterwards.
 func (s *NSSSHManager) Close() {
-	for _, client := range s.clients {
+	s.mu.Lock()
+	clients := s.clients
+	s.clients = nil
+	s.closed = true
+	s.mu.Unlock()
+	for _, client := range clients {
 		client.Close()
 	}
 }
@@ -1,14 +1,20 @@
 package shell
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"nadleeh/pkg/common"
 	"nadleeh/pkg/file"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"github.com/zhaojunlucky/golib/pkg/env"
+	"golang.org/x/term"
 
 	"io/fs"
 	"os"
@@ -16,6 +22,9 @@ import (
 	"path"
 )
 
+// killWaitDelay is how long to wait for the output pipes to be closed after the bash process group is killed
+const killWaitDelay = 5 * time.Second
+
 type bashScript struct {
 	err error
 }
@@ -65,6 +74,41 @@ func (sh *ShellContext) getShellTmpFile(script string) (string, error) {
 }
 
 func (sh *ShellContext) Run(env env.Env, shell string, needOutput bool) (int, string, error) {
+	return sh.RunContext(context.Background(), env, shell, needOutput)
+}
+
+// RunContext runs the bash script, the whole bash process group is killed once ctx is done
+func (sh *ShellContext) RunContext(ctx context.Context, env env.Env, shell string, needOutput bool) (int, string, error) {
+	return sh.run(ctx, env, shell, needOutput, nil, nil)
+}
+
+// RunStep runs the bash script of a step, the env written to $NADLEEH_ENV and the outputs written to
+// $NADLEEH_OUTPUT are returned. The output of the script is written to out as well if it's not nil.
+func (sh *ShellContext) RunStep(ctx context.Context, env env.Env, shell string, needOutput bool, out io.Writer) (int, string, *StepFiles, error) {
+	envFile, err := sh.createStepFile("env")
+	if err != nil {
+		return 1, "", nil, err
+	}
+	defer os.Remove(envFile)
+	outputFile, err := sh.createStepFile("output")
+	if err != nil {
+		return 1, "", nil, err
+	}
+	defer os.Remove(outputFile)
+
+	retCode, output, err := sh.run(ctx, env, shell, needOutput, map[string]string{EnvFileEnv: envFile, OutputFileEnv: outputFile}, out)
+	stepFiles, parseErr := parseStepFiles(envFile, outputFile)
+	if parseErr != nil {
+		log.Errorf("failed to parse step files of bash script: %v", parseErr)
+		if err == nil {
+			return 1, output, stepFiles, parseErr
+		}
+	}
+	return retCode, output, stepFiles, err
+}
+
+func (sh *ShellContext) run(ctx context.Context, env env.Env, shell string, needOutput bool, stepEnvs map[string]string,
+	out io.Writer) (int, string, error) {
 
 	tmpShFile, err := sh.getShellTmpFile(shell)
 	if err != nil {
@@ -72,7 +116,25 @@ func (sh *ShellContext) Run(env env.Env, shell string, needOutput bool) (int, st
 	}
 
 	defer os.Remove(tmpShFile)
-	cmd := exec.Command("/bin/bash", "-e", tmpShFile)
+	cmd := exec.CommandContext(ctx, "/bin/bash", "-e", tmpShFile)
+	cancellable := ctx.Done() != nil
+	_, hasDeadline := ctx.Deadline()
+	stdinFd := int(os.Stdin.Fd())
+	// a script without timeout which may read the terminal is handed the foreground of the terminal, the terminal
+	// sends Ctrl+C to the processes started by the script
+	foreground := cancellable && !needOutput && !hasDeadline && isForeground(stdinFd)
+	if cancellable {
+		// run bash in its own process group, so the processes started by the script are killed as well
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if foreground {
+			cmd.SysProcAttr.Foreground = true
+			cmd.SysProcAttr.Ctty = stdinFd
+		}
+		cmd.Cancel = func() error {
+			return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		cmd.WaitDelay = killWaitDelay
+	}
 
 	for key, value := range common.Sys.GetInfo().GetAll() {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
@@ -82,27 +144,59 @@ func (sh *ShellContext) Run(env env.Env, shell string, needOutput bool) (int, st
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
 	}
 
+	for key, value := range stepEnvs {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+
 	var output string
 	if needOutput {
 		aow := NewStdOutputWriter()
-		cmd.Stdout = *aow
-		cmd.Stderr = *aow
+		cmd.Stdout = teeWriter(*aow, out)
+		cmd.Stderr = teeWriter(*aow, out)
 		err = cmd.Run()
 		output = aow.String()
 	} else {
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
-		cmd.Stdin = os.Stdin
+		cmd.Stderr = teeWriter(os.Stderr, out)
+		cmd.Stdout = teeWriter(os.Stdout, out)
+		// a background process group is stopped when reading the terminal
+		if !cancellable || foreground || !term.IsTerminal(stdinFd) {
+			cmd.Stdin = os.Stdin
+		}
 		err = cmd.Run()
+		if foreground {
+			takeForeground(stdinFd)
+			forwardInterrupt(ctx, err)
+		}
 	}
 
 	if err != nil {
+		if ctx.Err() != nil {
+			log.Errorf("bash script is terminated: %v", ctx.Err())
+			return 1, output, fmt.Errorf("bash script is terminated: %w", ctx.Err())
+		}
 		_ = file.LogFileWithLineNo("bash", tmpShFile)
-		return 1, output, err
+		return exitCode(err), output, err
 	}
 	return 0, output, nil
 }
 
+// exitCode returns the exit code of the failed bash script, it's 1 if bash didn't exit by itself
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// teeWriter returns a writer which writes to w and out, w is returned as is if out is nil
+func teeWriter(w io.Writer, out io.Writer) io.Writer {
+	if out == nil {
+		return w
+	}
+	return io.MultiWriter(w, out)
+}
+
 func NewShellContext() ShellContext {
 	return ShellContext{
 		TmpDir:      os.TempDir(),
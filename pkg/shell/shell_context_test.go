@@ -1,11 +1,14 @@
 package shell
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // mockEnv implements the env.Env interface for testing
@@ -423,8 +426,8 @@ func TestShellContext_Run(t *testing.T) {
 			t.Error("Expected error for invalid shell script")
 		}
 		
-		if exitCode != 1 {
-			t.Errorf("Expected exit code 1, got %d", exitCode)
+		if exitCode != 127 {
+			t.Errorf("Expected exit code 127, got %d", exitCode)
 		}
 	})
 	
@@ -494,8 +497,9 @@ func TestShellContext_Integration(t *testing.T) {
 			t.Error("Expected run to fail for invalid script")
 		}
 		
-		if exitCode != 1 {
-			t.Errorf("Expected exit code 1, got %d", exitCode)
+		// bash exits with 2 for a syntax error
+		if exitCode != 2 {
+			t.Errorf("Expected exit code 2, got %d", exitCode)
 		}
 	})
 }
@@ -619,3 +623,70 @@ func BenchmarkShellContext_getShellTmpFile(b *testing.B) {
 		}
 	}
 }
+
+func TestShellContext_RunContext(t *testing.T) {
+	t.Run("KillProcessGroupOnTimeout", func(t *testing.T) {
+		ctx := NewShellContext()
+		mockEnv := newMockEnv()
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		// the child sleep keeps the output pipe open, it must be killed together with bash
+		exitCode, _, err := ctx.RunContext(timeoutCtx, mockEnv, "sleep 30 &\nsleep 30", true)
+		if err == nil {
+			t.Fatal("Expected error for timed out script")
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded error, got %v", err)
+		}
+		if exitCode != 1 {
+			t.Errorf("Expected exit code 1, got %d", exitCode)
+		}
+		if time.Since(start) > killWaitDelay {
+			t.Errorf("Expected the script to be killed quickly, took %v", time.Since(start))
+		}
+	})
+
+	t.Run("KillBackgroundChildOnCancel", func(t *testing.T) {
+		ctx := NewShellContext()
+		pidFile := filepath.Join(t.TempDir(), "pid")
+		cancelCtx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(300*time.Millisecond, cancel)
+
+		_, _, err := ctx.RunContext(cancelCtx, newMockEnv(), fmt.Sprintf("sleep 1000 &\necho $! > %s\nwait", pidFile), false)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected the script to be cancelled, got %v", err)
+		}
+		content, err := os.ReadFile(pidFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid := strings.TrimSpace(string(content))
+		// the killed child may stay a zombie until it's reaped by init
+		for start := time.Now(); ; time.Sleep(50 * time.Millisecond) {
+			stat, err := os.ReadFile(filepath.Join("/proc", pid, "stat"))
+			if err != nil || strings.Contains(string(stat), ") Z ") {
+				break
+			}
+			if time.Since(start) > 2*time.Second {
+				t.Fatalf("Expected the background child %s to be killed, got %s", pid, stat)
+			}
+		}
+	})
+
+	t.Run("FinishBeforeTimeout", func(t *testing.T) {
+		ctx := NewShellContext()
+		mockEnv := newMockEnv()
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		exitCode, _, err := ctx.RunContext(timeoutCtx, mockEnv, "echo done", false)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if exitCode != 0 {
+			t.Errorf("Expected exit code 0, got %d", exitCode)
+		}
+	})
+}
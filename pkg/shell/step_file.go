@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// EnvFileEnv is the env of the file a bash step writes the env of the following steps to
+	EnvFileEnv = "NADLEEH_ENV"
+	// OutputFileEnv is the env of the file a bash step writes its outputs to
+	OutputFileEnv = "NADLEEH_OUTPUT"
+)
+
+// StepFiles holds the env and outputs written by a bash step
+type StepFiles struct {
+	Env     map[string]string
+	Outputs map[string]string
+}
+
+// createStepFile creates an empty step file for the bash script to write to
+func (sh *ShellContext) createStepFile(name string) (string, error) {
+	f, err := os.CreateTemp(sh.TmpDir, fmt.Sprintf("nadleeh_%s_*", name))
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s file: %w", name, err)
+	}
+	defer f.Close()
+	return f.Name(), nil
+}
+
+// parseStepFiles parses the env and output files of a bash step, the values parsed are returned even if one of them is invalid
+func parseStepFiles(envFile, outputFile string) (*StepFiles, error) {
+	stepFiles := &StepFiles{
+		Env:     make(map[string]string),
+		Outputs: make(map[string]string),
+	}
+	var errs []error
+	envs, err := ParseStepFile(envFile)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to parse $%s: %w", EnvFileEnv, err))
+	}
+	for key, value := range envs {
+		if key == EnvFileEnv || key == OutputFileEnv {
+			errs = append(errs, fmt.Errorf("failed to parse $%s: env %s is not allowed to be set", EnvFileEnv, key))
+			continue
+		}
+		stepFiles.Env[key] = value
+	}
+
+	outputs, err := ParseStepFile(outputFile)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to parse $%s: %w", OutputFileEnv, err))
+	}
+	for key, value := range outputs {
+		stepFiles.Outputs[key] = value
+	}
+	return stepFiles, errors.Join(errs...)
+}
+
+// ParseStepFile parses a step file of key=value lines, a multi-line value is written as
+//
+//	key<<DELIMITER
+//	value
+//	DELIMITER
+func ParseStepFile(stepFile string) (map[string]string, error) {
+	f, err := os.Open(stepFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseStepFile(f)
+}
+
+func parseStepFile(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		heredoc := strings.Index(line, "<<")
+		if heredoc > 0 && (eq < 0 || heredoc < eq) {
+			key := strings.TrimSpace(line[:heredoc])
+			delimiter := strings.TrimSpace(line[heredoc+2:])
+			if len(delimiter) == 0 {
+				return nil, fmt.Errorf("invalid line %d: missing delimiter of %s", lineNo, key)
+			}
+			start := lineNo
+			var valueLines []string
+			closed := false
+			for scanner.Scan() {
+				lineNo++
+				valueLine := strings.TrimSuffix(scanner.Text(), "\r")
+				if valueLine == delimiter {
+					closed = true
+					break
+				}
+				valueLines = append(valueLines, valueLine)
+			}
+			if !closed {
+				return nil, fmt.Errorf("invalid line %d: delimiter %s of %s is not found", start, delimiter, key)
+			}
+			values[key] = strings.Join(valueLines, "\n")
+			continue
+		}
+
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid line %d: %s, expect key=value or key<<DELIMITER", lineNo, line)
+		}
+		values[strings.TrimSpace(line[:eq])] = line[eq+1:]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
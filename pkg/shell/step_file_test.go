@@ -0,0 +1,150 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseStepFile(t *testing.T) {
+	t.Run("KeyValue", func(t *testing.T) {
+		values, err := parseStepFile(strings.NewReader("path=/tmp/backup.tar\n\nquery=a=b\nempty=\n"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		expected := map[string]string{"path": "/tmp/backup.tar", "query": "a=b", "empty": ""}
+		for key, value := range expected {
+			if values[key] != value {
+				t.Errorf("Expected %s=%s, got %s", key, value, values[key])
+			}
+		}
+	})
+
+	t.Run("Heredoc", func(t *testing.T) {
+		values, err := parseStepFile(strings.NewReader("notes<<EOF\nline 1\nkey=value\n\nline 4\nEOF\nname=backup\n"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if values["notes"] != "line 1\nkey=value\n\nline 4" {
+			t.Errorf("Unexpected multi-line value: %q", values["notes"])
+		}
+		if values["name"] != "backup" {
+			t.Errorf("Expected name=backup, got %s", values["name"])
+		}
+	})
+
+	t.Run("LastValueWins", func(t *testing.T) {
+		values, err := parseStepFile(strings.NewReader("a=1\na=2\n"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if values["a"] != "2" {
+			t.Errorf("Expected a=2, got %s", values["a"])
+		}
+	})
+
+	t.Run("UnclosedHeredoc", func(t *testing.T) {
+		_, err := parseStepFile(strings.NewReader("notes<<EOF\nline 1\n"))
+		if err == nil || !strings.Contains(err.Error(), "delimiter EOF") {
+			t.Errorf("Expected unclosed delimiter error, got %v", err)
+		}
+	})
+
+	t.Run("InvalidLine", func(t *testing.T) {
+		_, err := parseStepFile(strings.NewReader("a=1\ninvalid\n"))
+		if err == nil || !strings.Contains(err.Error(), "invalid line 2") {
+			t.Errorf("Expected invalid line error, got %v", err)
+		}
+	})
+}
+
+func TestShellContext_RunStep(t *testing.T) {
+	ctx := NewShellContext()
+
+	t.Run("Outputs", func(t *testing.T) {
+		script := `echo "path=/tmp/backup.tar" >> "$NADLEEH_OUTPUT"
+{
+  echo "notes<<EOF"
+  echo "line 1"
+  echo "line 2"
+  echo "EOF"
+} >> "$NADLEEH_OUTPUT"`
+		exitCode, _, stepFiles, err := ctx.RunStep(t.Context(), newMockEnv(), script, false, nil)
+		if err != nil || exitCode != 0 {
+			t.Fatalf("Expected success, got %d %v", exitCode, err)
+		}
+		if stepFiles.Outputs["path"] != "/tmp/backup.tar" {
+			t.Errorf("Expected output path, got %v", stepFiles.Outputs)
+		}
+		if stepFiles.Outputs["notes"] != "line 1\nline 2" {
+			t.Errorf("Expected multi-line output notes, got %q", stepFiles.Outputs["notes"])
+		}
+	})
+
+	t.Run("OutputFileRemoved", func(t *testing.T) {
+		pathFile := filepath.Join(t.TempDir(), "path")
+		exitCode, _, _, err := ctx.RunStep(t.Context(), newMockEnv(), fmt.Sprintf(`printf "%%s" "$NADLEEH_OUTPUT" > %s`, pathFile), false, nil)
+		if err != nil || exitCode != 0 {
+			t.Fatalf("Expected success, got %d %v", exitCode, err)
+		}
+		outputFile, err := os.ReadFile(pathFile)
+		if err != nil || len(outputFile) == 0 {
+			t.Fatalf("Expected output file path, got %v", err)
+		}
+		if _, err := os.Stat(string(outputFile)); !os.IsNotExist(err) {
+			t.Errorf("Expected output file %s to be removed", outputFile)
+		}
+	})
+
+	t.Run("InvalidOutputs", func(t *testing.T) {
+		exitCode, _, _, err := ctx.RunStep(t.Context(), newMockEnv(), `echo "invalid" >> "$NADLEEH_OUTPUT"`, false, nil)
+		if err == nil || exitCode == 0 {
+			t.Errorf("Expected error for invalid outputs, got %d %v", exitCode, err)
+		}
+	})
+
+	t.Run("FailedScriptKeepsOutputs", func(t *testing.T) {
+		exitCode, _, stepFiles, err := ctx.RunStep(t.Context(), newMockEnv(), "echo \"code=7\" >> \"$NADLEEH_OUTPUT\"\nexit 7", false, nil)
+		if err == nil || exitCode == 0 {
+			t.Fatalf("Expected failure, got %d %v", exitCode, err)
+		}
+		if stepFiles.Outputs["code"] != "7" {
+			t.Errorf("Expected output code, got %v", stepFiles.Outputs)
+		}
+	})
+
+	t.Run("Env", func(t *testing.T) {
+		script := `echo "BACKUP_DIR=/tmp/backup" >> "$NADLEEH_ENV"
+cat >> "$NADLEEH_ENV" <<'END'
+NOTES<<EOF
+first line
+second line
+EOF
+END`
+		exitCode, _, stepFiles, err := ctx.RunStep(t.Context(), newMockEnv(), script, false, nil)
+		if err != nil || exitCode != 0 {
+			t.Fatalf("Expected success, got %d %v", exitCode, err)
+		}
+		if stepFiles.Env["BACKUP_DIR"] != "/tmp/backup" {
+			t.Errorf("Expected env BACKUP_DIR, got %v", stepFiles.Env)
+		}
+		if stepFiles.Env["NOTES"] != "first line\nsecond line" {
+			t.Errorf("Expected multi-line env NOTES, got %q", stepFiles.Env["NOTES"])
+		}
+		if len(stepFiles.Outputs) != 0 {
+			t.Errorf("Expected no outputs, got %v", stepFiles.Outputs)
+		}
+	})
+
+	t.Run("ReservedEnv", func(t *testing.T) {
+		exitCode, _, stepFiles, err := ctx.RunStep(t.Context(), newMockEnv(), `echo "NADLEEH_OUTPUT=/tmp/x" >> "$NADLEEH_ENV"`, false, nil)
+		if err == nil || exitCode == 0 {
+			t.Fatalf("Expected error for reserved env, got %d %v", exitCode, err)
+		}
+		if _, ok := stepFiles.Env[OutputFileEnv]; ok {
+			t.Errorf("Expected reserved env to be ignored, got %v", stepFiles.Env)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// interruptWait is how long to wait for the forwarded Ctrl+C to cancel the run
+const interruptWait = time.Second
+
+// isForeground returns whether fd is a terminal and nadleeh is its foreground process group, so a script can be
+// handed the terminal
+func isForeground(fd int) bool {
+	if !term.IsTerminal(fd) {
+		return false
+	}
+	pgrp, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	return err == nil && pgrp == syscall.Getpgrp()
+}
+
+// takeForeground puts the process group of nadleeh back to the foreground of the terminal after the script which was
+// handed the terminal exited. SIGTTOU is ignored meanwhile since nadleeh is in the background until then.
+func takeForeground(fd int) {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, syscall.Getpgrp()); err != nil {
+		log.Warnf("failed to take the terminal back from the bash script: %v", err)
+	}
+}
+
+// forwardInterrupt sends SIGINT to nadleeh if the script in the foreground of the terminal was interrupted, the
+// terminal sends Ctrl+C only to the process group of the script. It waits for ctx to be cancelled by the signal.
+func forwardInterrupt(ctx context.Context, err error) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() || status.Signal() != syscall.SIGINT {
+		return
+	}
+	log.Warnf("bash script is interrupted, interrupt nadleeh as well")
+	if err = syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		log.Warnf("failed to interrupt nadleeh: %v", err)
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(interruptWait):
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// OutputResultKey is the output name of a script result which is not an object
+const OutputResultKey = "result"
+
+type outputsRecord struct {
+	status  *RunnableStatus
+	outputs map[string]string
+}
+
+// OutputsContext records the status and outputs of the steps with an id in a job, or the jobs in a workflow. It's
+// exposed to expressions as steps and jobs.
+type OutputsContext struct {
+	mu      sync.RWMutex
+	records map[string]*outputsRecord
+}
+
+func NewOutputsContext() *OutputsContext {
+	return &OutputsContext{
+		records: make(map[string]*outputsRecord),
+	}
+}
+
+// Record records the status and outputs of the step or job
+func (s *OutputsContext) Record(id string, status *RunnableStatus, outputs map[string]string) {
+	if s == nil || len(id) == 0 {
+		return
+	}
+	if outputs == nil {
+		outputs = make(map[string]string)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[id] = &outputsRecord{status: status, outputs: outputs}
+}
+
+// Outputs returns the outputs of the step or job, it returns nil if it's not recorded
+func (s *OutputsContext) Outputs(id string) map[string]string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[id]
+	if !ok {
+		return nil
+	}
+	return record.outputs
+}
+
+// ToMap returns the records as <id>.outputs, <id>.status and <id>.conclusion
+func (s *OutputsContext) ToMap() map[string]any {
+	records := make(map[string]any)
+	if s == nil {
+		return records
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for id, record := range s.records {
+		records[id] = map[string]any{
+			"outputs":    record.outputs,
+			"status":     record.status.Status(),
+			"conclusion": record.status.Conclusion(),
+		}
+	}
+	return records
+}
+
+// ParseOutputs parses the outputs of a script result. A JSON object result is parsed as named outputs,
+// any other non-empty result is the output named result.
+func ParseOutputs(output string) map[string]string {
+	outputs := make(map[string]string)
+	if len(output) == 0 {
+		return outputs
+	}
+	var values map[string]any
+	if err := json.Unmarshal([]byte(output), &values); err != nil || values == nil {
+		outputs[OutputResultKey] = output
+		return outputs
+	}
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			outputs[key] = v
+		case nil:
+			outputs[key] = ""
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				outputs[key] = fmt.Sprintf("%v", v)
+			} else {
+				outputs[key] = string(data)
+			}
+		}
+	}
+	return outputs
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOutputsContext(t *testing.T) {
+	t.Run("ToMap", func(t *testing.T) {
+		steps := NewOutputsContext()
+		passed := NewRunnableStatus("build", "step")
+		passed.Finish()
+		failed := NewRunnableStatus("lint", "step")
+		failed.Finish(errors.New("lint failed"))
+		failed.ContinueOnErr = true
+		steps.Record("build", passed, map[string]string{"path": "/tmp/out"})
+		steps.Record("lint", failed, nil)
+		steps.Record("", passed, nil)
+
+		m := steps.ToMap()
+		if len(m) != 2 {
+			t.Fatalf("Expected 2 steps, got %d", len(m))
+		}
+		build := m["build"].(map[string]any)
+		if build["outputs"].(map[string]string)["path"] != "/tmp/out" {
+			t.Errorf("Unexpected outputs %v", build["outputs"])
+		}
+		if build["status"] != Pass || build["conclusion"] != Pass {
+			t.Errorf("Unexpected build status %v and conclusion %v", build["status"], build["conclusion"])
+		}
+		lint := m["lint"].(map[string]any)
+		if lint["status"] != Fail || lint["conclusion"] != Pass {
+			t.Errorf("Unexpected lint status %v and conclusion %v", lint["status"], lint["conclusion"])
+		}
+		if len(lint["outputs"].(map[string]string)) != 0 {
+			t.Errorf("Expected empty outputs, got %v", lint["outputs"])
+		}
+	})
+
+	t.Run("NilContext", func(t *testing.T) {
+		var steps *OutputsContext
+		steps.Record("build", NewRunnableStatus("build", "step"), nil)
+		if len(steps.ToMap()) != 0 || steps.Outputs("build") != nil {
+			t.Error("Expected nil steps context to be empty")
+		}
+	})
+}
+
+func TestParseOutputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected map[string]string
+	}{
+		{"Empty", "", map[string]string{}},
+		{"Scalar", "15", map[string]string{OutputResultKey: "15"}},
+		{"Array", `[1,2]`, map[string]string{OutputResultKey: "[1,2]"}},
+		{"Object", `{"path":"/tmp/out","size":10,"ok":true,"tags":["a"],"none":null}`,
+			map[string]string{"path": "/tmp/out", "size": "10", "ok": "true", "tags": `["a"]`, "none": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputs := ParseOutputs(tt.output)
+			if len(outputs) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, outputs)
+			}
+			for key, value := range tt.expected {
+				if outputs[key] != value {
+					t.Errorf("Expected %s=%s, got %s", key, value, outputs[key])
+				}
+			}
+		})
+	}
+}
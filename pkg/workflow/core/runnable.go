@@ -1,6 +1,8 @@
 package core
 
 import (
+	"context"
+	"io"
 	"nadleeh/pkg/workflow/run_context"
 
 	"github.com/zhaojunlucky/golib/pkg/env"
@@ -11,6 +13,8 @@ type RunnableResult struct {
 	Err        error
 	ReturnCode int
 	Output     string
+	// Outputs are the named outputs of a step
+	Outputs map[string]string
 }
 
 type RunnableContext struct {
@@ -18,13 +22,59 @@ type RunnableContext struct {
 	Args           env.Env
 	JobStatus      *RunnableStatus
 	WorkflowStatus *RunnableStatus
+	// Context is cancelled when the running job or step times out or the run is cancelled
+	Context context.Context
+	// Cancellation is cancelled when the run is cancelled, unlike Context it's kept by the post steps and the finally
+	// job which still run
+	Cancellation context.Context
+	Step         *StepContext
+	// Steps records the steps with an id of the running job
+	Steps *OutputsContext
+	// Jobs records the jobs of the running workflow
+	Jobs *OutputsContext
+	// Matrix is the matrix combination of the running job instance
+	Matrix map[string]any
+	// Inputs are the inputs of the running composite step
+	Inputs map[string]string
+	// Trigger is what triggered the run like the type and the changed path of a watch trigger, it's exposed as trigger
+	Trigger map[string]string
+	// Output receives the output of the running step, it's nil if the step output isn't logged
+	Output io.Writer
+}
+
+// StepContext holds the state of the running step attempt, it's exposed to expressions as step
+type StepContext struct {
+	Attempt  int
+	ExitCode int
+	Error    string
+}
+
+// Cancelled returns whether the run is cancelled, e.g. nadleeh is interrupted by SIGINT or SIGTERM. It's available to
+// expressions as cancelled().
+func (r *RunnableContext) Cancelled() bool {
+	return r.Cancellation != nil && r.Cancellation.Err() != nil
+}
+
+// GetContext returns the context of the runnable, it never returns nil
+func (r *RunnableContext) GetContext() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+	return r.Context
 }
 
 func (r *RunnableContext) GenerateMap() map[string]any {
 	return map[string]any{
-		"args":     r.Args,
-		"workflow": r.WorkflowStatus,
-		"job":      r.JobStatus,
+		"args":      r.Args,
+		"workflow":  r.WorkflowStatus,
+		"job":       r.JobStatus,
+		"step":      r.Step,
+		"steps":     r.Steps.ToMap(),
+		"jobs":      r.Jobs.ToMap(),
+		"matrix":    r.Matrix,
+		"inputs":    r.Inputs,
+		"cancelled": r.Cancelled,
+		"trigger":   r.Trigger,
 	}
 }
 
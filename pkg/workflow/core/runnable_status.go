@@ -1,13 +1,19 @@
 package core
 
-import "strings"
+import (
+	"strings"
+	"sync"
+	"time"
+)
 
 var (
-	Fail     = "Fail"
-	Pass     = "Pass"
-	NotStart = "NotStart"
-	Running  = "Running"
-	Skipped  = "Skipped"
+	Fail      = "Fail"
+	Pass      = "Pass"
+	NotStart  = "NotStart"
+	Running   = "Running"
+	Skipped   = "Skipped"
+	TimedOut  = "TimedOut"
+	Cancelled = "Cancelled"
 )
 
 type RunnableStatus struct {
@@ -18,13 +24,63 @@ type RunnableStatus struct {
 	childs        []*RunnableStatus
 	childMap      map[string]*RunnableStatus
 	ContinueOnErr bool
+	logFile       string
+	skipReason    string
+	restoredFrom  string
+	exitCode      int
+	// hasExitCode is whether the exit code is set, a step which fails before its command runs has no exit code
+	hasExitCode bool
+	startTime   time.Time
+	endTime     time.Time
+
+	mu sync.RWMutex
+}
+
+// StatusSnapshot is a copy of a runnable status tree which can be saved as json
+type StatusSnapshot struct {
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Status       string            `json:"status"`
+	Conclusion   string            `json:"conclusion"`
+	Errors       []string          `json:"errors,omitempty"`
+	SkipReason   string            `json:"skipReason,omitempty"`
+	RestoredFrom string            `json:"restoredFrom,omitempty"`
+	ExitCode     *int              `json:"exitCode,omitempty"`
+	StartTime    *time.Time        `json:"startTime,omitempty"`
+	EndTime      *time.Time        `json:"endTime,omitempty"`
+	DurationMs   int64             `json:"durationMs"`
+	LogFile      string            `json:"logFile,omitempty"`
+	Children     []*StatusSnapshot `json:"children,omitempty"`
+}
+
+func (r *RunnableStatus) Name() string {
+	return r.name
+}
+
+func (r *RunnableStatus) Type() string {
+	return r.rType
 }
 
 func (r *RunnableStatus) Status() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status
+}
+
+// Conclusion returns the status after continue-on-error is applied, a failure which is allowed to continue is Pass.
+// A cancelled runnable is never continued.
+func (r *RunnableStatus) Conclusion() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if (r.status == Fail || r.status == TimedOut) && r.ContinueOnErr {
+		return Pass
+	}
 	return r.status
 }
 
 func (r *RunnableStatus) errors() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var allErrs []string
 	allErrs = append(allErrs, r.errs...)
 	for _, child := range r.childs {
@@ -38,7 +94,12 @@ func (r *RunnableStatus) Reason() string {
 }
 
 func (r *RunnableStatus) FutureStatus() string {
-	if r.status == Fail && !r.ContinueOnErr {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.status == Cancelled {
+		return Fail
+	}
+	if (r.status == Fail || r.status == TimedOut) && !r.ContinueOnErr {
 		return Fail
 	}
 	for _, child := range r.childs {
@@ -49,7 +110,37 @@ func (r *RunnableStatus) FutureStatus() string {
 	return Pass
 }
 
+// Failure returns whether the future status is Fail, it's job.failure() of the expressions
+func (r *RunnableStatus) Failure() bool {
+	return r.FutureStatus() == Fail
+}
+
+// CanContinue returns whether the runnables after it can run, it's false if the runnable or a child failed and the
+// failure isn't allowed to continue. The failures of the children of a runnable which continues on error are allowed
+// as well, e.g. the failed attempts of a step.
+func (r *RunnableStatus) CanContinue() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.status == Cancelled {
+		return false
+	}
+	if r.ContinueOnErr {
+		return true
+	}
+	if r.status == Fail || r.status == TimedOut {
+		return false
+	}
+	for _, child := range r.childs {
+		if !child.CanContinue() {
+			return false
+		}
+	}
+	return true
+}
+
 func (r *RunnableStatus) Finish(errs ...error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if len(errs) > 0 {
 		r.status = Fail
 	} else {
@@ -58,17 +149,147 @@ func (r *RunnableStatus) Finish(errs ...error) {
 	for _, err := range errs {
 		r.errs = append(r.errs, err.Error())
 	}
+	r.endTime = time.Now()
+}
+
+// AddError adds the error to the reason of the runnable, its status isn't changed
+func (r *RunnableStatus) AddError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err.Error())
+}
+
+// TimedOut marks the runnable as timed out with the given error
+func (r *RunnableStatus) TimedOut(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = TimedOut
+	if err != nil {
+		r.errs = append(r.errs, err.Error())
+	}
+	r.endTime = time.Now()
+}
+
+// Cancel marks the runnable as cancelled with the given error, e.g. nadleeh is interrupted while it's running
+func (r *RunnableStatus) Cancel(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = Cancelled
+	if err != nil {
+		r.errs = append(r.errs, err.Error())
+	}
+	r.endTime = time.Now()
+}
+
+// SetContinueOnErr sets whether a failure of the runnable is allowed to continue, it's set under the lock since the
+// status may be read concurrently, e.g. by the summary of the running workflow
+func (r *RunnableStatus) SetContinueOnErr(value bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ContinueOnErr = value
 }
 
 func (r *RunnableStatus) Skipped() {
+	r.SkippedWithReason("")
+}
+
+// SkippedWithReason marks the runnable as skipped, the reason tells why it's skipped
+func (r *RunnableStatus) SkippedWithReason(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.status = Skipped
+	r.skipReason = reason
+	r.endTime = time.Now()
+}
+
+// SkipReason returns why the runnable is skipped
+func (r *RunnableStatus) SkipReason() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.skipReason
+}
+
+// Restored marks the runnable as passed without running it, it passed in the resumed run of runId
+func (r *RunnableStatus) Restored(runId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = Pass
+	r.restoredFrom = runId
+	r.endTime = time.Now()
+}
+
+// RestoredFrom returns the resumed run which the runnable passed in, it's empty if the runnable isn't restored
+func (r *RunnableStatus) RestoredFrom() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.restoredFrom
 }
 
 func (r *RunnableStatus) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.status = Running
+	r.startTime = time.Now()
+}
+
+// StartTime returns when the runnable started, it's zero if the runnable didn't start
+func (r *RunnableStatus) StartTime() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.startTime
+}
+
+// EndTime returns when the runnable finished, it's zero if the runnable didn't finish
+func (r *RunnableStatus) EndTime() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.endTime
+}
+
+// Elapsed returns how long the runnable ran, it's up to now if the runnable is still running and zero if it didn't
+// start
+func (r *RunnableStatus) Elapsed() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.startTime.IsZero() {
+		return 0
+	}
+	if r.endTime.IsZero() || r.endTime.Before(r.startTime) {
+		return time.Since(r.startTime)
+	}
+	return r.endTime.Sub(r.startTime)
+}
+
+// Duration returns how long the runnable ran in seconds, it's available to expressions as job.duration()
+func (r *RunnableStatus) Duration() float64 {
+	return r.Elapsed().Seconds()
+}
+
+// SetExitCode sets the exit code of the runnable, e.g. the exit code of a bash step
+func (r *RunnableStatus) SetExitCode(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exitCode = code
+	r.hasExitCode = true
+}
+
+// HasExitCode returns whether the exit code of the runnable is set
+func (r *RunnableStatus) HasExitCode() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.hasExitCode
+}
+
+// ExitCode returns the exit code of the runnable
+func (r *RunnableStatus) ExitCode() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.exitCode
 }
 
 func (r *RunnableStatus) AddChild(child *RunnableStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.childs = append(r.childs, child)
 	if len(child.name) > 0 {
 		r.childMap[child.name] = child
@@ -76,13 +297,64 @@ func (r *RunnableStatus) AddChild(child *RunnableStatus) {
 }
 
 func (r *RunnableStatus) GetChild(name string) *RunnableStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.childMap[name]
 }
 
 func (r *RunnableStatus) GetChildByIndex(i int) *RunnableStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.childs[i]
 }
 
+// Children returns a copy of the children of the runnable
+func (r *RunnableStatus) Children() []*RunnableStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*RunnableStatus{}, r.childs...)
+}
+
+// SetLogFile sets the file which the output of the runnable is written to
+func (r *RunnableStatus) SetLogFile(logFile string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logFile = logFile
+}
+
+// Snapshot returns a copy of the status tree
+func (r *RunnableStatus) Snapshot() *StatusSnapshot {
+	snapshot := &StatusSnapshot{
+		Name:       r.name,
+		Type:       r.rType,
+		Status:     r.Status(),
+		Conclusion: r.Conclusion(),
+	}
+	snapshot.DurationMs = r.Elapsed().Milliseconds()
+	r.mu.RLock()
+	snapshot.Errors = append(snapshot.Errors, r.errs...)
+	snapshot.SkipReason = r.skipReason
+	snapshot.RestoredFrom = r.restoredFrom
+	if r.hasExitCode {
+		exitCode := r.exitCode
+		snapshot.ExitCode = &exitCode
+	}
+	snapshot.LogFile = r.logFile
+	if !r.startTime.IsZero() {
+		startTime := r.startTime
+		snapshot.StartTime = &startTime
+	}
+	if !r.endTime.IsZero() {
+		endTime := r.endTime
+		snapshot.EndTime = &endTime
+	}
+	r.mu.RUnlock()
+	for _, child := range r.Children() {
+		snapshot.Children = append(snapshot.Children, child.Snapshot())
+	}
+	return snapshot
+}
+
 func NewRunnableStatus(name, rType string) *RunnableStatus {
 	return &RunnableStatus{
 		name:     name,
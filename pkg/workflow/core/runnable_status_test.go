@@ -2,8 +2,11 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // Test RunnableStatus struct fields and initialization
@@ -593,6 +596,100 @@ func TestRunnableStatus_EdgeCases(t *testing.T) {
 	})
 }
 
+// Test TimedOut method
+func TestRunnableStatus_TimedOut(t *testing.T) {
+	status := NewRunnableStatus("test", "step")
+	status.Start()
+	status.TimedOut(errors.New("timed out"))
+
+	if status.Status() != TimedOut {
+		t.Errorf("Expected status '%s', got '%s'", TimedOut, status.Status())
+	}
+	if status.Reason() != "timed out" {
+		t.Errorf("Expected reason 'timed out', got '%s'", status.Reason())
+	}
+	if status.FutureStatus() != Fail {
+		t.Errorf("Expected future status '%s', got '%s'", Fail, status.FutureStatus())
+	}
+	status.ContinueOnErr = true
+	if status.FutureStatus() != Pass {
+		t.Errorf("Expected future status '%s' with ContinueOnErr=true, got '%s'", Pass, status.FutureStatus())
+	}
+}
+
+// Test CanContinue method
+func TestRunnableStatus_CanContinue(t *testing.T) {
+	job := NewRunnableStatus("job", "job")
+	step := NewRunnableStatus("step", "step")
+	attempt := NewRunnableStatus("attempt 1", "attempt")
+	job.AddChild(step)
+	step.AddChild(attempt)
+	attempt.Finish(errors.New("attempt failed"))
+	step.Finish(errors.New("step failed"))
+	if job.CanContinue() || !job.Failure() {
+		t.Error("Expected the failed step to stop the job")
+	}
+
+	step.SetContinueOnErr(true)
+	if !job.CanContinue() {
+		t.Error("Expected the job to continue after the step which continues on error")
+	}
+	if !job.Failure() {
+		t.Error("Expected the failed attempt of the step to fail the future status")
+	}
+
+	step.Cancel(errors.New("cancelled"))
+	if job.CanContinue() {
+		t.Error("Expected the cancelled step never to continue")
+	}
+}
+
+// Test Cancel method
+func TestRunnableStatus_Cancel(t *testing.T) {
+	status := NewRunnableStatus("test", "step")
+	status.Start()
+	status.Cancel(errors.New("received interrupt"))
+
+	if status.Status() != Cancelled {
+		t.Errorf("Expected status '%s', got '%s'", Cancelled, status.Status())
+	}
+	if status.Reason() != "received interrupt" {
+		t.Errorf("Expected reason 'received interrupt', got '%s'", status.Reason())
+	}
+	status.ContinueOnErr = true
+	if status.FutureStatus() != Fail {
+		t.Errorf("Expected future status '%s' with ContinueOnErr=true, got '%s'", Fail, status.FutureStatus())
+	}
+	if status.Conclusion() != Cancelled {
+		t.Errorf("Expected conclusion '%s' with ContinueOnErr=true, got '%s'", Cancelled, status.Conclusion())
+	}
+}
+
+// Test concurrent updates of RunnableStatus
+func TestRunnableStatus_Concurrency(t *testing.T) {
+	parent := NewRunnableStatus("parent", "workflow")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			child := NewRunnableStatus(fmt.Sprintf("child-%d", i), "job")
+			parent.AddChild(child)
+			child.Start()
+			child.Finish(errors.New("error"))
+			_ = parent.FutureStatus()
+			_ = parent.Reason()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 50; i++ {
+		if parent.GetChild(fmt.Sprintf("child-%d", i)) == nil {
+			t.Errorf("Expected child-%d to be added", i)
+		}
+	}
+}
+
 // Benchmark tests for RunnableStatus performance
 func BenchmarkRunnableStatus_NewRunnableStatus(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -668,3 +765,50 @@ func BenchmarkRunnableStatus_Reason(b *testing.B) {
 		_ = status.Reason()
 	}
 }
+
+func TestRunnableStatus_Snapshot(t *testing.T) {
+	job := NewRunnableStatus("backup", "job")
+	step := NewRunnableStatus("dump", "step")
+	step.SetLogFile("/tmp/backup/01-dump.log")
+	job.AddChild(step)
+	step.Finish(errors.New("exit status 1"))
+	step.SetContinueOnErr(true)
+	job.Finish()
+
+	snapshot := job.Snapshot()
+	if snapshot.Name != "backup" || snapshot.Type != "job" || snapshot.Status != Pass || len(snapshot.Children) != 1 {
+		t.Fatalf("Unexpected snapshot %+v", snapshot)
+	}
+	child := snapshot.Children[0]
+	if child.Status != Fail || child.Conclusion != Pass || child.LogFile != "/tmp/backup/01-dump.log" {
+		t.Errorf("Unexpected child snapshot %+v", child)
+	}
+	if len(child.Errors) != 1 || child.Errors[0] != "exit status 1" {
+		t.Errorf("Expected the errors of the child, got %v", child.Errors)
+	}
+}
+
+func TestRunnableStatus_Timing(t *testing.T) {
+	status := NewRunnableStatus("dump", "step")
+	if status.Elapsed() != 0 || !status.StartTime().IsZero() {
+		t.Error("Expected no duration before the runnable starts")
+	}
+	status.Start()
+	time.Sleep(5 * time.Millisecond)
+	status.Finish()
+	if status.Elapsed() < 5*time.Millisecond || status.EndTime().Before(status.StartTime()) {
+		t.Errorf("Unexpected duration %v", status.Elapsed())
+	}
+	if status.Duration() != status.Elapsed().Seconds() {
+		t.Errorf("Expected duration in seconds, got %v", status.Duration())
+	}
+
+	skipped := NewRunnableStatus("upload", "step")
+	skipped.SkippedWithReason("a previous step failed")
+	if skipped.Status() != Skipped || skipped.SkipReason() != "a previous step failed" || skipped.Elapsed() != 0 {
+		t.Errorf("Unexpected skipped status %s %s", skipped.Status(), skipped.SkipReason())
+	}
+	if snapshot := skipped.Snapshot(); snapshot.StartTime != nil || snapshot.EndTime == nil {
+		t.Errorf("Expected only the end time of a skipped runnable")
+	}
+}
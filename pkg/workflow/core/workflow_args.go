@@ -6,8 +6,13 @@ type WorkflowArgs struct {
 	File        *string
 	Provider    *string
 	Check       *bool
+	Plan        *bool
 	Usage       *bool
 	PrivateFile *string
+	// Reports are the reports to write in the format "format=path"
+	Reports []string
+	// Resume is the id of the failed run to resume
+	Resume *string
 }
 
 // NewWorkflowArgsFromRunArgs creates WorkflowArgs from cobra RunArgs
@@ -23,11 +28,18 @@ func NewWorkflowArgsFromRunArgs(args *argument.RunArgs) *WorkflowArgs {
 	}
 
 	wa.Check = &args.Check
+	wa.Plan = &args.Plan
 	wa.Usage = &args.Usage
 
 	if args.PrivateFile != "" {
 		wa.PrivateFile = &args.PrivateFile
 	}
 
+	wa.Reports = args.Reports
+
+	if args.Resume != "" {
+		wa.Resume = &args.Resume
+	}
+
 	return wa
 }
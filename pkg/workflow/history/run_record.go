@@ -0,0 +1,150 @@
+package history
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"nadleeh/pkg/common"
+	"nadleeh/pkg/workflow/core"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TriggerEnv is the env which tells what triggered the run, e.g. the schedule of the scheduler which started nadleeh
+const TriggerEnv = "NADLEEH_TRIGGER"
+
+// TriggerPathEnv and TriggerEventEnv are the changed path and its event of the watch which triggered the run
+const (
+	TriggerPathEnv  = "NADLEEH_TRIGGER_PATH"
+	TriggerEventEnv = "NADLEEH_TRIGGER_EVENT"
+)
+
+// RunIdEnv is the env which gives the id of the run, e.g. the webhook server returns the run id before nadleeh starts
+const RunIdEnv = "NADLEEH_RUN_ID"
+
+var runIdPattern = regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{8}$`)
+
+// RunRecord is the record of a workflow run which is saved as json
+type RunRecord struct {
+	Id          string               `json:"id"`
+	Workflow    string               `json:"workflow"`
+	Provider    string               `json:"provider,omitempty"`
+	Sha         string               `json:"sha"`
+	Args        map[string]string    `json:"args,omitempty"`
+	User        string               `json:"user,omitempty"`
+	SudoUser    string               `json:"sudoUser,omitempty"`
+	LogFile     string               `json:"logFile,omitempty"`
+	StartTime   time.Time            `json:"startTime"`
+	EndTime     *time.Time           `json:"endTime,omitempty"`
+	Status      string               `json:"status"`
+	Reason      string               `json:"reason,omitempty"`
+	ResumedFrom string               `json:"resumedFrom,omitempty"`
+	Trigger     string               `json:"trigger,omitempty"`
+	Tree        *core.StatusSnapshot `json:"tree,omitempty"`
+}
+
+// TriggerFromEnv returns the trigger of the run from the envs, the type is manual if nadleeh isn't triggered by the
+// scheduler or the webhook server
+func TriggerFromEnv() map[string]string {
+	trigger := map[string]string{"type": "manual"}
+	if fields := strings.Fields(os.Getenv(TriggerEnv)); len(fields) > 0 {
+		trigger["type"] = fields[0]
+	}
+	if path := os.Getenv(TriggerPathEnv); len(path) > 0 {
+		trigger["path"] = path
+		trigger["event"] = os.Getenv(TriggerEventEnv)
+	}
+	return trigger
+}
+
+// NewRunId returns a run id which sorts by the start time, e.g. 20240102-150405-1a2b3c4d
+func NewRunId(start time.Time) string {
+	return fmt.Sprintf("%s-%s", start.UTC().Format("20060102-150405"), uuid.New().String()[:8])
+}
+
+// IsRunId returns whether the id is a run id created by NewRunId
+func IsRunId(id string) bool {
+	return runIdPattern.MatchString(id)
+}
+
+// runIdFromEnv returns the run id given by RunIdEnv or a new run id. The env is unset, so a workflow which runs
+// nadleeh again doesn't reuse the id.
+func runIdFromEnv(start time.Time) string {
+	id := os.Getenv(RunIdEnv)
+	_ = os.Unsetenv(RunIdEnv)
+	if IsRunId(id) {
+		return id
+	}
+	return NewRunId(start)
+}
+
+// NewRunRecord creates the record of a running workflow, the secret args are masked
+func NewRunRecord(workflow string, content []byte, args map[string]string) *RunRecord {
+	start := time.Now()
+	record := &RunRecord{
+		Id:        runIdFromEnv(start),
+		Workflow:  workflow,
+		Sha:       fmt.Sprintf("%x", sha256.Sum256(content)),
+		Args:      common.MaskSecrets(args),
+		SudoUser:  os.Getenv("SUDO_USER"),
+		LogFile:   os.Getenv("NADLEEH_LOG_FILE"),
+		Trigger:   os.Getenv(TriggerEnv),
+		StartTime: start,
+		Status:    core.Running,
+	}
+	if u, err := user.Current(); err == nil {
+		record.User = u.Username
+	}
+	return record
+}
+
+// Finish records the status tree of the workflow and the end time
+func (r *RunRecord) Finish(status *core.RunnableStatus) {
+	end := time.Now()
+	r.EndTime = &end
+	r.Tree = status.Snapshot()
+	r.Status = r.Tree.Conclusion
+	r.Reason = status.Reason()
+}
+
+// Duration returns how long the workflow ran, it's up to now if the workflow is still running
+func (r *RunRecord) Duration() time.Duration {
+	if r.EndTime == nil {
+		return time.Since(r.StartTime)
+	}
+	return r.EndTime.Sub(r.StartTime)
+}
+
+// StepLog is the log file of a step, the name is the path of the step like backup / archive
+type StepLog struct {
+	Name    string
+	LogFile string
+}
+
+// StepLogs returns the log files of the steps in the run order
+func (r *RunRecord) StepLogs() []StepLog {
+	var logs []StepLog
+	var walk func(snapshot *core.StatusSnapshot, prefix string)
+	walk = func(snapshot *core.StatusSnapshot, prefix string) {
+		name := snapshot.Name
+		if len(prefix) > 0 {
+			name = prefix + " / " + name
+		}
+		if len(snapshot.LogFile) > 0 {
+			logs = append(logs, StepLog{Name: name, LogFile: snapshot.LogFile})
+		}
+		for _, child := range snapshot.Children {
+			walk(child, name)
+		}
+	}
+	if r.Tree != nil {
+		for _, child := range r.Tree.Children {
+			walk(child, "")
+		}
+	}
+	return logs
+}
@@ -0,0 +1,83 @@
+package history
+
+import (
+	"errors"
+	"nadleeh/pkg/common"
+	"nadleeh/pkg/workflow/core"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRunRecord(t *testing.T) {
+	record := NewRunRecord("backup.yml", []byte("name: backup"), map[string]string{"token": "abc"})
+	if !strings.HasPrefix(record.Id, record.StartTime.UTC().Format("20060102-150405")) {
+		t.Errorf("Expected run id to start with the start time, got %s", record.Id)
+	}
+	if len(record.Sha) != 64 {
+		t.Errorf("Expected sha256 of the workflow, got %s", record.Sha)
+	}
+	if record.Status != core.Running || record.EndTime != nil {
+		t.Errorf("Expected a running record, got %s", record.Status)
+	}
+	if record.Args["token"] != common.MaskedValue {
+		t.Errorf("Expected token to be masked, got %s", record.Args["token"])
+	}
+}
+
+func TestNewRunRecord_RunIdEnv(t *testing.T) {
+	id := NewRunId(time.Now())
+	t.Setenv(RunIdEnv, id)
+	if record := NewRunRecord("backup.yml", nil, nil); record.Id != id {
+		t.Errorf("Expected run id %s from the env, got %s", id, record.Id)
+	}
+	if record := NewRunRecord("backup.yml", nil, nil); record.Id == id {
+		t.Errorf("Expected the env run id used only once")
+	}
+
+	t.Setenv(RunIdEnv, "../../etc")
+	if record := NewRunRecord("backup.yml", nil, nil); !IsRunId(record.Id) {
+		t.Errorf("Expected a new run id for an invalid env, got %s", record.Id)
+	}
+}
+
+func TestTriggerFromEnv(t *testing.T) {
+	t.Setenv(TriggerEnv, "")
+	t.Setenv(TriggerPathEnv, "")
+	if trigger := TriggerFromEnv(); len(trigger) != 1 || trigger["type"] != "manual" {
+		t.Errorf("Expected a manual trigger, got %v", trigger)
+	}
+
+	t.Setenv(TriggerEnv, "watch create /uploads/orders.csv")
+	t.Setenv(TriggerPathEnv, "/uploads/orders.csv")
+	t.Setenv(TriggerEventEnv, "create")
+	trigger := TriggerFromEnv()
+	if trigger["type"] != "watch" || trigger["path"] != "/uploads/orders.csv" || trigger["event"] != "create" {
+		t.Errorf("Unexpected watch trigger %v", trigger)
+	}
+}
+
+func TestRunRecord_Finish(t *testing.T) {
+	workflowStatus := core.NewRunnableStatus("backup", "workflow")
+	jobStatus := core.NewRunnableStatus("dump", "job")
+	workflowStatus.AddChild(jobStatus)
+	stepStatus := core.NewRunnableStatus("pg_dump", "step")
+	stepStatus.SetLogFile("/tmp/dump/01-pg_dump.log")
+	jobStatus.AddChild(stepStatus)
+	stepStatus.Finish(errors.New("exit status 1"))
+	jobStatus.Finish(errors.New("step failed"))
+	workflowStatus.Finish(errors.New("job failed"))
+
+	record := NewRunRecord("backup.yml", nil, nil)
+	record.Finish(workflowStatus)
+	if record.Status != core.Fail || record.EndTime == nil {
+		t.Errorf("Expected a failed record, got %s", record.Status)
+	}
+	if !strings.Contains(record.Reason, "exit status 1") {
+		t.Errorf("Expected the reason of the step, got %s", record.Reason)
+	}
+	logs := record.StepLogs()
+	if len(logs) != 1 || logs[0].Name != "dump / pg_dump" || logs[0].LogFile != "/tmp/dump/01-pg_dump.log" {
+		t.Errorf("Unexpected step logs %v", logs)
+	}
+}
@@ -0,0 +1,149 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"nadleeh/internal/argument"
+	"nadleeh/pkg/workflow/core"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HandleRuns runs the runs command against the default store
+func HandleRuns(args *argument.RunsArgs) {
+	store, err := NewDefaultStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch args.Command {
+	case argument.RunsList:
+		err = ListRuns(os.Stdout, store, args.Limit)
+	case argument.RunsShow:
+		err = ShowRun(os.Stdout, store, args.RunId, args.Json)
+	case argument.RunsLogs:
+		err = ShowLogs(os.Stdout, store, args.RunId, args.Step)
+	case argument.RunsPrune:
+		err = PruneRuns(os.Stdout, store, args.Keep, args.OlderThan)
+	default:
+		err = fmt.Errorf("unknown runs command %s", args.Command)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ListRuns writes a table of the latest runs, a limit <= 0 lists all runs
+func ListRuns(w io.Writer, store *Store, limit int) error {
+	records, err := store.List()
+	if err != nil {
+		return err
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tWORKFLOW\tSTATUS\tSTARTED\tDURATION")
+	for _, record := range records {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", record.Id, record.Workflow, record.Status,
+			record.StartTime.Local().Format(time.DateTime), record.Duration().Round(time.Second))
+	}
+	return tw.Flush()
+}
+
+// ShowRun writes the run record and its status tree
+func ShowRun(w io.Writer, store *Store, id string, asJson bool) error {
+	record, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(record)
+	}
+
+	_, _ = fmt.Fprintf(w, "Run:      %s\n", record.Id)
+	_, _ = fmt.Fprintf(w, "Workflow: %s\n", record.Workflow)
+	_, _ = fmt.Fprintf(w, "Sha256:   %s\n", record.Sha)
+	_, _ = fmt.Fprintf(w, "Status:   %s\n", record.Status)
+	if len(record.ResumedFrom) > 0 {
+		_, _ = fmt.Fprintf(w, "Resumed:  %s\n", record.ResumedFrom)
+	}
+	if len(record.Trigger) > 0 {
+		_, _ = fmt.Fprintf(w, "Trigger:  %s\n", record.Trigger)
+	}
+	_, _ = fmt.Fprintf(w, "Started:  %s\n", record.StartTime.Local().Format(time.DateTime))
+	_, _ = fmt.Fprintf(w, "Duration: %s\n", record.Duration().Round(time.Millisecond))
+	if len(record.SudoUser) > 0 {
+		_, _ = fmt.Fprintf(w, "User:     %s (sudo %s)\n", record.User, record.SudoUser)
+	} else if len(record.User) > 0 {
+		_, _ = fmt.Fprintf(w, "User:     %s\n", record.User)
+	}
+	if len(record.LogFile) > 0 {
+		_, _ = fmt.Fprintf(w, "Log:      %s\n", record.LogFile)
+	}
+	for _, key := range slices.Sorted(maps.Keys(record.Args)) {
+		_, _ = fmt.Fprintf(w, "Arg:      %s=%s\n", key, record.Args[key])
+	}
+	if record.Tree != nil {
+		_, _ = fmt.Fprintln(w)
+		writeTree(w, record.Tree, "")
+	}
+	return nil
+}
+
+func writeTree(w io.Writer, snapshot *core.StatusSnapshot, indent string) {
+	status := snapshot.Status
+	if snapshot.Conclusion != snapshot.Status {
+		status = fmt.Sprintf("%s (continue on error)", status)
+	}
+	_, _ = fmt.Fprintf(w, "%s%s %s: %s\n", indent, snapshot.Type, snapshot.Name, status)
+	for _, err := range snapshot.Errors {
+		_, _ = fmt.Fprintf(w, "%s  error: %s\n", indent, err)
+	}
+	for _, child := range snapshot.Children {
+		writeTree(w, child, indent+"  ")
+	}
+}
+
+// ShowLogs writes the step logs of the run, only the steps whose name contains step are written if it's not empty
+func ShowLogs(w io.Writer, store *Store, id string, step string) error {
+	record, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, stepLog := range record.StepLogs() {
+		if len(step) > 0 && !strings.Contains(stepLog.Name, step) {
+			continue
+		}
+		found = true
+		_, _ = fmt.Fprintf(w, "==> %s <==\n", stepLog.Name)
+		data, err := os.ReadFile(stepLog.LogFile)
+		if err != nil {
+			_, _ = fmt.Fprintf(w, "failed to read log %s: %v\n", stepLog.LogFile, err)
+			continue
+		}
+		_, _ = w.Write(data)
+	}
+	if !found {
+		return fmt.Errorf("no step logs found in run %s", record.Id)
+	}
+	return nil
+}
+
+// PruneRuns removes the old runs and writes the removed run ids
+func PruneRuns(w io.Writer, store *Store, keep int, olderThan time.Duration) error {
+	removed, err := store.Prune(keep, olderThan)
+	for _, id := range removed {
+		_, _ = fmt.Fprintf(w, "removed run %s\n", id)
+	}
+	return err
+}
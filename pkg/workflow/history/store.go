@@ -0,0 +1,208 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"nadleeh/pkg/workflow/run_context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	recordFile     = "run.json"
+	checkpointFile = "checkpoint.json"
+	logsDir        = "logs"
+)
+
+// Store saves the run records under a directory, each run has its own directory named by the run id
+type Store struct {
+	Dir string
+}
+
+// DefaultDir returns ~/.nadleeh/runs
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home dir: %w", err)
+	}
+	return filepath.Join(home, ".nadleeh", "runs"), nil
+}
+
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// NewDefaultStore creates the store under ~/.nadleeh/runs
+func NewDefaultStore() (*Store, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(dir), nil
+}
+
+func (s *Store) runDir(id string) string {
+	return filepath.Join(s.Dir, id)
+}
+
+// LogDir returns the directory of the step logs of the run
+func (s *Store) LogDir(id string) string {
+	return filepath.Join(s.runDir(id), logsDir)
+}
+
+// Save writes the run record, the record is written to a temp file first so a reader never sees a partial record
+func (s *Store) Save(record *RunRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run %s: %w", record.Id, err)
+	}
+	if err = s.writeFile(record.Id, recordFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write run %s: %w", record.Id, err)
+	}
+	return nil
+}
+
+// SaveCheckpoint writes the checkpoint of the run, only the owner can read it since the env may hold secrets
+func (s *Store) SaveCheckpoint(checkpoint *run_context.Checkpoint) error {
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint of run %s: %w", checkpoint.RunId, err)
+	}
+	if err = s.writeFile(checkpoint.RunId, checkpointFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write checkpoint of run %s: %w", checkpoint.RunId, err)
+	}
+	return nil
+}
+
+// LoadCheckpoint reads the checkpoint of the run
+func (s *Store) LoadCheckpoint(id string) (*run_context.Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(s.runDir(id), checkpointFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("run %s has no checkpoint", id)
+		}
+		return nil, fmt.Errorf("failed to read checkpoint of run %s: %w", id, err)
+	}
+	var checkpoint run_context.Checkpoint
+	if err = json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint of run %s: %w", id, err)
+	}
+	return &checkpoint, nil
+}
+
+// writeFile writes the file of the run to a temp file first, so a reader never sees a partial file
+func (s *Store) writeFile(id string, name string, data []byte, perm os.FileMode) error {
+	dir := s.runDir(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create run dir %s: %w", dir, err)
+	}
+	tmpFile := filepath.Join(dir, name+".tmp")
+	if err := os.WriteFile(tmpFile, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filepath.Join(dir, name))
+}
+
+// Load reads the run record of the given id, a unique prefix of the id is accepted
+func (s *Store) Load(id string) (*RunRecord, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	var matched []string
+	for _, runId := range ids {
+		if runId == id {
+			matched = []string{runId}
+			break
+		}
+		if strings.HasPrefix(runId, id) {
+			matched = append(matched, runId)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("run %s not found", id)
+	} else if len(matched) > 1 {
+		return nil, fmt.Errorf("run %s is ambiguous, it matches %s", id, strings.Join(matched, ", "))
+	}
+	return s.load(matched[0])
+}
+
+func (s *Store) load(id string) (*RunRecord, error) {
+	data, err := os.ReadFile(filepath.Join(s.runDir(id), recordFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run %s: %w", id, err)
+	}
+	var record RunRecord
+	if err = json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid run %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+// ids returns the ids of the saved runs, the latest run first
+func (s *Store) ids() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read runs dir %s: %w", s.Dir, err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	slices.Sort(ids)
+	slices.Reverse(ids)
+	return ids, nil
+}
+
+// List returns the saved runs, the latest run first. The invalid runs are skipped.
+func (s *Store) List() ([]*RunRecord, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	var records []*RunRecord
+	for _, id := range ids {
+		record, err := s.load(id)
+		if err != nil {
+			log.Warnf("skip run %s: %v", id, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Prune removes the runs which are older than olderThan or beyond the latest keep runs, the runs which are still
+// running are kept. A zero olderThan or a negative keep disables the check. It returns the removed run ids.
+func (s *Store) Prune(keep int, olderThan time.Duration) ([]string, error) {
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for i, record := range records {
+		expired := olderThan > 0 && time.Since(record.StartTime) > olderThan
+		if !expired && (keep < 0 || i < keep) {
+			continue
+		}
+		if record.EndTime == nil {
+			log.Warnf("run %s is still running, keep it", record.Id)
+			continue
+		}
+		if err = os.RemoveAll(s.runDir(record.Id)); err != nil {
+			return removed, fmt.Errorf("failed to remove run %s: %w", record.Id, err)
+		}
+		removed = append(removed, record.Id)
+	}
+	return removed, nil
+}
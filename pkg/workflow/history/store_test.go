@@ -0,0 +1,135 @@
+package history
+
+import (
+	"bytes"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func saveRecord(t *testing.T, store *Store, id string, start time.Time, finished bool) *RunRecord {
+	t.Helper()
+	record := &RunRecord{Id: id, Workflow: "backup.yml", StartTime: start, Status: core.Running}
+	if finished {
+		record.Finish(core.NewRunnableStatus("backup", "workflow"))
+	}
+	if err := store.Save(record); err != nil {
+		t.Fatalf("Failed to save run %s: %v", id, err)
+	}
+	return record
+}
+
+func TestStore_SaveLoad(t *testing.T) {
+	store := NewStore(t.TempDir())
+	saveRecord(t, store, "20240101-000000-aaaa", time.Now(), true)
+	saveRecord(t, store, "20240102-000000-bbbb", time.Now(), true)
+	saveRecord(t, store, "20240102-000000-bbcc", time.Now(), false)
+
+	record, err := store.Load("20240101")
+	if err != nil || record.Id != "20240101-000000-aaaa" {
+		t.Errorf("Expected run by prefix, got %v %v", record, err)
+	}
+	if _, err = store.Load("20240102-000000-bb"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected ambiguous run error, got %v", err)
+	}
+	if _, err = store.Load("2023"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected run not found error, got %v", err)
+	}
+
+	records, err := store.List()
+	if err != nil || len(records) != 3 || records[0].Id != "20240102-000000-bbcc" {
+		t.Errorf("Expected the latest run first, got %v %v", records, err)
+	}
+}
+
+func TestStore_ListEmpty(t *testing.T) {
+	records, err := NewStore(filepath.Join(t.TempDir(), "missing")).List()
+	if err != nil || len(records) != 0 {
+		t.Errorf("Expected no runs, got %v %v", records, err)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	t.Run("Keep", func(t *testing.T) {
+		store := NewStore(t.TempDir())
+		saveRecord(t, store, "20240101-000000-aaaa", time.Now(), true)
+		saveRecord(t, store, "20240102-000000-bbbb", time.Now(), true)
+		saveRecord(t, store, "20240103-000000-cccc", time.Now(), true)
+
+		removed, err := store.Prune(1, 0)
+		if err != nil || len(removed) != 2 {
+			t.Fatalf("Expected 2 runs removed, got %v %v", removed, err)
+		}
+		if _, err = os.Stat(filepath.Join(store.Dir, "20240101-000000-aaaa")); !os.IsNotExist(err) {
+			t.Error("Expected the run dir to be removed")
+		}
+	})
+
+	t.Run("OlderThan", func(t *testing.T) {
+		store := NewStore(t.TempDir())
+		saveRecord(t, store, "20240101-000000-aaaa", time.Now().Add(-48*time.Hour), true)
+		saveRecord(t, store, "20240102-000000-bbbb", time.Now().Add(-48*time.Hour), false)
+		saveRecord(t, store, "20240103-000000-cccc", time.Now(), true)
+
+		removed, err := store.Prune(-1, 24*time.Hour)
+		if err != nil || len(removed) != 1 || removed[0] != "20240101-000000-aaaa" {
+			t.Errorf("Expected only the old finished run removed, got %v %v", removed, err)
+		}
+	})
+}
+
+func TestShowLogs(t *testing.T) {
+	store := NewStore(t.TempDir())
+	logFile := filepath.Join(t.TempDir(), "01-dump.log")
+	if err := os.WriteFile(logFile, []byte("dumped\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workflowStatus := core.NewRunnableStatus("backup", "workflow")
+	jobStatus := core.NewRunnableStatus("db", "job")
+	stepStatus := core.NewRunnableStatus("dump", "step")
+	stepStatus.SetLogFile(logFile)
+	workflowStatus.AddChild(jobStatus)
+	jobStatus.AddChild(stepStatus)
+	record := &RunRecord{Id: "20240101-000000-aaaa", StartTime: time.Now()}
+	record.Finish(workflowStatus)
+	if err := store.Save(record); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := ShowLogs(&out, store, record.Id, ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.String() != "==> db / dump <==\ndumped\n" {
+		t.Errorf("Unexpected logs %q", out.String())
+	}
+	if err := ShowLogs(&out, store, record.Id, "upload"); err == nil {
+		t.Error("Expected no step logs error")
+	}
+}
+
+func TestStore_Checkpoint(t *testing.T) {
+	store := NewStore(t.TempDir())
+	if _, err := store.LoadCheckpoint("20240101-000000-aaaa"); err == nil || !strings.Contains(err.Error(), "no checkpoint") {
+		t.Errorf("Expected no checkpoint error, got %v", err)
+	}
+	checkpoint := run_context.NewCheckpoint("20240101-000000-aaaa")
+	checkpoint.Jobs["dump"] = &run_context.JobCheckpoint{Steps: []*run_context.StepCheckpoint{
+		{Name: "dump", Status: core.Pass, Env: map[string]string{"DUMP_FILE": "/tmp/dump.sql"}},
+	}}
+	if err := store.SaveCheckpoint(checkpoint); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(store.Dir, checkpoint.RunId, checkpointFile))
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected the checkpoint to be readable by the owner only, got %v %v", fi, err)
+	}
+	loaded, err := store.LoadCheckpoint(checkpoint.RunId)
+	if err != nil || loaded.Jobs["dump"].Steps[0].Env["DUMP_FILE"] != "/tmp/dump.sql" {
+		t.Errorf("Expected the saved checkpoint, got %+v %v", loaded, err)
+	}
+}
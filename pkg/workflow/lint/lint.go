@@ -0,0 +1,73 @@
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"nadleeh/internal/argument"
+	"nadleeh/pkg/common"
+	workflow "nadleeh/pkg/workflow/model"
+	"nadleeh/pkg/workflow/run_context"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HandleLint lints the workflow files, it fails if an error is found, or a warning with --strict
+func HandleLint(args *argument.LintArgs) {
+	errCount, warnCount, err := LintFiles(os.Stdout, args.Files)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if errCount > 0 || (args.Strict && warnCount > 0) {
+		log.Fatalf("%d errors and %d warnings found in the workflow files", errCount, warnCount)
+	}
+	if warnCount > 0 {
+		log.Warnf("%d warnings found in the workflow files", warnCount)
+		return
+	}
+	log.Infof("no problem found in the workflow files")
+}
+
+// LintFiles writes the problems of the workflow files, it returns the numbers of the errors and warnings
+func LintFiles(w io.Writer, files []string) (int, int, error) {
+	errCount, warnCount := 0, 0
+	for _, file := range files {
+		problems, err := LintFile(file)
+		if err != nil {
+			return errCount, warnCount, err
+		}
+		for _, problem := range problems {
+			_, _ = fmt.Fprintln(w, problem)
+			if problem.Severity == workflow.LintError {
+				errCount++
+			} else {
+				warnCount++
+			}
+		}
+	}
+	return errCount, warnCount, nil
+}
+
+// LintFile parses and lints the workflow file, the parse errors are returned as the problems. The relative paths of
+// the workflow, e.g. the composite actions, are resolved against its directory like it runs.
+func LintFile(file string) ([]*workflow.LintProblem, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	common.MustSetEnvs(map[string]string{
+		"WORKFLOW_FILE": abs,
+		"WORKFLOW_DIR":  filepath.Dir(abs),
+	})
+	wf, err := workflow.ParseWorkflowFile(file, bytes.NewReader(content))
+	if err != nil {
+		return workflow.ErrorProblems(file, err), nil
+	}
+	return wf.Lint(file, *run_context.NewWorkflowRunContext(nil)), nil
+}
@@ -0,0 +1,39 @@
+package lint
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintFiles(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.yml")
+	_ = os.WriteFile(valid, []byte("jobs:\n  build:\n    steps:\n      - run: echo ${{ env.WORKFLOW_DIR }}\n"), 0644)
+	warned := filepath.Join(dir, "warned.yml")
+	_ = os.WriteFile(warned, []byte("checks:\n  args:\n    - name: host\njobs:\n  build:\n    steps:\n      - run: echo\n"), 0644)
+	invalid := filepath.Join(dir, "invalid.yml")
+	_ = os.WriteFile(invalid, []byte("jobs:\n  build:\n    step: []\n"), 0644)
+
+	var buf bytes.Buffer
+	errCount, warnCount, err := LintFiles(&buf, []string{valid, warned, invalid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errCount != 1 || warnCount != 1 {
+		t.Errorf("Expected 1 error and 1 warning, got %d and %d:\n%s", errCount, warnCount, buf.String())
+	}
+	expected := []string{
+		warned + ":3:7: warning: arg host of checks.args is never used",
+		invalid + ":3:5: error: unknown key step of jobs.build, did you mean steps?",
+	}
+	if output := strings.TrimSpace(buf.String()); output != strings.Join(expected, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(expected, "\n"), output)
+	}
+
+	if _, _, err = LintFiles(&buf, []string{filepath.Join(dir, "missing.yml")}); err == nil {
+		t.Error("Expected the error of the missing file")
+	}
+}
@@ -17,8 +17,8 @@ type BashRunner struct {
 // Compile compiles the bash script
 func (r *BashRunner) Compile(runCtx run_context.WorkflowRunContext) error {
 	err := runCtx.ShellCtx.Compile(r.Script)
-	log.Errorf("shell compile error: %v", err)
 	if err != nil {
+		log.Errorf("shell compile error of step %s: %v", r.Name, err)
 		r.hasError = 1
 	} else {
 		r.hasError = 2
@@ -35,12 +35,21 @@ func (r *BashRunner) Do(parent env.Env, runCtx *run_context.WorkflowRunContext,
 	}
 	bashEnv := env.NewReadWriteEnv(parent, ctx.Args.GetAll())
 
-	retCode, output, err := runCtx.ShellCtx.Run(bashEnv, run, ctx.NeedOutput)
-	return &core.RunnableResult{
+	retCode, output, stepFiles, err := runCtx.ShellCtx.RunStep(ctx.GetContext(), bashEnv, run, ctx.NeedOutput, ctx.Output)
+	result := &core.RunnableResult{
 		Err:        err,
 		ReturnCode: retCode,
 		Output:     output,
 	}
+	if stepFiles != nil {
+		// the parent is a WriteOnParentEnv, the env is written to the job env for the following steps
+		for key, value := range stepFiles.Env {
+			log.Debugf("step %s set env %s", r.Name, key)
+			parent.Set(key, value)
+		}
+		result.Outputs = stepFiles.Outputs
+	}
+	return result
 }
 
 func (r *BashRunner) CanRun() bool {
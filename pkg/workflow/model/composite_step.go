@@ -0,0 +1,235 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"gopkg.in/yaml.v3"
+)
+
+// maxCompositeDepth limits how deep composite steps can be nested, it stops composite steps using each other
+const maxCompositeDepth = 8
+
+// compositeActionFiles are the file names of a composite step in an action directory
+var compositeActionFiles = []string{"action.yml", "action.yaml"}
+
+// CompositeInput defines an input of a composite step
+type CompositeInput struct {
+	Description string
+	Required    bool
+	Default     string
+}
+
+// CompositeAction is a list of steps defined in a local yaml file, a step uses it by uses: ./actions/archive
+type CompositeAction struct {
+	Name        string
+	Description string
+	Inputs      map[string]*CompositeInput
+	Steps       []*Step
+}
+
+// compositeInputs are the inputs a step passes to a composite action, the values are evaluated when each step of the
+// composite action runs. The parent is set when the composite action is used by a step of another composite action.
+type compositeInputs struct {
+	values map[string]string
+	parent *compositeInputs
+}
+
+// eval evaluates the inputs, the inputs of the parent are available as inputs
+func (c *compositeInputs) eval(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) (map[string]string, error) {
+	inputsCtx := *ctx
+	inputsCtx.Inputs = nil
+	if c.parent != nil {
+		parentInputs, err := c.parent.eval(parent, runCtx, ctx)
+		if err != nil {
+			return nil, err
+		}
+		inputsCtx.Inputs = parentInputs
+	}
+	return run_context.InterpretPluginCfg(runCtx, parent, c.values, inputsCtx.GenerateMap())
+}
+
+// resolveCompositeAction returns the file of a local composite action, uses is either the yaml file or a directory
+// with action.yml. A relative path is resolved against WORKFLOW_DIR.
+func resolveCompositeAction(uses string) (string, error) {
+	path := uses
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(os.Getenv("WORKFLOW_DIR"), path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("composite action %s doesn't exist: %w", uses, err)
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	for _, name := range compositeActionFiles {
+		actionFile := filepath.Join(path, name)
+		if _, err = os.Stat(actionFile); err == nil {
+			return actionFile, nil
+		}
+	}
+	return "", fmt.Errorf("no %s found in composite action %s", compositeActionFiles[0], uses)
+}
+
+// loadCompositeAction loads and parses a local composite action
+func loadCompositeAction(uses string) (*CompositeAction, error) {
+	actionFile, err := resolveCompositeAction(uses)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("load composite action %s", actionFile)
+	data, err := os.ReadFile(actionFile)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, yamlError(actionFile, err.Error(), "composite action")
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("composite action %s has no steps", actionFile)
+	}
+	root := doc.Content[0]
+	if errs := checkKnownKeys(actionFile, root, reflect.TypeFor[CompositeAction](), ""); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	var action CompositeAction
+	if err = decodeNode(actionFile, root, &action, "composite action"); err != nil {
+		return nil, err
+	}
+	setStepPositions(actionFile, root, "steps", action.Steps)
+	if len(action.Steps) == 0 {
+		return nil, fmt.Errorf("composite action %s has no steps", actionFile)
+	}
+	var errs []error
+	for _, step := range action.Steps {
+		errs = append(errs, step.loadEnvFiles())
+	}
+	if err = errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// IsComposite returns whether the step uses a local composite action
+func (step *Step) IsComposite() bool {
+	return step.RequirePlugin() && isLocalUses(step.Uses)
+}
+
+// inputs returns the inputs of the composite action, the defaults are used for the inputs not set by the step
+func (action *CompositeAction) inputs(step *Step) (map[string]string, error) {
+	var errs []error
+	for key := range step.With {
+		if _, ok := action.Inputs[key]; !ok {
+			errs = append(errs, fmt.Errorf("unknown input %s of step %s", key, step.Name))
+		}
+	}
+	values := make(map[string]string, len(action.Inputs))
+	for key, input := range action.Inputs {
+		if value, ok := step.With[key]; ok {
+			values[key] = value
+		} else if input != nil && input.Required && len(input.Default) == 0 {
+			errs = append(errs, fmt.Errorf("input %s is required by step %s", key, step.Name))
+		} else if input != nil {
+			values[key] = input.Default
+		} else {
+			values[key] = ""
+		}
+	}
+	return values, errors.Join(errs...)
+}
+
+// expand returns the steps of the composite action used by the step. The steps are named after the step like
+// archive / pack, they inherit the env, timeout and continue-on-error of the step unless they define their own.
+func (step *Step) expand(prefix string, parent *compositeInputs, depth int) ([]*Step, error) {
+	if depth >= maxCompositeDepth {
+		return nil, fmt.Errorf("step %s uses composite action %s nested more than %d levels, check if composite actions use each other",
+			step.Name, step.Uses, maxCompositeDepth)
+	}
+	if len(step.Id) > 0 || step.HasIf() || step.HasRetry() {
+		return nil, fmt.Errorf("id, if and retry are not supported by step %s which uses composite action %s", step.Name, step.Uses)
+	}
+	action, err := loadCompositeAction(step.Uses)
+	if err != nil {
+		return nil, fmt.Errorf("invalid composite action of step %s: %w", step.Name, err)
+	}
+	values, err := action.inputs(step)
+	if err != nil {
+		return nil, err
+	}
+	inputs := &compositeInputs{values: values, parent: parent}
+
+	name := step.Name
+	if len(name) == 0 {
+		name = action.Name
+	}
+	if len(name) == 0 {
+		name = step.Uses
+	}
+	prefix += name
+	var steps []*Step
+	for i, actionStep := range action.Steps {
+		for key, value := range step.Env {
+			if _, ok := actionStep.Env[key]; !ok {
+				if actionStep.Env == nil {
+					actionStep.Env = make(map[string]string)
+				}
+				actionStep.Env[key] = value
+			}
+		}
+		if actionStep.TimeoutMinutes <= 0 {
+			actionStep.TimeoutMinutes = step.TimeoutMinutes
+		}
+		if !actionStep.HasContinueOnError() {
+			actionStep.ContinueOnError = step.ContinueOnError
+		}
+
+		if !actionStep.IsComposite() {
+			if len(actionStep.Name) == 0 {
+				actionStep.Name = fmt.Sprintf("step %d", i+1)
+			}
+			actionStep.Name = fmt.Sprintf("%s / %s", prefix, actionStep.Name)
+			actionStep.inputs = inputs
+			steps = append(steps, actionStep)
+			continue
+		}
+		nested, err := actionStep.expand(prefix+" / ", inputs, depth+1)
+		if err != nil {
+			return nil, actionStep.pos.of("uses").wrap(err)
+		}
+		steps = append(steps, nested...)
+	}
+	log.Debugf("step %s expanded to %d steps of composite action %s", step.Name, len(steps), step.Uses)
+	return steps, nil
+}
+
+// expandCompositeSteps replaces the steps which use composite actions with the steps of the composite actions
+func expandCompositeSteps(steps []*Step) ([]*Step, error) {
+	if !slices.ContainsFunc(steps, (*Step).IsComposite) {
+		return steps, nil
+	}
+	var expanded []*Step
+	var errs []error
+	for _, step := range steps {
+		if !step.IsComposite() {
+			expanded = append(expanded, step)
+			continue
+		}
+		actionSteps, err := step.expand("", nil, 0)
+		if err != nil {
+			errs = append(errs, step.pos.of("uses").wrap(err))
+			continue
+		}
+		expanded = append(expanded, actionSteps...)
+	}
+	return expanded, errors.Join(errs...)
+}
@@ -0,0 +1,186 @@
+package workflow
+
+import (
+	"nadleeh/pkg/workflow/core"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCompositeAction(t *testing.T, dir, name, yml string) {
+	t.Helper()
+	actionDir := filepath.Join(dir, "actions", name)
+	if err := os.MkdirAll(actionDir, 0755); err != nil {
+		t.Fatalf("Failed to create action dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(actionDir, "action.yml"), []byte(yml), 0644); err != nil {
+		t.Fatalf("Failed to write action %s: %v", name, err)
+	}
+}
+
+func parseCompositeJob(t *testing.T, yml string) (*Job, error) {
+	t.Helper()
+	wf, err := ParseWorkflow(strings.NewReader(yml))
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	job := wf.Jobs[0]
+	return job, job.Precheck()
+}
+
+func TestJob_CompositeSteps(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WORKFLOW_DIR", dir)
+	writeCompositeAction(t, dir, "archive", `
+name: archive
+inputs:
+  source:
+    required: true
+  format:
+    default: tar
+steps:
+  - name: pack
+    id: pack
+    run: echo "file=${{ inputs.source }}.${{ inputs.format }}" >> "$NADLEEH_OUTPUT"
+  - name: check
+    script: |
+      if (inputs.format !== "tgz") { throw new Error("unexpected format " + inputs.format) }
+`)
+	writeCompositeAction(t, dir, "backup", `
+inputs:
+  db:
+    required: true
+steps:
+  - uses: ./actions/archive
+    with:
+      source: /backup/${{ inputs.db }}
+      format: tgz
+`)
+
+	t.Run("Expand", func(t *testing.T) {
+		job, err := parseCompositeJob(t, `
+name: composite
+jobs:
+  backup:
+    steps:
+      - name: archive data
+        uses: ./actions/archive
+        env:
+          LEVEL: "9"
+        with:
+          source: ${{ "/data" }}
+          format: tgz
+      - run: test "${{ steps.pack.outputs.file }}" = "/data.tgz"
+`)
+		if err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		if len(job.Steps) != 3 {
+			t.Fatalf("Expected 3 steps, got %d", len(job.Steps))
+		}
+		if job.Steps[0].Name != "archive data / pack" || job.Steps[0].Env["LEVEL"] != "9" {
+			t.Errorf("Unexpected expanded step %s %v", job.Steps[0].Name, job.Steps[0].Env)
+		}
+
+		ctx := createTestJobRunnableContext()
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		if result.ReturnCode != 0 {
+			t.Fatalf("Expected job to pass, got %v", result.Err)
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		job, err := parseCompositeJob(t, `
+name: composite
+jobs:
+  backup:
+    steps:
+      - name: backup
+        uses: ./actions/backup
+        with:
+          db: postgres
+      - run: test "${{ steps.pack.outputs.file }}" = "/backup/postgres.tgz"
+`)
+		if err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		if job.Steps[0].Name != "backup / archive / pack" {
+			t.Errorf("Unexpected nested step name %s", job.Steps[0].Name)
+		}
+		ctx := createTestJobRunnableContext()
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		if result.ReturnCode != 0 {
+			t.Fatalf("Expected job to pass, got %v", result.Err)
+		}
+	})
+
+	t.Run("InvalidInputs", func(t *testing.T) {
+		_, err := parseCompositeJob(t, `
+name: composite
+jobs:
+  backup:
+    steps:
+      - uses: ./actions/archive
+        with:
+          level: "9"
+`)
+		if err == nil || !strings.Contains(err.Error(), "unknown input level") || !strings.Contains(err.Error(), "input source is required") {
+			t.Errorf("Expected input errors, got %v", err)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := parseCompositeJob(t, `
+name: composite
+jobs:
+  backup:
+    steps:
+      - uses: ./actions/missing
+`)
+		if err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+			t.Errorf("Expected missing action error, got %v", err)
+		}
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		writeCompositeAction(t, dir, "loop", `
+steps:
+  - uses: ./actions/loop
+`)
+		_, err := parseCompositeJob(t, `
+name: composite
+jobs:
+  backup:
+    steps:
+      - uses: ./actions/loop
+`)
+		if err == nil || !strings.Contains(err.Error(), "nested more than") {
+			t.Errorf("Expected nested action error, got %v", err)
+		}
+	})
+
+	t.Run("PostSteps", func(t *testing.T) {
+		job, err := parseCompositeJob(t, `
+name: composite
+jobs:
+  backup:
+    steps:
+      - script: throw new Error("boom")
+    post:
+      - uses: ./actions/archive
+        with:
+          source: /data
+          format: tgz
+`)
+		if err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		ctx := createTestJobRunnableContext()
+		job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		check := ctx.WorkflowStatus.GetChild("backup").GetChild("archive / check")
+		if check == nil || check.Status() != core.Pass {
+			t.Errorf("Expected post steps of the composite action to run")
+		}
+	})
+}
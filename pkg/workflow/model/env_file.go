@@ -0,0 +1,361 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"nadleeh/pkg/util"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile is a dotenv file of env-files, it's either the path or a mapping of the path and optional
+type EnvFile struct {
+	Path string `yaml:"path"`
+	// Optional files are skipped if they don't exist
+	Optional bool `yaml:"optional"`
+}
+
+// envFileDefinition is the mapping form of EnvFile, it's decoded without UnmarshalYAML
+type envFileDefinition EnvFile
+
+func (f *EnvFile) UnmarshalYAML(node *yaml.Node) error {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yaml.ScalarNode:
+		f.Path = node.Value
+		return nil
+	case yaml.MappingNode:
+	default:
+		return &yaml.TypeError{Errors: []string{
+			fmt.Sprintf("line %d: env file must be a path or a mapping of path and optional", node.Line)}}
+	}
+	// the keys are checked here since checkKnownKeys skips the types which unmarshal themselves
+	var messages []string
+	fields := yamlFields(reflect.TypeFor[envFileDefinition]())
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i]; fields[key.Value] == nil {
+			messages = append(messages, fmt.Sprintf("line %d: %v", key.Line,
+				unknownKeyError(key.Value, "env-files", fields)))
+		}
+	}
+	if len(messages) > 0 {
+		return &yaml.TypeError{Errors: messages}
+	}
+	var definition envFileDefinition
+	if err := node.Decode(&definition); err != nil {
+		return err
+	}
+	if len(definition.Path) == 0 {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: path of the env file is required", node.Line)}}
+	}
+	*f = EnvFile(definition)
+	return nil
+}
+
+// resolve returns the path of the env file, a relative path is looked up in the current dir, WORKFLOW_DIR and
+// ~/.nadleeh in order. The path is empty if the file is optional and not found.
+func (f EnvFile) resolve() (string, error) {
+	candidates := []string{f.Path}
+	if !filepath.IsAbs(f.Path) {
+		if workflowDir := os.Getenv("WORKFLOW_DIR"); len(workflowDir) > 0 {
+			candidates = append(candidates, filepath.Join(workflowDir, f.Path))
+		}
+		candidates = append(candidates, filepath.Join(os.Getenv("HOME"), ".nadleeh", f.Path))
+	}
+	for _, candidate := range candidates {
+		log.Debugf("check env file: %s", candidate)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if f.Optional {
+		log.Debugf("optional env file %s does not exist", f.Path)
+		return "", nil
+	}
+	return "", fmt.Errorf("env file %s does not exist, it's looked up in %s", f.Path, strings.Join(candidates, ", "))
+}
+
+// parseEnv adds the envs of the env files to the env, the envs of a file override the env and the former files. A
+// value may refer to the envs of the former lines and files, the env and the OS envs in order.
+func parseEnv(env map[string]string, envFiles []EnvFile) (map[string]string, error) {
+	if env == nil {
+		env = make(map[string]string)
+	}
+	// env holds the envs of the former lines and files since they override it
+	lookup := func(key string) (string, bool) {
+		if value, ok := env[key]; ok {
+			return value, true
+		}
+		return os.LookupEnv(key)
+	}
+	for _, envFile := range envFiles {
+		path, err := envFile.resolve()
+		if err != nil {
+			return nil, err
+		} else if len(path) == 0 {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("parsing env file: %s", path)
+		parser := &dotenvParser{file: path, content: []rune(string(content)), line: 1, lookup: lookup}
+		err = parser.parse(func(key string, value string) {
+			if util.HasKey(env, key) {
+				log.Warnf("override env key %s from file %s", key, path)
+			}
+			env[key] = value
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// dotenvParser parses the KEY=VALUE lines of a dotenv file. The lines may start with export, the lines starting with #
+// or // are comments. An unquoted value ends at the line end or at an inline comment, a trailing backslash joins the
+// next line. A single quoted value is literal, a double quoted value may have escapes like \n. Both quoted values may
+// span lines. $NAME, ${NAME} and ${NAME:-default} are interpolated in the unquoted and double quoted values, ${{ }}
+// expressions are kept for the workflow.
+type dotenvParser struct {
+	file    string
+	content []rune
+	pos     int
+	line    int
+	lookup  func(key string) (string, bool)
+}
+
+// parse calls set with the key and value of each line, the values set must be found by lookup of the later lines
+func (p *dotenvParser) parse(set func(key string, value string)) error {
+	for {
+		p.skip(" \t\r\n")
+		if p.pos >= len(p.content) {
+			return nil
+		}
+		if p.peek() == '#' || strings.HasPrefix(string(p.content[p.pos:min(p.pos+2, len(p.content))]), "//") {
+			p.skipLine()
+			continue
+		}
+		line := p.line
+		key, ok := p.readKey()
+		if !ok {
+			return fmt.Errorf("env file %s line %d is not valid, it must be KEY=VALUE", p.file, line)
+		}
+		value, err := p.readValue()
+		if err != nil {
+			return fmt.Errorf("env file %s line %d of key %s is not valid: %w", p.file, line, key, err)
+		}
+		set(key, value)
+	}
+}
+
+// readKey reads the key and the = after it, the export prefix is skipped
+func (p *dotenvParser) readKey() (string, bool) {
+	start := p.pos
+	for p.pos < len(p.content) && p.peek() != '=' && p.peek() != '\n' {
+		p.pos++
+	}
+	if p.pos >= len(p.content) || p.peek() != '=' {
+		return "", false
+	}
+	key := strings.TrimSpace(string(p.content[start:p.pos]))
+	p.pos++
+	if name, ok := strings.CutPrefix(key, "export "); ok {
+		key = strings.TrimSpace(name)
+	}
+	if len(key) == 0 || strings.ContainsAny(key, " \t'\"$") {
+		return "", false
+	}
+	return key, true
+}
+
+func (p *dotenvParser) readValue() (string, error) {
+	p.skip(" \t")
+	if p.pos >= len(p.content) {
+		return "", nil
+	}
+	var value string
+	var err error
+	switch p.peek() {
+	case '\'':
+		value, err = p.readSingleQuoted()
+	case '"':
+		value, err = p.readDoubleQuoted()
+	default:
+		return p.readUnquoted(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// only an inline comment may follow the quoted value
+	p.skip(" \t\r")
+	if p.pos < len(p.content) && p.peek() != '\n' && p.peek() != '#' {
+		return "", fmt.Errorf("unexpected %q after the quoted value", p.peek())
+	}
+	p.skipLine()
+	return value, nil
+}
+
+func (p *dotenvParser) readSingleQuoted() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for ; p.pos < len(p.content); p.pos++ {
+		c := p.peek()
+		if c == '\'' {
+			p.pos++
+			return sb.String(), nil
+		}
+		if c == '\n' {
+			p.line++
+		}
+		sb.WriteRune(c)
+	}
+	return "", errors.New("unterminated single quote")
+}
+
+func (p *dotenvParser) readDoubleQuoted() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.content) {
+		c := p.peek()
+		switch {
+		case c == '"':
+			p.pos++
+			return sb.String(), nil
+		case c == '\\' && p.pos+1 < len(p.content):
+			p.pos++
+			switch escaped := p.peek(); escaped {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			case 'r':
+				sb.WriteRune('\r')
+			case '"', '\\', '$':
+				sb.WriteRune(escaped)
+			case '\n':
+				// an escaped line end joins the next line
+				p.line++
+			default:
+				sb.WriteRune('\\')
+				sb.WriteRune(escaped)
+			}
+			p.pos++
+		case c == '$':
+			sb.WriteString(p.readVariable())
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			sb.WriteRune(c)
+			p.pos++
+		}
+	}
+	return "", errors.New("unterminated double quote")
+}
+
+// readUnquoted reads the value to the line end or the inline comment, the value is trimmed
+func (p *dotenvParser) readUnquoted() string {
+	var sb strings.Builder
+	for p.pos < len(p.content) {
+		c := p.peek()
+		switch {
+		case c == '\n':
+			p.pos++
+			p.line++
+			return strings.TrimSpace(sb.String())
+		case c == '#' && (sb.Len() == 0 || strings.ContainsRune(" \t", p.content[p.pos-1])):
+			p.skipLine()
+			return strings.TrimSpace(sb.String())
+		case c == '\\' && p.pos+1 < len(p.content) && p.content[p.pos+1] == '\n':
+			p.pos += 2
+			p.line++
+		case c == '\\' && p.pos+1 < len(p.content) && p.content[p.pos+1] == '$':
+			sb.WriteRune('$')
+			p.pos += 2
+		case c == '$':
+			sb.WriteString(p.readVariable())
+		default:
+			sb.WriteRune(c)
+			p.pos++
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// readVariable reads $NAME, ${NAME} or ${NAME:-default} and returns its value, a missing variable is empty. A $ which
+// doesn't start a variable, e.g. of a ${{ }} expression, is kept.
+func (p *dotenvParser) readVariable() string {
+	p.pos++
+	if p.pos < len(p.content) && p.peek() == '{' {
+		end := p.pos + 1
+		for end < len(p.content) && p.content[end] != '}' && p.content[end] != '\n' {
+			end++
+		}
+		body := string(p.content[p.pos+1 : min(end, len(p.content))])
+		name, fallback, hasFallback := strings.Cut(body, ":-")
+		if end >= len(p.content) || p.content[end] != '}' || !isEnvName(name) {
+			return "$"
+		}
+		p.pos = end + 1
+		if value, ok := p.lookup(name); ok && (len(value) > 0 || !hasFallback) {
+			return value
+		}
+		return fallback
+	}
+	end := p.pos
+	for end < len(p.content) && isEnvNameRune(p.content[end], end == p.pos) {
+		end++
+	}
+	if end == p.pos {
+		return "$"
+	}
+	name := string(p.content[p.pos:end])
+	p.pos = end
+	value, _ := p.lookup(name)
+	return value
+}
+
+func (p *dotenvParser) peek() rune {
+	return p.content[p.pos]
+}
+
+func (p *dotenvParser) skip(chars string) {
+	for p.pos < len(p.content) && strings.ContainsRune(chars, p.peek()) {
+		if p.peek() == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) skipLine() {
+	for p.pos < len(p.content) && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+func isEnvName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i, c := range name {
+		if !isEnvNameRune(c, i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isEnvNameRune(c rune, first bool) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (!first && c >= '0' && c <= '9')
+}
@@ -0,0 +1,198 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnv_Dotenv(t *testing.T) {
+	t.Setenv("NADLEEH_TEST_HOST", "example.com")
+	envFile := filepath.Join(t.TempDir(), "test.env")
+	content := `export USER_NAME=admin
+PLAIN = value with spaces   # inline comment
+HASH=abc#def
+SINGLE='literal $USER_NAME # not a comment'
+DOUBLE="line1\nline2\t\"quoted\" \$HOME"
+MULTI="first
+second"
+JOINED=one \
+two
+URL=https://${NADLEEH_TEST_HOST}/$USER_NAME
+FALLBACK=${MISSING:-default}
+MISSING_REF=[$MISSING]
+EXPRESSION=${{ args.name }}
+EMPTY=
+`
+	if err := os.WriteFile(envFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env, err := parseEnv(map[string]string{"PLAIN": "from env"}, []EnvFile{{Path: envFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"USER_NAME":   "admin",
+		"PLAIN":       "value with spaces",
+		"HASH":        "abc#def",
+		"SINGLE":      "literal $USER_NAME # not a comment",
+		"DOUBLE":      "line1\nline2\t\"quoted\" $HOME",
+		"MULTI":       "first\nsecond",
+		"JOINED":      "one two",
+		"URL":         "https://example.com/admin",
+		"FALLBACK":    "default",
+		"MISSING_REF": "[]",
+		"EXPRESSION":  "${{ args.name }}",
+		"EMPTY":       "",
+	}
+	if len(env) != len(expected) {
+		t.Errorf("expected %d envs, got %v", len(expected), env)
+	}
+	for key, value := range expected {
+		if env[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, env[key])
+		}
+	}
+}
+
+func TestParseEnv_Errors(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"KEY=\"unterminated\n", "line 1 of key KEY is not valid: unterminated double quote"},
+		{"A=1\nKEY='unterminated", "line 2 of key KEY is not valid: unterminated single quote"},
+		{"KEY=\"value\" trailing", "line 1 of key KEY is not valid: unexpected 't' after the quoted value"},
+		{"MULTI='a\nb'\nNO_EQUALS", "line 3 is not valid, it must be KEY=VALUE"},
+		{"BAD KEY=value", "line 1 is not valid, it must be KEY=VALUE"},
+	}
+	for _, test := range tests {
+		envFile := filepath.Join(t.TempDir(), "invalid.env")
+		if err := os.WriteFile(envFile, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := parseEnv(nil, []EnvFile{{Path: envFile}})
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected %q for %q, got %v", test.expected, test.content, err)
+		}
+	}
+}
+
+func TestParseEnv_Interpolation(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.env"), filepath.Join(dir, "second.env")
+	if err := os.WriteFile(first, []byte("BASE=/opt/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("DATA=${BASE}/data\nLOGS=\"$DATA/logs\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env, err := parseEnv(map[string]string{"BASE": "inline"}, []EnvFile{{Path: first}, {Path: second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env["DATA"] != "/opt/app/data" || env["LOGS"] != "/opt/app/data/logs" {
+		t.Errorf("expected the values of the former files and lines, got %v", env)
+	}
+
+	t.Run("InlineEnv", func(t *testing.T) {
+		t.Setenv("APP_HOME", "/from/os")
+		if err := os.WriteFile(first, []byte("APP_DATA=${APP_HOME:-/opt}/data\nAPP_LOGS=$APP_LOGS_DIR\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		env, err := parseEnv(map[string]string{"APP_HOME": "/srv/app", "APP_LOGS_DIR": "/var/log/app"}, []EnvFile{{Path: first}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if env["APP_DATA"] != "/srv/app/data" || env["APP_LOGS"] != "/var/log/app" {
+			t.Errorf("expected the inline envs before the OS envs, got %v", env)
+		}
+	})
+}
+
+func TestEnvFile_resolve(t *testing.T) {
+	home, workflowDir := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("WORKFLOW_DIR", workflowDir)
+	if err := os.MkdirAll(filepath.Join(home, ".nadleeh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{filepath.Join(workflowDir, "both.env"), filepath.Join(home, ".nadleeh", "both.env"),
+		filepath.Join(home, ".nadleeh", "home.env")} {
+		if err := os.WriteFile(file, []byte("KEY=value\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := EnvFile{Path: "both.env"}.resolve()
+	if err != nil || path != filepath.Join(workflowDir, "both.env") {
+		t.Errorf("expected the env file of WORKFLOW_DIR first, got %s, %v", path, err)
+	}
+	path, err = EnvFile{Path: "home.env"}.resolve()
+	if err != nil || path != filepath.Join(home, ".nadleeh", "home.env") {
+		t.Errorf("expected the env file of ~/.nadleeh, got %s, %v", path, err)
+	}
+	path, err = EnvFile{Path: "missing.env", Optional: true}.resolve()
+	if err != nil || len(path) > 0 {
+		t.Errorf("expected the optional env file to be skipped, got %s, %v", path, err)
+	}
+	if _, err = (EnvFile{Path: "missing.env"}).resolve(); err == nil {
+		t.Error("expected the missing env file to fail")
+	}
+}
+
+func TestParseWorkflowFile_EnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WORKFLOW_DIR", dir)
+	for name, content := range map[string]string{"workflow.env": "LEVEL=workflow\n", "job.env": "LEVEL=job\n",
+		"step.env": "LEVEL=step\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := `env-files:
+  - workflow.env
+  - path: missing.env
+    optional: true
+jobs:
+  build:
+    env-files: [job.env]
+    steps:
+      - run: echo $LEVEL
+        env-files:
+          - path: step.env
+`
+	wf, err := ParseWorkflowFile("wf.yml", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wf.Env["LEVEL"] != "workflow" || wf.Jobs[0].Env["LEVEL"] != "job" || wf.Jobs[0].Steps[0].Env["LEVEL"] != "step" {
+		t.Errorf("expected the env files of the workflow, job and step, got %v, %v, %v", wf.Env, wf.Jobs[0].Env,
+			wf.Jobs[0].Steps[0].Env)
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		content := `jobs:
+  build:
+    steps:
+      - run: echo
+        env-files:
+          - pth: step.env
+      - name: missing
+        run: echo
+        env-files: [missing.env]
+`
+		_, err := ParseWorkflowFile("wf.yml", strings.NewReader(content))
+		if err == nil || !strings.Contains(err.Error(), "wf.yml:6: failed to parse job build: unknown key pth of env-files, did you mean path?") {
+			t.Errorf("expected the unknown key at its line, got %v", err)
+		}
+
+		content = strings.Replace(content, "pth", "path", 1)
+		_, err = ParseWorkflowFile("wf.yml", strings.NewReader(content))
+		if err == nil || !strings.Contains(err.Error(), "wf.yml:9:9: invalid env-files of step missing: env file missing.env does not exist") {
+			t.Errorf("expected the missing env file at its position, got %v", err)
+		}
+	})
+}
@@ -1,29 +1,98 @@
 package workflow
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"nadleeh/pkg/workflow/core"
 	"nadleeh/pkg/workflow/run_context"
+	"slices"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zhaojunlucky/golib/pkg/env"
+	"gopkg.in/yaml.v3"
 )
 
 type Job struct {
-	Name  string
-	Steps []*Step
-	Env   map[string]string
+	Name            string
+	Steps           []*Step
+	Post            []*Step
+	Env             map[string]string
+	EnvFiles        []EnvFile `yaml:"env-files"`
+	Needs           []string
+	TimeoutMinutes  float64 `yaml:"timeout-minutes"`
+	Strategy        *JobStrategy
+	If              string
+	ContinueOnError string `yaml:"continue-on-error"`
+	// Uses is the reusable workflow the job calls instead of running steps
+	Uses    string
+	With    map[string]string
+	Outputs map[string]string
+	// Matrix is the matrix combination of a job instance
+	Matrix map[string]any `yaml:"-"`
+
+	matrixName string
+	workflow   *Workflow
+	depth      int
+	pos        positions
+}
+
+// setPositions records the positions of the job and its steps, the job is at its key node if it's not nil
+func (job *Job) setPositions(file string, key, node *yaml.Node) {
+	if node == nil {
+		return
+	}
+	job.pos = newPositions(file, key, node)
+	setStepPositions(file, node, "steps", job.Steps)
+	setStepPositions(file, node, "post", job.Post)
+}
+
+// loadEnvFiles adds the envs of the env files of the job and its steps to their env
+func (job *Job) loadEnvFiles() error {
+	var errs []error
+	if len(job.EnvFiles) > 0 {
+		env, err := parseEnv(job.Env, job.EnvFiles)
+		if err != nil {
+			errs = append(errs, job.pos.of("env-files").wrap(fmt.Errorf("invalid env-files of job %s: %w", job.Name, err)))
+		} else {
+			job.Env = env
+		}
+	}
+	for _, step := range slices.Concat(job.Steps, job.Post) {
+		errs = append(errs, step.loadEnvFiles())
+	}
+	return errors.Join(errs...)
 }
 
 // Precheck validates the job definition
 func (job *Job) Precheck() error {
+	if job.UsesWorkflow() {
+		return job.pos.of("uses").wrap(job.precheckWorkflow())
+	}
 	var jobErrors []error
+	stepIds := make(map[string]bool)
 
-	for _, step := range job.Steps {
+	var err error
+	if job.Steps, err = expandCompositeSteps(job.Steps); err != nil {
+		jobErrors = append(jobErrors, err)
+	}
+	if job.Post, err = expandCompositeSteps(job.Post); err != nil {
+		jobErrors = append(jobErrors, err)
+	}
+	for _, step := range job.Post {
+		step.post = true
+	}
+	for _, step := range job.allSteps() {
 		err := step.Precheck()
 		if err != nil {
 			jobErrors = append(jobErrors, err)
 		}
+		if len(step.Id) > 0 {
+			if stepIds[step.Id] {
+				jobErrors = append(jobErrors, step.pos.of("id").wrap(fmt.Errorf("duplicate step id %s in job %s", step.Id, job.Name)))
+			}
+			stepIds[step.Id] = true
+		}
 	}
 
 	if len(jobErrors) > 0 {
@@ -35,7 +104,13 @@ func (job *Job) Precheck() error {
 func (job *Job) PreflightCheck(parent env.Env, args env.Env, runCtx *run_context.WorkflowRunContext) error {
 	var errs []error
 
-	for _, step := range job.Steps {
+	if job.workflow != nil {
+		// the args and envs of the reusable workflow are checked when it's called
+		for _, nested := range job.workflow.allJobs() {
+			errs = append(errs, nested.PreflightCheck(parent, args, runCtx))
+		}
+	}
+	for _, step := range job.allSteps() {
 		err := step.PreflightCheck(parent, args, runCtx)
 		if err != nil {
 			errs = append(errs, err)
@@ -51,54 +126,252 @@ func (job *Job) PreflightCheck(parent env.Env, args env.Env, runCtx *run_context
 
 // Compile compiles the workflow
 func (job *Job) Compile(ctx run_context.WorkflowRunContext) error {
-	var errs []error
+	errs := []error{job.pos.compileExpressions(ctx, job.expressionValues())}
 
-	for _, step := range job.Steps {
-		errs = append(errs, step.Compile(ctx))
+	if job.workflow != nil {
+		return errors.Join(append(errs, job.workflow.Compile(ctx))...)
 	}
-	if len(errs) > 0 {
-		return errors.Join(errs...)
+	for _, step := range job.allSteps() {
+		errs = append(errs, step.Compile(ctx))
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// expressionValues returns the values of the job which may have expressions by their keys
+func (job *Job) expressionValues() map[string]string {
+	values := map[string]string{"if": job.If, "continue-on-error": job.ContinueOnError}
+	values = expressionValues(values, "env", job.Env)
+	values = expressionValues(values, "with", job.With)
+	return expressionValues(values, "outputs", job.Outputs)
 }
 
 func (job *Job) HasSteps() bool {
 	return len(job.Steps) > 0
 }
 
-func (job *Job) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) *core.RunnableResult {
+func (job *Job) HasIf() bool {
+	return len(job.If) > 0
+}
+
+func (job *Job) HasContinueOnError() bool {
+	return len(job.ContinueOnError) > 0
+}
+
+// allSteps returns the steps followed by the post steps
+func (job *Job) allSteps() []*Step {
+	return append(append([]*Step{}, job.Steps...), job.Post...)
+}
+
+// IsMatrixInstance returns whether the job is an instance of a matrix job
+func (job *Job) IsMatrixInstance() bool {
+	return len(job.matrixName) > 0
+}
+
+func (job *Job) skip(ctx *core.RunnableContext, reason string) {
+	jobStatus := core.NewRunnableStatus(job.Name, "job")
+	ctx.WorkflowStatus.AddChild(jobStatus)
+	jobStatus.SkippedWithReason(reason)
+	ctx.Jobs.Record(job.Name, jobStatus, nil)
+}
+
+func (job *Job) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) (result *core.RunnableResult) {
 	log.Infof("Run job: %s", job.Name)
 	jobStatus := core.NewRunnableStatus(job.Name, "job")
 	ctx.WorkflowStatus.AddChild(jobStatus)
-	ctx.JobStatus = jobStatus
+	defer func() {
+		if result != nil {
+			ctx.Jobs.Record(job.Name, jobStatus, result.Outputs)
+			job.checkpointer(runCtx).RecordJob(job.Name, jobStatus.Status() == core.Pass, result.Outputs)
+		}
+	}()
+	if restored, ok := job.restore(runCtx, jobStatus); ok {
+		return restored
+	}
+	// jobs may run concurrently, each job gets its own copy of the runnable context
+	jobCtx := *ctx
+	jobCtx.JobStatus = jobStatus
+	jobCtx.Steps = core.NewOutputsContext()
+	jobCtx.Matrix = job.Matrix
+
+	if job.HasIf() {
+		ifVal, err := job.evalIf(runCtx, parent, &jobCtx)
+		if err != nil {
+			log.Errorf("failed to eval if for job %s", job.Name)
+			jobStatus.Finish(err)
+			return core.NewRunnable(err, -1, err.Error())
+		} else if !ifVal {
+			log.Warnf("job %s if evaluated as false, skip it", job.Name)
+			jobStatus.SkippedWithReason("if evaluated as false")
+			return core.NewRunnableResult(nil)
+		}
+	}
+
+	result = job.run(parent, runCtx, &jobCtx)
+	if result.ReturnCode != 0 && job.HasContinueOnError() && jobStatus.Status() != core.Cancelled {
+		log.Debugf("job %s failed, check continue on error", job.Name)
+		value, err := job.evalContinueOnError(runCtx, parent, &jobCtx)
+		if err != nil {
+			jobStatus.Finish(err)
+			return core.NewRunnable(err, -1, "")
+		}
+		if value {
+			log.Warnf("job %s failed, continue on error: %v", job.Name, result.Err)
+			jobStatus.SetContinueOnErr(true)
+			return core.NewRunnableResult(nil)
+		}
+	}
+	return result
+}
+
+// run runs the steps and the post steps of the job
+func (job *Job) run(parent env.Env, runCtx *run_context.WorkflowRunContext, jobCtx *core.RunnableContext) *core.RunnableResult {
+	jobStatus := jobCtx.JobStatus
+	ctx, cancel := withTimeout(jobCtx, job.TimeoutMinutes)
+	defer cancel()
 	jobStatus.Start()
 
+	var errResults []error
+	var outputs map[string]string
 	jobEnv, err := InterpretNadEnv(&runCtx.JSCtx, parent, job.Env, ctx.GenerateMap())
 	if err != nil {
 		log.Errorf("Failed to interpret job env %v", err)
-		log.Errorf("job %s failed", job.Name)
-		log.Debugf("job %s error: %s", job.Name, jobStatus.Reason())
-		jobStatus.Finish(err)
-		return core.NewRunnableResult(err)
-	}
-
-	var errResults []error
-	for _, step := range job.Steps {
-		ret := step.Do(jobEnv, runCtx, ctx)
+		errResults = append(errResults, fmt.Errorf("failed to interpret env of job %s: %w", job.Name, err))
+		// the steps can't run without the job env, the post steps always run so they run with the parent env
+		jobEnv = env.NewReadWriteEnv(parent, nil)
+		for _, step := range job.Steps {
+			step.skip(ctx, fmt.Sprintf("env of job %s failed", job.Name))
+		}
+	} else if job.UsesWorkflow() {
+		ret := job.callWorkflow(jobEnv, runCtx, ctx)
 		if ret.ReturnCode != 0 {
-			log.Errorf("Run job %s failed due to step %s failed", job.Name, step.Name)
+			log.Errorf("Run job %s failed due to workflow %s failed", job.Name, job.Uses)
 			errResults = append(errResults, ret.Err)
 		}
+		outputs = ret.Outputs
+	} else {
+		checkpointer := job.checkpointer(runCtx)
+		// the steps which passed in the resumed run are restored until the first step which didn't pass
+		restoring := len(checkpointer.ResumedFrom()) > 0
+		for i, step := range job.Steps {
+			if restoring {
+				stepCheckpoint := checkpointer.ResumedStep(job.Name, i, step.Name)
+				if step.restore(jobEnv, ctx, stepCheckpoint, checkpointer.ResumedFrom()) {
+					checkpointer.RecordStep(job.Name, stepCheckpoint)
+					continue
+				}
+				restoring = false
+			}
+			if isTimedOut(ctx) {
+				log.Warnf("step %s skipped due to job %s timed out", step.Name, job.Name)
+				step.skip(ctx, fmt.Sprintf("job %s timed out", job.Name))
+				continue
+			}
+			if isCancelled(ctx) {
+				log.Warnf("step %s skipped due to job %s cancelled", step.Name, job.Name)
+				step.skip(ctx, fmt.Sprintf("job %s cancelled", job.Name))
+				continue
+			}
+			ret := step.Do(jobEnv, runCtx, ctx)
+			if ret.ReturnCode != 0 {
+				log.Errorf("Run job %s failed due to step %s failed", job.Name, step.Name)
+				errResults = append(errResults, ret.Err)
+			}
+			if checkpointer != nil {
+				checkpointer.RecordStep(job.Name, newStepCheckpoint(step, parent, jobEnv, ctx, ret))
+			}
+		}
+		if len(errResults) == 0 && ctx.GetContext().Err() == nil {
+			if outputs, err = job.evalOutputs(runCtx, jobEnv, ctx); err != nil {
+				errResults = append(errResults, err)
+			}
+		}
 	}
-	if len(errResults) == 0 {
+	var result *core.RunnableResult
+	if isTimedOut(ctx) {
+		err = fmt.Errorf("job %s timed out after %v minutes", job.Name, job.TimeoutMinutes)
+		log.Error(err)
+		jobStatus.TimedOut(err)
+		result = core.NewRunnable(errors.Join(append(errResults, err)...), 255, "")
+	} else if isCancelled(ctx) {
+		err = fmt.Errorf("job %s cancelled: %w", job.Name, cancelCause(ctx))
+		log.Error(err)
+		jobStatus.Cancel(err)
+		result = core.NewRunnable(errors.Join(append(errResults, err)...), 255, "")
+	} else if len(errResults) == 0 {
 		log.Debugf("job %s passed", job.Name)
 		jobStatus.Finish([]error{}...)
-		return core.NewRunnableResult(nil)
+		result = core.NewRunnableResult(nil)
+		result.Outputs = outputs
 	} else {
 		log.Errorf("job %s failed", job.Name)
 		log.Debugf("job %s error: %s", job.Name, jobStatus.Reason())
 		jobStatus.Finish(errResults...)
-		return core.NewRunnable(errors.Join(errResults...), 255, "")
+		result = core.NewRunnable(errors.Join(errResults...), 255, "")
 	}
 
+	postErrs := job.runPost(jobEnv, runCtx, ctx)
+	if len(postErrs) > 0 {
+		log.Errorf("job %s failed due to post steps failed", job.Name)
+		if result.ReturnCode == 0 {
+			jobStatus.Finish(postErrs...)
+			return core.NewRunnable(errors.Join(postErrs...), 255, "")
+		}
+		result.Err = errors.Join(append([]error{result.Err}, postErrs...)...)
+	}
+	return result
+}
+
+// runPost runs the post steps once the job status is final, so job.status() and job.reason() are available. The post
+// steps don't inherit the timeout or cancellation of the job, cancelled() tells whether the run is cancelled.
+func (job *Job) runPost(jobEnv env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) []error {
+	if len(job.Post) == 0 {
+		return nil
+	}
+	postCtx := *ctx
+	postCtx.Context = context.WithoutCancel(ctx.GetContext())
+
+	var errs []error
+	for _, step := range job.Post {
+		log.Infof("run post step %s of job %s", step.Name, job.Name)
+		ret := step.Do(jobEnv, runCtx, &postCtx)
+		if ret.ReturnCode != 0 {
+			log.Errorf("post step %s of job %s failed", step.Name, job.Name)
+			errs = append(errs, ret.Err)
+		}
+	}
+	return errs
+}
+
+// evalOutputs evaluates the job outputs, the step outputs are available as steps.<id>.outputs
+func (job *Job) evalOutputs(runCtx *run_context.WorkflowRunContext, jobEnv env.Env, ctx *core.RunnableContext) (map[string]string, error) {
+	if len(job.Outputs) == 0 {
+		return nil, nil
+	}
+	outputs, err := run_context.InterpretPluginCfg(runCtx, jobEnv, job.Outputs, ctx.GenerateMap())
+	if err != nil {
+		log.Errorf("Failed to eval outputs of job %s, error: %v", job.Name, err)
+		return nil, fmt.Errorf("failed to eval outputs of job %s: %w", job.Name, err)
+	}
+	return outputs, nil
+}
+
+func (job *Job) evalIf(runCtx *run_context.WorkflowRunContext, parent env.Env, ctx *core.RunnableContext) (bool, error) {
+	value, err := runCtx.JSCtx.EvalActionScriptBool(parent, job.If, ctx.GenerateMap())
+	if err != nil {
+		log.Errorf("Failed to eval if for job %s, error: %v", job.Name, err)
+		return false, err
+	}
+
+	log.Infof("If is %v for job %s", value, job.Name)
+	return value, nil
+}
+
+func (job *Job) evalContinueOnError(runCtx *run_context.WorkflowRunContext, parent env.Env, ctx *core.RunnableContext) (bool, error) {
+	value, err := runCtx.JSCtx.EvalActionScriptBool(parent, job.ContinueOnError, ctx.GenerateMap())
+	if err != nil {
+		log.Errorf("Failed to eval continue-on-error for job %s, error: %v", job.Name, err)
+		return false, err
+	}
+	log.Infof("Continue on error is %v for job %s", value, job.Name)
+	return value, nil
 }
@@ -0,0 +1,69 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateJobNeeds checks that every job in needs exists and that the job dependencies form a DAG
+func validateJobNeeds(jobs []*Job) error {
+	jobMap := make(map[string]*Job, len(jobs))
+	for _, job := range jobs {
+		jobMap[job.Name] = job
+	}
+
+	for _, job := range jobs {
+		for _, need := range job.Needs {
+			if need == job.Name {
+				return job.pos.of("needs").wrap(fmt.Errorf("job %s can't depend on itself", job.Name))
+			}
+			if _, ok := jobMap[need]; !ok {
+				return job.pos.of("needs").wrap(fmt.Errorf("job %s needs unknown job %s", job.Name, need))
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(jobs))
+	var path []string
+
+	var visit func(job *Job) error
+	visit = func(job *Job) error {
+		switch state[job.Name] {
+		case visiting:
+			start := 0
+			for i, name := range path {
+				if name == job.Name {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), job.Name)
+			return job.pos.of("needs").wrap(fmt.Errorf("job dependency cycle detected: %s", strings.Join(cycle, " -> ")))
+		case visited:
+			return nil
+		}
+
+		state[job.Name] = visiting
+		path = append(path, job.Name)
+		for _, need := range job.Needs {
+			if err := visit(jobMap[need]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[job.Name] = visited
+		return nil
+	}
+
+	for _, job := range jobs {
+		if err := visit(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
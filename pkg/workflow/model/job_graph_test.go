@@ -0,0 +1,121 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateJobNeeds(t *testing.T) {
+	t.Run("NoNeeds", func(t *testing.T) {
+		jobs := []*Job{{Name: "a"}, {Name: "b"}}
+		if err := validateJobNeeds(jobs); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("ValidDAG", func(t *testing.T) {
+		jobs := []*Job{
+			{Name: "a"},
+			{Name: "b", Needs: []string{"a"}},
+			{Name: "c", Needs: []string{"a"}},
+			{Name: "d", Needs: []string{"b", "c"}},
+		}
+		if err := validateJobNeeds(jobs); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("UnknownNeed", func(t *testing.T) {
+		jobs := []*Job{{Name: "a", Needs: []string{"missing"}}}
+		err := validateJobNeeds(jobs)
+		if err == nil {
+			t.Fatal("Expected error for unknown need")
+		}
+		if !strings.Contains(err.Error(), "unknown job missing") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("SelfNeed", func(t *testing.T) {
+		jobs := []*Job{{Name: "a", Needs: []string{"a"}}}
+		if err := validateJobNeeds(jobs); err == nil {
+			t.Error("Expected error for job depending on itself")
+		}
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		jobs := []*Job{
+			{Name: "a", Needs: []string{"c"}},
+			{Name: "b", Needs: []string{"a"}},
+			{Name: "c", Needs: []string{"b"}},
+		}
+		err := validateJobNeeds(jobs)
+		if err == nil {
+			t.Fatal("Expected error for cycle")
+		}
+		if !strings.Contains(err.Error(), "a -> c -> b -> a") {
+			t.Errorf("Expected cycle path in error, got %v", err)
+		}
+	})
+}
+
+func TestParseWorkflowNeeds(t *testing.T) {
+	t.Run("ParseNeedsAndMaxParallel", func(t *testing.T) {
+		yml := `
+name: needs
+max-parallel: 2
+jobs:
+  build:
+    steps:
+      - run: echo build
+  test:
+    needs: [build]
+    steps:
+      - run: echo test
+`
+		wf, err := ParseWorkflow(strings.NewReader(yml))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if wf.MaxParallel != 2 {
+			t.Errorf("Expected max-parallel 2, got %d", wf.MaxParallel)
+		}
+		if len(wf.Jobs[1].Needs) != 1 || wf.Jobs[1].Needs[0] != "build" {
+			t.Errorf("Expected needs [build], got %v", wf.Jobs[1].Needs)
+		}
+	})
+
+	t.Run("CycleRejected", func(t *testing.T) {
+		yml := `
+name: cycle
+jobs:
+  a:
+    needs: [b]
+    steps:
+      - run: echo a
+  b:
+    needs: [a]
+    steps:
+      - run: echo b
+`
+		_, err := ParseWorkflow(strings.NewReader(yml))
+		if err == nil {
+			t.Fatal("Expected error for cyclic needs")
+		}
+	})
+
+	t.Run("NegativeMaxParallel", func(t *testing.T) {
+		yml := `
+name: invalid
+max-parallel: -1
+jobs:
+  a:
+    steps:
+      - run: echo a
+`
+		_, err := ParseWorkflow(strings.NewReader(yml))
+		if err == nil {
+			t.Fatal("Expected error for negative max-parallel")
+		}
+	})
+}
@@ -0,0 +1,237 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// JobStrategy defines how a job runs as multiple instances
+type JobStrategy struct {
+	FailFast *bool `yaml:"fail-fast"`
+	Matrix   *JobMatrix
+}
+
+// IsFailFast returns whether the other instances are cancelled once an instance fails, it's true by default
+func (s *JobStrategy) IsFailFast() bool {
+	return s == nil || s.FailFast == nil || *s.FailFast
+}
+
+// MatrixCombination is a set of matrix values, the keys keep the definition order
+type MatrixCombination struct {
+	Keys   []string
+	Values map[string]any
+}
+
+func (c *MatrixCombination) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: matrix entry must be a map", node.Line)
+	}
+	c.Values = make(map[string]any)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var value any
+		if err := node.Content[i+1].Decode(&value); err != nil {
+			return err
+		}
+		c.set(node.Content[i].Value, value)
+	}
+	return nil
+}
+
+func (c *MatrixCombination) set(key string, value any) {
+	if _, ok := c.Values[key]; !ok {
+		c.Keys = append(c.Keys, key)
+	}
+	c.Values[key] = value
+}
+
+func (c *MatrixCombination) copy() *MatrixCombination {
+	other := &MatrixCombination{Values: make(map[string]any, len(c.Values))}
+	for _, key := range c.Keys {
+		other.set(key, c.Values[key])
+	}
+	return other
+}
+
+// matches returns whether the combination has the same values of the given keys of other
+func (c *MatrixCombination) matches(other *MatrixCombination, keys []string) bool {
+	for _, key := range keys {
+		value, ok := c.Values[key]
+		if !ok || !matrixValueEquals(value, other.Values[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the values joined in the key order, e.g. postgres, 14
+func (c *MatrixCombination) String() string {
+	values := make([]string, 0, len(c.Keys))
+	for _, key := range c.Keys {
+		values = append(values, fmt.Sprintf("%v", c.Values[key]))
+	}
+	return strings.Join(values, ", ")
+}
+
+// JobMatrix defines the values a job is run with, each combination of the values runs as a job instance
+type JobMatrix struct {
+	Keys    []string
+	Values  map[string][]any
+	Include []*MatrixCombination
+	Exclude []*MatrixCombination
+}
+
+func (m *JobMatrix) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: matrix must be a map", node.Line)
+	}
+	m.Values = make(map[string][]any)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		valueNode := node.Content[i+1]
+		var err error
+		switch key {
+		case "include":
+			err = valueNode.Decode(&m.Include)
+		case "exclude":
+			err = valueNode.Decode(&m.Exclude)
+		default:
+			var values []any
+			err = valueNode.Decode(&values)
+			if err == nil && len(values) == 0 {
+				err = fmt.Errorf("line %d: matrix %s has no value", valueNode.Line, key)
+			}
+			m.Keys = append(m.Keys, key)
+			m.Values[key] = values
+		}
+		if err != nil {
+			return fmt.Errorf("invalid matrix %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Combinations returns the combinations of the matrix values, the excluded combinations are removed before the
+// included combinations are added
+func (m *JobMatrix) Combinations() ([]*MatrixCombination, error) {
+	var combinations []*MatrixCombination
+	if len(m.Keys) > 0 {
+		combinations = []*MatrixCombination{{Values: make(map[string]any)}}
+	}
+	for _, key := range m.Keys {
+		var expanded []*MatrixCombination
+		for _, combination := range combinations {
+			for _, value := range m.Values[key] {
+				c := combination.copy()
+				c.set(key, value)
+				expanded = append(expanded, c)
+			}
+		}
+		combinations = expanded
+	}
+
+	var kept []*MatrixCombination
+	for _, combination := range combinations {
+		if !m.isExcluded(combination) {
+			kept = append(kept, combination)
+		}
+	}
+
+	originals := len(kept)
+	for _, include := range m.Include {
+		added := false
+		for _, combination := range kept[:originals] {
+			if m.canInclude(combination, include) {
+				for _, key := range include.Keys {
+					combination.set(key, include.Values[key])
+				}
+				added = true
+			}
+		}
+		if !added {
+			kept = append(kept, include.copy())
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("matrix has no combination")
+	}
+	return kept, nil
+}
+
+func (m *JobMatrix) isExcluded(combination *MatrixCombination) bool {
+	for _, exclude := range m.Exclude {
+		if combination.matches(exclude, exclude.Keys) {
+			return true
+		}
+	}
+	return false
+}
+
+// canInclude returns whether the include can be added to the combination without overwriting any matrix value
+func (m *JobMatrix) canInclude(combination *MatrixCombination, include *MatrixCombination) bool {
+	var matrixKeys []string
+	for _, key := range include.Keys {
+		if _, ok := m.Values[key]; ok {
+			matrixKeys = append(matrixKeys, key)
+		}
+	}
+	return combination.matches(include, matrixKeys)
+}
+
+func matrixValueEquals(a, b any) bool {
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// expandMatrixJob decodes a job instance from the job node for each matrix combination, the instance is named like
+// backup (postgres)
+func expandMatrixJob(job *Job, node *yaml.Node) ([]*Job, error) {
+	combinations, err := job.Strategy.Matrix.Combinations()
+	if err != nil {
+		return nil, fmt.Errorf("invalid matrix of job %s: %w", job.Name, err)
+	}
+	names := make(map[string]bool, len(combinations))
+	var instances []*Job
+	for _, combination := range combinations {
+		var instance Job
+		if err = node.Decode(&instance); err != nil {
+			return nil, fmt.Errorf("failed to parse job %s: %w", job.Name, err)
+		}
+		instance.Name = fmt.Sprintf("%s (%s)", job.Name, combination.String())
+		if names[instance.Name] {
+			return nil, fmt.Errorf("duplicate matrix combination %s of job %s", combination.String(), job.Name)
+		}
+		names[instance.Name] = true
+		instance.Matrix = combination.Values
+		instance.matrixName = job.Name
+		instances = append(instances, &instance)
+	}
+	log.Debugf("job %s expanded to %d matrix instances", job.Name, len(instances))
+	return instances, nil
+}
+
+// resolveMatrixNeeds replaces the needs of a matrix job with all of its instances
+func resolveMatrixNeeds(jobs []*Job) {
+	instances := make(map[string][]string)
+	for _, job := range jobs {
+		if job.IsMatrixInstance() {
+			instances[job.matrixName] = append(instances[job.matrixName], job.Name)
+		}
+	}
+	if len(instances) == 0 {
+		return
+	}
+	for _, job := range jobs {
+		var needs []string
+		for _, need := range job.Needs {
+			if names, ok := instances[need]; ok {
+				needs = append(needs, names...)
+			} else {
+				needs = append(needs, need)
+			}
+		}
+		job.Needs = needs
+	}
+}
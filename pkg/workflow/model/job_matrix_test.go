@@ -0,0 +1,254 @@
+package workflow
+
+import (
+	"nadleeh/pkg/workflow/core"
+	"strings"
+	"testing"
+)
+
+func parseMatrixWorkflow(t *testing.T, yml string) *Workflow {
+	t.Helper()
+	wf, err := ParseWorkflow(strings.NewReader(yml))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return wf
+}
+
+func jobNames(jobs []*Job) []string {
+	var names []string
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+	return names
+}
+
+func TestJobMatrix_Combinations(t *testing.T) {
+	t.Run("Expand", func(t *testing.T) {
+		wf := parseMatrixWorkflow(t, `
+name: matrix
+jobs:
+  backup:
+    strategy:
+      matrix:
+        db: [postgres, mysql]
+        version: [14, 15]
+    steps:
+      - run: echo backup
+`)
+		expected := []string{"backup (postgres, 14)", "backup (postgres, 15)", "backup (mysql, 14)", "backup (mysql, 15)"}
+		if strings.Join(jobNames(wf.Jobs), "|") != strings.Join(expected, "|") {
+			t.Errorf("Expected jobs %v, got %v", expected, jobNames(wf.Jobs))
+		}
+		if wf.Jobs[1].Matrix["db"] != "postgres" || wf.Jobs[1].Matrix["version"] != 15 {
+			t.Errorf("Unexpected matrix %v", wf.Jobs[1].Matrix)
+		}
+		if wf.Jobs[0].Steps[0] == wf.Jobs[1].Steps[0] {
+			t.Error("Expected each instance to have its own steps")
+		}
+	})
+
+	t.Run("IncludeExclude", func(t *testing.T) {
+		wf := parseMatrixWorkflow(t, `
+name: matrix
+jobs:
+  backup:
+    strategy:
+      matrix:
+        db: [postgres, mysql]
+        exclude:
+          - db: mysql
+        include:
+          - db: postgres
+            port: 5432
+          - db: mongo
+    steps:
+      - run: echo backup
+`)
+		expected := []string{"backup (postgres, 5432)", "backup (mongo)"}
+		if strings.Join(jobNames(wf.Jobs), "|") != strings.Join(expected, "|") {
+			t.Errorf("Expected jobs %v, got %v", expected, jobNames(wf.Jobs))
+		}
+	})
+
+	t.Run("NoCombination", func(t *testing.T) {
+		_, err := ParseWorkflow(strings.NewReader(`
+name: matrix
+jobs:
+  backup:
+    strategy:
+      matrix:
+        db: [postgres]
+        exclude:
+          - db: postgres
+    steps:
+      - run: echo backup
+`))
+		if err == nil || !strings.Contains(err.Error(), "no combination") {
+			t.Errorf("Expected no combination error, got %v", err)
+		}
+	})
+
+	t.Run("EmptyValues", func(t *testing.T) {
+		_, err := ParseWorkflow(strings.NewReader(`
+name: matrix
+jobs:
+  backup:
+    strategy:
+      matrix:
+        db: []
+    steps:
+      - run: echo backup
+`))
+		if err == nil || !strings.Contains(err.Error(), "has no value") {
+			t.Errorf("Expected empty matrix error, got %v", err)
+		}
+	})
+
+	t.Run("NeedsAllInstances", func(t *testing.T) {
+		wf := parseMatrixWorkflow(t, `
+name: matrix
+jobs:
+  backup:
+    strategy:
+      matrix:
+        db: [postgres, mysql]
+    steps:
+      - run: echo backup
+  notify:
+    needs: [backup]
+    steps:
+      - run: echo notify
+`)
+		notify := wf.Jobs[2]
+		if strings.Join(notify.Needs, "|") != "backup (postgres)|backup (mysql)" {
+			t.Errorf("Expected notify to need all instances, got %v", notify.Needs)
+		}
+	})
+}
+
+func TestJobStrategy_IsFailFast(t *testing.T) {
+	failFast := false
+	var nilStrategy *JobStrategy
+	if !nilStrategy.IsFailFast() || !(&JobStrategy{}).IsFailFast() {
+		t.Error("Expected fail-fast by default")
+	}
+	if (&JobStrategy{FailFast: &failFast}).IsFailFast() {
+		t.Error("Expected fail-fast to be false")
+	}
+}
+
+func TestJob_MatrixRun(t *testing.T) {
+	t.Run("Interpolation", func(t *testing.T) {
+		wf := parseMatrixWorkflow(t, `
+name: matrix
+jobs:
+  backup:
+    strategy:
+      matrix:
+        db: [postgres]
+    env:
+      DB: ${{ matrix.db }}
+    steps:
+      - run: test "$DB" = "postgres" && test "${{ matrix.db }}" = "postgres"
+`)
+		job := wf.Jobs[0]
+		if err := job.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		ctx := createTestJobRunnableContext()
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		if result.ReturnCode != 0 {
+			t.Fatalf("Expected job to pass, got %v", result.Err)
+		}
+		if ctx.WorkflowStatus.GetChild("backup (postgres)") == nil {
+			t.Error("Expected status of the matrix instance")
+		}
+	})
+
+	matrixJobs := func(t *testing.T, failFast string) []*Job {
+		wf := parseMatrixWorkflow(t, `
+name: matrix
+jobs:
+  backup:
+    strategy:
+      fail-fast: `+failFast+`
+      matrix:
+        db: [postgres, mysql, mongo]
+    steps:
+      - script: if (matrix.db === "postgres") { throw new Error("failed") }
+  notify:
+    needs: [backup]
+    steps:
+      - run: echo notify
+`)
+		for _, job := range wf.Jobs {
+			if err := job.Precheck(); err != nil {
+				t.Fatalf("Unexpected precheck error: %v", err)
+			}
+		}
+		return wf.Jobs
+	}
+
+	t.Run("FailFast", func(t *testing.T) {
+		ctx := createTestJobRunnableContext()
+		errs := newJobScheduler(matrixJobs(t, "true"), 1).Run(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		if len(errs) != 1 {
+			t.Fatalf("Expected 1 error, got %v", errs)
+		}
+		for _, name := range []string{"backup (mysql)", "backup (mongo)", "notify"} {
+			if ctx.WorkflowStatus.GetChild(name).Status() != core.Skipped {
+				t.Errorf("Expected job %s to be skipped, got %s", name, ctx.WorkflowStatus.GetChild(name).Status())
+			}
+		}
+	})
+
+	t.Run("NotFailFast", func(t *testing.T) {
+		ctx := createTestJobRunnableContext()
+		errs := newJobScheduler(matrixJobs(t, "false"), 1).Run(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		if len(errs) != 1 {
+			t.Fatalf("Expected 1 error, got %v", errs)
+		}
+		expected := map[string]string{
+			"backup (postgres)": core.Fail,
+			"backup (mysql)":    core.Pass,
+			"backup (mongo)":    core.Pass,
+			"notify":            core.Skipped,
+		}
+		for name, status := range expected {
+			if ctx.WorkflowStatus.GetChild(name).Status() != status {
+				t.Errorf("Expected job %s status %s, got %s", name, status, ctx.WorkflowStatus.GetChild(name).Status())
+			}
+		}
+	})
+
+	t.Run("FailFastCancelsRunningInstances", func(t *testing.T) {
+		wf := parseMatrixWorkflow(t, `
+name: matrix
+jobs:
+  backup:
+    strategy:
+      matrix:
+        db: [postgres, mysql]
+    steps:
+      - script: if (matrix.db === "postgres") { throw new Error("failed") } else { while (true) {} }
+`)
+		for _, job := range wf.Jobs {
+			if err := job.Precheck(); err != nil {
+				t.Fatalf("Unexpected precheck error: %v", err)
+			}
+		}
+		ctx := createTestJobRunnableContext()
+		errs := newJobScheduler(wf.Jobs, 2).Run(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		if len(errs) != 2 {
+			t.Fatalf("Expected 2 errors, got %v", errs)
+		}
+		mysql := ctx.WorkflowStatus.GetChild("backup (mysql)")
+		if mysql.Status() != core.Cancelled {
+			t.Errorf("Expected the running instance to be cancelled, got %s", mysql.Status())
+		}
+		if !strings.Contains(mysql.Reason(), "job backup (postgres) failed") {
+			t.Errorf("Expected the failed instance in the reason, got %s", mysql.Reason())
+		}
+	})
+}
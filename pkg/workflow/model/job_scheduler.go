@@ -0,0 +1,162 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+// DefaultMaxParallel keeps jobs running one by one in the YAML order unless the workflow sets max-parallel
+const DefaultMaxParallel = 1
+
+type matrixGroup struct {
+	ctx    *core.RunnableContext
+	cancel context.CancelCauseFunc
+}
+
+type jobDone struct {
+	job    *Job
+	result *core.RunnableResult
+}
+
+// jobScheduler runs the workflow jobs following their needs, independent jobs run concurrently up to maxParallel
+type jobScheduler struct {
+	jobs        []*Job
+	maxParallel int
+}
+
+func newJobScheduler(jobs []*Job, maxParallel int) *jobScheduler {
+	if maxParallel <= 0 {
+		maxParallel = DefaultMaxParallel
+	}
+	return &jobScheduler{
+		jobs:        jobs,
+		maxParallel: maxParallel,
+	}
+}
+
+// Run runs all jobs and returns the errors of the failed jobs. Once a job fails or the run is cancelled, no new job
+// will be started, the running jobs are waited and the pending jobs are skipped. The other instances of a failed matrix job are
+// cancelled if the matrix is fail-fast, or are still started if it's not.
+func (s *jobScheduler) Run(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) []error {
+	results := make(map[string]*core.RunnableResult, len(s.jobs))
+	pending := append([]*Job{}, s.jobs...)
+	done := make(chan jobDone)
+	running := 0
+	var errs []error
+	// halted is false if only the instances of the matrix jobs which are not fail-fast failed
+	halted := false
+	continuedMatrices := make(map[string]bool)
+	matrices := make(map[string]*matrixGroup)
+	defer func() {
+		for _, group := range matrices {
+			group.cancel(nil)
+		}
+	}()
+
+	for {
+		// no new job is started once the run is cancelled
+		halted = halted || isCancelled(ctx)
+		if !halted {
+			var waiting []*Job
+			for _, job := range pending {
+				ready, failedNeed := s.checkNeeds(job, results)
+				if len(failedNeed) > 0 {
+					log.Warnf("job %s skipped due to job %s didn't pass", job.Name, failedNeed)
+					job.skip(ctx, fmt.Sprintf("needed job %s didn't pass", failedNeed))
+					results[job.Name] = core.NewRunnable(nil, -1, "")
+					continue
+				}
+				if len(errs) > 0 && !continuedMatrices[job.matrixName] {
+					waiting = append(waiting, job)
+					continue
+				}
+				if !ready || running >= s.maxParallel {
+					waiting = append(waiting, job)
+					continue
+				}
+				running++
+				jobCtx := ctx
+				if job.IsMatrixInstance() {
+					jobCtx = s.matrixGroup(matrices, job, ctx).ctx
+				}
+				go func(job *Job, jobCtx *core.RunnableContext) {
+					done <- jobDone{job: job, result: job.Do(parent, runCtx, jobCtx)}
+				}(job, jobCtx)
+			}
+			pending = waiting
+		}
+
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+		results[d.job.Name] = d.result
+		if status := ctx.WorkflowStatus.GetChild(d.job.Name); status != nil && status.Status() == core.Skipped {
+			// the jobs need a skipped job are skipped as well
+			results[d.job.Name] = core.NewRunnable(nil, -1, "")
+		} else if d.result.ReturnCode != 0 {
+			log.Errorf("job %s failed", d.job.Name)
+			errs = append(errs, d.result.Err)
+			if !d.job.IsMatrixInstance() || d.job.Strategy.IsFailFast() {
+				halted = true
+			} else {
+				continuedMatrices[d.job.matrixName] = true
+			}
+			if group, ok := matrices[d.job.matrixName]; ok && d.job.Strategy.IsFailFast() {
+				log.Warnf("cancel the other instances of job %s due to job %s failed", d.job.matrixName, d.job.Name)
+				group.cancel(fmt.Errorf("job %s failed", d.job.Name))
+			}
+		}
+	}
+
+	for _, job := range pending {
+		if _, failedNeed := s.checkNeeds(job, results); len(failedNeed) > 0 {
+			log.Warnf("job %s skipped due to job %s didn't pass", job.Name, failedNeed)
+			job.skip(ctx, fmt.Sprintf("needed job %s didn't pass", failedNeed))
+			continue
+		}
+		if isCancelled(ctx) {
+			log.Warnf("job %s skipped due to workflow cancelled", job.Name)
+			job.skip(ctx, "workflow cancelled")
+			continue
+		}
+		log.Warnf("job %s skipped due to previous error", job.Name)
+		job.skip(ctx, "a previous job failed")
+	}
+	return errs
+}
+
+// matrixGroup returns the runnable context shared by the instances of the matrix job, it's cancelled once an
+// instance of a fail-fast matrix fails
+func (s *jobScheduler) matrixGroup(matrices map[string]*matrixGroup, job *Job, ctx *core.RunnableContext) *matrixGroup {
+	group, ok := matrices[job.matrixName]
+	if !ok {
+		groupCtx := *ctx
+		var cancel context.CancelCauseFunc
+		groupCtx.Context, cancel = context.WithCancelCause(ctx.GetContext())
+		group = &matrixGroup{ctx: &groupCtx, cancel: cancel}
+		matrices[job.matrixName] = group
+	}
+	return group
+}
+
+// checkNeeds returns whether all needed jobs passed, or the name of the first needed job which didn't pass
+func (s *jobScheduler) checkNeeds(job *Job, results map[string]*core.RunnableResult) (bool, string) {
+	for _, need := range job.Needs {
+		result, ok := results[need]
+		if !ok {
+			return false, ""
+		}
+		if result.ReturnCode != 0 {
+			return false, need
+		}
+	}
+	return true, ""
+}
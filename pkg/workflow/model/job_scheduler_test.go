@@ -0,0 +1,133 @@
+package workflow
+
+import (
+	"nadleeh/pkg/workflow/core"
+	"testing"
+)
+
+func createSchedulerJob(name string, script string, needs ...string) *Job {
+	step := &Step{Name: name + "-step", Script: script}
+	_ = step.Precheck()
+	return &Job{Name: name, Steps: []*Step{step}, Needs: needs}
+}
+
+func TestJobScheduler_Run(t *testing.T) {
+	t.Run("RunsAllJobsFollowingNeeds", func(t *testing.T) {
+		jobs := []*Job{
+			createSchedulerJob("c", "env.set('C', env.get('B') + 'c')", "b"),
+			createSchedulerJob("a", "1"),
+			createSchedulerJob("b", "1", "a"),
+		}
+		ctx := createTestJobRunnableContext()
+		parent := &mockJobEnv{data: map[string]string{}}
+
+		errs := newJobScheduler(jobs, 2).Run(parent, createTestWorkflowRunContextPtrForJob(), ctx)
+		if len(errs) != 0 {
+			t.Fatalf("Expected no errors, got %v", errs)
+		}
+		for _, name := range []string{"a", "b", "c"} {
+			status := ctx.WorkflowStatus.GetChild(name)
+			if status == nil {
+				t.Fatalf("Expected status for job %s", name)
+			}
+			if status.Status() != core.Pass {
+				t.Errorf("Expected job %s to pass, got %s", name, status.Status())
+			}
+		}
+		if ctx.WorkflowStatus.GetChildByIndex(0) != ctx.WorkflowStatus.GetChild("a") {
+			t.Error("Expected job a to run first")
+		}
+	})
+
+	t.Run("ParallelJobs", func(t *testing.T) {
+		var jobs []*Job
+		for _, name := range []string{"j1", "j2", "j3", "j4"} {
+			jobs = append(jobs, createSchedulerJob(name, "var x = 1; x + 1;"))
+		}
+		ctx := createTestJobRunnableContext()
+		parent := &mockJobEnv{data: map[string]string{}}
+
+		errs := newJobScheduler(jobs, 4).Run(parent, createTestWorkflowRunContextPtrForJob(), ctx)
+		if len(errs) != 0 {
+			t.Fatalf("Expected no errors, got %v", errs)
+		}
+		for _, job := range jobs {
+			if ctx.WorkflowStatus.GetChild(job.Name).Status() != core.Pass {
+				t.Errorf("Expected job %s to pass", job.Name)
+			}
+		}
+	})
+
+	t.Run("FailureSkipsDependents", func(t *testing.T) {
+		jobs := []*Job{
+			createSchedulerJob("a", "throw new Error('failed')"),
+			createSchedulerJob("b", "1", "a"),
+			createSchedulerJob("c", "1"),
+		}
+		ctx := createTestJobRunnableContext()
+		parent := &mockJobEnv{data: map[string]string{}}
+
+		errs := newJobScheduler(jobs, 1).Run(parent, createTestWorkflowRunContextPtrForJob(), ctx)
+		if len(errs) != 1 {
+			t.Fatalf("Expected 1 error, got %v", errs)
+		}
+		if ctx.WorkflowStatus.GetChild("a").Status() != core.Fail {
+			t.Errorf("Expected job a to fail")
+		}
+		if ctx.WorkflowStatus.GetChild("b").Status() != core.Skipped {
+			t.Errorf("Expected job b to be skipped, got %s", ctx.WorkflowStatus.GetChild("b").Status())
+		}
+		if ctx.WorkflowStatus.GetChild("c").Status() != core.Skipped {
+			t.Errorf("Expected job c to be skipped, got %s", ctx.WorkflowStatus.GetChild("c").Status())
+		}
+	})
+
+	t.Run("SkippedJobSkipsDependents", func(t *testing.T) {
+		optional := createSchedulerJob("optional", "1")
+		optional.If = "${{ false }}"
+		jobs := []*Job{
+			optional,
+			createSchedulerJob("upload", "1", "optional"),
+			createSchedulerJob("other", "1"),
+		}
+		ctx := createTestJobRunnableContext()
+		parent := &mockJobEnv{data: map[string]string{}}
+
+		errs := newJobScheduler(jobs, 1).Run(parent, createTestWorkflowRunContextPtrForJob(), ctx)
+		if len(errs) != 0 {
+			t.Fatalf("Expected no errors, got %v", errs)
+		}
+		expected := map[string]string{"optional": core.Skipped, "upload": core.Skipped, "other": core.Pass}
+		for name, status := range expected {
+			if ctx.WorkflowStatus.GetChild(name).Status() != status {
+				t.Errorf("Expected job %s status %s, got %s", name, status, ctx.WorkflowStatus.GetChild(name).Status())
+			}
+		}
+	})
+
+	t.Run("ContinueOnErrorJob", func(t *testing.T) {
+		flaky := createSchedulerJob("flaky", "throw new Error('failed')")
+		flaky.ContinueOnError = "${{ true }}"
+		jobs := []*Job{
+			flaky,
+			createSchedulerJob("next", "1", "flaky"),
+		}
+		ctx := createTestJobRunnableContext()
+		parent := &mockJobEnv{data: map[string]string{}}
+
+		errs := newJobScheduler(jobs, 1).Run(parent, createTestWorkflowRunContextPtrForJob(), ctx)
+		if len(errs) != 0 {
+			t.Fatalf("Expected no errors, got %v", errs)
+		}
+		if ctx.WorkflowStatus.GetChild("next").Status() != core.Pass {
+			t.Errorf("Expected job next to run, got %s", ctx.WorkflowStatus.GetChild("next").Status())
+		}
+	})
+
+	t.Run("DefaultMaxParallel", func(t *testing.T) {
+		scheduler := newJobScheduler(nil, 0)
+		if scheduler.maxParallel != DefaultMaxParallel {
+			t.Errorf("Expected max parallel %d, got %d", DefaultMaxParallel, scheduler.maxParallel)
+		}
+	})
+}
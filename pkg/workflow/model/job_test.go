@@ -1,12 +1,15 @@
 package workflow
 
 import (
+	"context"
+	"errors"
 	"nadleeh/pkg/encrypt"
 	"nadleeh/pkg/script"
 	"nadleeh/pkg/workflow/core"
 	"nadleeh/pkg/workflow/run_context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/zhaojunlucky/golib/pkg/env"
 )
@@ -645,3 +648,366 @@ func BenchmarkJob_Do(b *testing.B) {
 		}
 	}
 }
+
+func TestJob_Timeout(t *testing.T) {
+	t.Run("StepTimedOut", func(t *testing.T) {
+		step1 := &Step{Name: "step1", Script: "while (true) {}", TimeoutMinutes: 0.001}
+		step2 := &Step{Name: "step2", Script: "1"}
+		job := createTestJob("test-job", []*Step{step1, step2}, nil)
+		_ = step1.Precheck()
+		_ = step2.Precheck()
+		ctx := createTestJobRunnableContext()
+
+		result := job.Do(&mockJobEnv{}, createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected job to fail")
+		}
+		jobStatus := ctx.WorkflowStatus.GetChild("test-job")
+		if jobStatus.Status() != core.Fail {
+			t.Errorf("Expected job status %s, got %s", core.Fail, jobStatus.Status())
+		}
+		if jobStatus.GetChild("step1").Status() != core.TimedOut {
+			t.Errorf("Expected step1 status %s, got %s", core.TimedOut, jobStatus.GetChild("step1").Status())
+		}
+		if jobStatus.GetChild("step2").Status() != core.Skipped {
+			t.Errorf("Expected step2 status %s, got %s", core.Skipped, jobStatus.GetChild("step2").Status())
+		}
+	})
+
+	t.Run("JobTimedOut", func(t *testing.T) {
+		step1 := &Step{Name: "step1", Script: "while (true) {}"}
+		step2 := &Step{Name: "step2", Script: "1", If: "${{ true }}"}
+		job := createTestJob("test-job", []*Step{step1, step2}, nil)
+		job.TimeoutMinutes = 0.001
+		_ = step1.Precheck()
+		_ = step2.Precheck()
+		ctx := createTestJobRunnableContext()
+
+		result := job.Do(&mockJobEnv{}, createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected job to fail")
+		}
+		jobStatus := ctx.WorkflowStatus.GetChild("test-job")
+		if jobStatus.Status() != core.TimedOut {
+			t.Errorf("Expected job status %s, got %s", core.TimedOut, jobStatus.Status())
+		}
+		if jobStatus.GetChild("step1").Status() != core.TimedOut {
+			t.Errorf("Expected step1 status %s, got %s", core.TimedOut, jobStatus.GetChild("step1").Status())
+		}
+		if jobStatus.GetChild("step2").Status() != core.Skipped {
+			t.Errorf("Expected step2 status %s, got %s", core.Skipped, jobStatus.GetChild("step2").Status())
+		}
+	})
+}
+
+func TestJob_Cancel(t *testing.T) {
+	runCancelled := func(t *testing.T, job *Job) (*core.RunnableResult, *core.RunnableStatus, time.Duration) {
+		t.Helper()
+		if err := job.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		ctx := createTestJobRunnableContext()
+		cancelCtx, cancel := context.WithCancelCause(context.Background())
+		ctx.Context = cancelCtx
+		ctx.Cancellation = cancelCtx
+		time.AfterFunc(100*time.Millisecond, func() { cancel(errors.New("received interrupt")) })
+		start := time.Now()
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		return result, ctx.WorkflowStatus.GetChild(job.Name), time.Since(start)
+	}
+
+	for name, step := range map[string]*Step{
+		"Bash": {Name: "step1", Run: "sleep 30"},
+		"JS":   {Name: "step1", Script: "while (true) {}"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			job := createTestJob("test-job", []*Step{step, {Name: "step2", Script: "1", If: "${{ true }}"}}, nil)
+			job.Post = []*Step{
+				{Name: "cleanup", Script: "1", If: "${{ cancelled() && job.status() == 'Cancelled' }}"},
+			}
+			result, jobStatus, elapsed := runCancelled(t, job)
+
+			if result.ReturnCode == 0 {
+				t.Fatal("Expected job to fail")
+			}
+			if elapsed > 10*time.Second {
+				t.Errorf("Expected the running step to be cancelled, took %v", elapsed)
+			}
+			if jobStatus.Status() != core.Cancelled {
+				t.Errorf("Expected job status %s, got %s", core.Cancelled, jobStatus.Status())
+			}
+			if !contains(jobStatus.Reason(), "received interrupt") {
+				t.Errorf("Expected the cancel cause in the reason, got %s", jobStatus.Reason())
+			}
+			if jobStatus.GetChild("step1").Status() != core.Cancelled {
+				t.Errorf("Expected step1 status %s, got %s", core.Cancelled, jobStatus.GetChild("step1").Status())
+			}
+			if jobStatus.GetChild("step2").Status() != core.Skipped {
+				t.Errorf("Expected step2 status %s, got %s", core.Skipped, jobStatus.GetChild("step2").Status())
+			}
+			if jobStatus.GetChild("cleanup").Status() != core.Pass {
+				t.Errorf("Expected post step to run, got %s", jobStatus.GetChild("cleanup").Status())
+			}
+		})
+	}
+
+	t.Run("NotContinued", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{
+			{Name: "step1", Script: "while (true) {}", ContinueOnError: "${{ true }}"},
+		}, nil)
+		job.ContinueOnError = "${{ true }}"
+		result, jobStatus, _ := runCancelled(t, job)
+
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected a cancelled job not to continue on error")
+		}
+		if jobStatus.Conclusion() != core.Cancelled {
+			t.Errorf("Expected job conclusion %s, got %s", core.Cancelled, jobStatus.Conclusion())
+		}
+	})
+}
+
+func TestJob_StepOutputs(t *testing.T) {
+	t.Run("OutputsOfPreviousSteps", func(t *testing.T) {
+		steps := []*Step{
+			{Name: "backup", Id: "backup", Script: "({path: '/tmp/backup.tar', size: 10})"},
+			{Name: "pack", Id: "pack", Run: `echo "name=backup.zip" >> "$NADLEEH_OUTPUT"`},
+			{Name: "flaky", Id: "flaky", Script: "throw new Error('failed')", ContinueOnError: "${{ true }}"},
+			{Name: "check", Script: "1", If: "${{ steps.backup.outputs.path == '/tmp/backup.tar' && steps.backup.outputs.size == '10' && steps.pack.outputs.name == 'backup.zip' }}"},
+			{Name: "conclusion", Script: "1", If: "${{ steps.flaky.status == 'Fail' && steps.flaky.conclusion == 'Pass' && steps.backup.conclusion == 'Pass' }}"},
+		}
+		for _, step := range steps {
+			if err := step.Precheck(); err != nil {
+				t.Fatalf("Unexpected precheck error: %v", err)
+			}
+		}
+		job := createTestJob("test-job", steps, nil)
+		ctx := createTestJobRunnableContext()
+
+		job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+
+		jobStatus := ctx.WorkflowStatus.GetChild("test-job")
+		for _, name := range []string{"backup", "pack", "check", "conclusion"} {
+			if jobStatus.GetChild(name).Status() != core.Pass {
+				t.Errorf("Expected step %s to pass, got %s", name, jobStatus.GetChild(name).Status())
+			}
+		}
+	})
+
+	t.Run("DuplicateStepId", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{
+			{Name: "step1", Id: "same", Script: "1"},
+			{Name: "step2", Id: "same", Script: "2"},
+		}, nil)
+		err := job.Precheck()
+		if err == nil || !contains(err.Error(), "duplicate step id same") {
+			t.Errorf("Expected duplicate step id error, got %v", err)
+		}
+	})
+}
+
+func TestJob_BashEnv(t *testing.T) {
+	steps := []*Step{
+		{Name: "export", Run: "echo \"BACKUP_DIR=/tmp/backup\" >> \"$NADLEEH_ENV\"\nprintf 'NOTES<<EOF\\nline 1\\nline 2\\nEOF\\n' >> \"$NADLEEH_ENV\""},
+		{Name: "bash", Run: `test "$BACKUP_DIR" = "/tmp/backup"`},
+		{Name: "js", Script: "if (env.get('NOTES') !== 'line 1\\nline 2') { throw new Error('unexpected NOTES ' + env.get('NOTES')) }"},
+	}
+	for _, step := range steps {
+		if err := step.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+	}
+	job := createTestJob("test-job", steps, nil)
+	ctx := createTestJobRunnableContext()
+	parent := &mockJobEnv{data: map[string]string{}}
+
+	result := job.Do(parent, createTestWorkflowRunContextPtrForJob(), ctx)
+
+	if result.ReturnCode != 0 {
+		t.Fatalf("Expected job to pass, got %v", result.Err)
+	}
+	if parent.Contains("BACKUP_DIR") {
+		t.Error("Expected env to be written to the job env only")
+	}
+}
+
+func TestJob_Post(t *testing.T) {
+	runJob := func(t *testing.T, job *Job) (*core.RunnableResult, *core.RunnableStatus) {
+		t.Helper()
+		if err := job.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		ctx := createTestJobRunnableContext()
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		return result, ctx.WorkflowStatus.GetChild(job.Name)
+	}
+
+	t.Run("RunsAfterFailure", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{
+			{Name: "fail", Script: "throw new Error('boom')"},
+			{Name: "skipped", Script: "1"},
+		}, nil)
+		job.Post = []*Step{
+			{Name: "cleanup", Script: "1"},
+			{Name: "notify", Script: "1", If: "${{ job.status() == 'Fail' && job.reason().indexOf('boom') >= 0 }}"},
+		}
+		result, jobStatus := runJob(t, job)
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected job to fail")
+		}
+		expected := map[string]string{"skipped": core.Skipped, "cleanup": core.Pass, "notify": core.Pass}
+		for name, status := range expected {
+			if jobStatus.GetChild(name).Status() != status {
+				t.Errorf("Expected step %s status %s, got %s", name, status, jobStatus.GetChild(name).Status())
+			}
+		}
+	})
+
+	t.Run("RunsAfterTimeout", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "loop", Script: "while (true) {}"}}, nil)
+		job.TimeoutMinutes = 0.001
+		job.Post = []*Step{{Name: "cleanup", Script: "1", If: "${{ job.status() == 'TimedOut' }}"}}
+		result, jobStatus := runJob(t, job)
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected job to fail")
+		}
+		if jobStatus.Status() != core.TimedOut {
+			t.Errorf("Expected job to time out, got %s", jobStatus.Status())
+		}
+		if jobStatus.GetChild("cleanup").Status() != core.Pass {
+			t.Errorf("Expected post step to pass, got %s", jobStatus.GetChild("cleanup").Status())
+		}
+	})
+
+	t.Run("RunsAfterEnvFailure", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "build", Script: "1"}}, nil)
+		job.Env = map[string]string{"BROKEN": "${{ undefinedVariable.value }}"}
+		job.Post = []*Step{{Name: "cleanup", Script: "1", If: "${{ job.status() == 'Fail' }}"}}
+		result, jobStatus := runJob(t, job)
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected job to fail")
+		}
+		if jobStatus.GetChild("build").Status() != core.Skipped {
+			t.Errorf("Expected step to be skipped, got %s", jobStatus.GetChild("build").Status())
+		}
+		if jobStatus.GetChild("cleanup").Status() != core.Pass {
+			t.Errorf("Expected post step to run with the parent env, got %s", jobStatus.GetChild("cleanup").Status())
+		}
+	})
+
+	t.Run("PostFailureFailsJob", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "pass", Script: "1"}}, nil)
+		job.Post = []*Step{
+			{Name: "cleanup", Script: "throw new Error('cleanup failed')"},
+			{Name: "notify", Script: "1"},
+		}
+		result, jobStatus := runJob(t, job)
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected job to fail")
+		}
+		if jobStatus.Status() != core.Fail {
+			t.Errorf("Expected job to fail, got %s", jobStatus.Status())
+		}
+		if jobStatus.GetChild("notify").Status() != core.Pass {
+			t.Errorf("Expected all post steps to run, got %s", jobStatus.GetChild("notify").Status())
+		}
+	})
+
+	t.Run("ExitCodeAndDuration", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "fail", Run: "exit 3"}}, nil)
+		job.Post = []*Step{{Name: "report", Script: "1", If: "${{ job.duration() >= 0 && job.exitCode() == 0 }}"}}
+		result, jobStatus := runJob(t, job)
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected job to fail")
+		}
+		if code := jobStatus.GetChild("fail").ExitCode(); code != 3 {
+			t.Errorf("Expected exit code 3, got %d", code)
+		}
+		if jobStatus.GetChild("report").Status() != core.Pass {
+			t.Errorf("Expected job.duration() in the expression, got %s", jobStatus.GetChild("report").Status())
+		}
+	})
+}
+
+func TestJob_If(t *testing.T) {
+	runJob := func(t *testing.T, job *Job) (*core.RunnableResult, *core.RunnableStatus) {
+		t.Helper()
+		_ = job.Precheck()
+		ctx := createTestJobRunnableContext()
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+		return result, ctx.WorkflowStatus.GetChild(job.Name)
+	}
+
+	t.Run("False", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "step1", Script: "1"}}, nil)
+		job.If = "${{ args.get('arg1') == 'full' }}"
+		result, jobStatus := runJob(t, job)
+		if result.ReturnCode != 0 {
+			t.Errorf("Expected skipped job to succeed, got %v", result.Err)
+		}
+		if jobStatus.Status() != core.Skipped {
+			t.Errorf("Expected job to be skipped, got %s", jobStatus.Status())
+		}
+		if jobStatus.GetChild("step1") != nil {
+			t.Error("Expected no step to run")
+		}
+	})
+
+	t.Run("True", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "step1", Script: "1"}}, nil)
+		job.If = "${{ args.get('arg1') == 'value1' }}"
+		result, jobStatus := runJob(t, job)
+		if result.ReturnCode != 0 || jobStatus.Status() != core.Pass {
+			t.Errorf("Expected job to pass, got %s %v", jobStatus.Status(), result.Err)
+		}
+	})
+
+	t.Run("InvalidIf", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "step1", Script: "1"}}, nil)
+		job.If = "${{ unknown.value }}"
+		result, jobStatus := runJob(t, job)
+		if result.ReturnCode == 0 || jobStatus.Status() != core.Fail {
+			t.Errorf("Expected job to fail, got %s", jobStatus.Status())
+		}
+	})
+}
+
+func TestJob_ContinueOnError(t *testing.T) {
+	t.Run("Continue", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "step1", Script: "throw new Error('failed')"}}, nil)
+		job.ContinueOnError = "${{ job.status() == 'Fail' }}"
+		_ = job.Precheck()
+		ctx := createTestJobRunnableContext()
+
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode != 0 {
+			t.Errorf("Expected job failure to be tolerated, got %v", result.Err)
+		}
+		jobStatus := ctx.WorkflowStatus.GetChild("test-job")
+		if jobStatus.Status() != core.Fail || jobStatus.Conclusion() != core.Pass {
+			t.Errorf("Expected status Fail and conclusion Pass, got %s and %s", jobStatus.Status(), jobStatus.Conclusion())
+		}
+		if !ctx.WorkflowStatus.CanContinue() {
+			t.Error("Expected the workflow to continue after the job")
+		}
+		if ctx.WorkflowStatus.FutureStatus() != core.Fail {
+			t.Error("Expected the failed step of the continued job in the workflow future status")
+		}
+	})
+
+	t.Run("NotContinue", func(t *testing.T) {
+		job := createTestJob("test-job", []*Step{{Name: "step1", Script: "throw new Error('failed')"}}, nil)
+		job.ContinueOnError = "${{ false }}"
+		_ = job.Precheck()
+		ctx := createTestJobRunnableContext()
+
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode == 0 {
+			t.Error("Expected job to fail")
+		}
+	})
+}
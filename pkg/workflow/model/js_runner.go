@@ -16,8 +16,8 @@ type JSRunner struct {
 
 func (r *JSRunner) Compile(runCtx run_context.WorkflowRunContext) error {
 	err := runCtx.JSCtx.Compile(r.Script)
-	log.Errorf("js compile error: %v", err)
 	if err != nil {
+		log.Errorf("js compile error of step %s: %v", r.Name, err)
 		r.hasError = 1
 	} else {
 		r.hasError = 2
@@ -26,7 +26,7 @@ func (r *JSRunner) Compile(runCtx run_context.WorkflowRunContext) error {
 }
 
 func (r *JSRunner) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) *core.RunnableResult {
-	retCode, output, err := runCtx.JSCtx.Run(parent, r.Script, ctx.GenerateMap())
+	retCode, output, err := runCtx.JSCtx.RunContext(ctx.GetContext(), parent, r.Script, ctx.GenerateMap())
 	if err != nil {
 		log.Errorf("failed to run js: %v", err)
 	}
@@ -34,6 +34,7 @@ func (r *JSRunner) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ct
 		Err:        err,
 		ReturnCode: retCode,
 		Output:     output,
+		Outputs:    core.ParseOutputs(output),
 	}
 }
 
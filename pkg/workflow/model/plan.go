@@ -0,0 +1,301 @@
+package workflow
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"nadleeh/pkg/common"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+	"slices"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+// minMaskedLen is the min length of the secret values which are masked in the interpolated text, shorter values like
+// true would mask too much
+const minMaskedLen = 4
+
+// planWriter writes the execution plan of a workflow, the expressions are evaluated but no step is run
+type planWriter struct {
+	out    io.Writer
+	runCtx *run_context.WorkflowRunContext
+	// secrets are the values of the secret args and envs, they're masked wherever they appear
+	secrets []string
+}
+
+// Plan writes the jobs and steps which would run with their env, with, if and run interpolated. The bash and JS
+// scripts and the plugins aren't run, the plan assumes every step passes. The secrets, including the args of type
+// secret, are masked, secure.decrypt returns the masked value afterwards. The expressions which can't be evaluated
+// before running, like the outputs of steps, are written as is with the error.
+func (w *Workflow) Plan(out io.Writer, parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) {
+	runCtx.JSCtx.JSSecCtx.MaskSecrets()
+	p := &planWriter{out: out, runCtx: runCtx}
+	p.workflow(w, parent, ctx, "")
+}
+
+func (p *planWriter) workflow(w *Workflow, parent env.Env, ctx *core.RunnableContext, indent string) {
+	workflowStatus := core.NewRunnableStatus(w.Name, "workflow")
+	workflowStatus.Start()
+	ctx.WorkflowStatus = workflowStatus
+	ctx.Jobs = core.NewOutputsContext()
+
+	p.printf(indent, "workflow %s", w.Name)
+	inner := indent + "  "
+	if len(w.WorkingDir) > 0 {
+		p.printf(inner, "working-dir: %s", w.WorkingDir)
+	}
+	if ctx.Args != nil {
+		args := ctx.Args.GetAll()
+		p.addSecrets(args)
+		p.addSecretArgs(&w.Checks, args)
+		p.writeValues(inner, "args", w.Checks.MaskSecretArgs(args))
+	}
+	if w.Concurrency != nil && w.depth == 0 {
+		group, err := w.ConcurrencyGroup(parent, ctx.Args, p.runCtx)
+		if err != nil {
+			p.printf(inner, "concurrency: %s (unresolved, %s), %s", p.mask(w.Concurrency.Group), p.errorLine(err),
+				w.Concurrency.GetMode())
+		} else {
+			p.printf(inner, "concurrency: %s, %s", p.mask(group), w.Concurrency.GetMode())
+		}
+	}
+	workflowEnv := p.env(inner, parent, w.Env, map[string]any{"arg": ctx.Args})
+
+	for _, job := range w.Jobs {
+		p.job(job, "job", workflowEnv, ctx, inner)
+	}
+	workflowStatus.Finish()
+	if w.Finally != nil {
+		p.job(w.Finally, "finally job", workflowEnv, ctx, inner)
+	}
+	p.values(inner, "outputs", workflowEnv, w.Outputs, ctx.GenerateMap())
+}
+
+func (p *planWriter) job(job *Job, kind string, parent env.Env, ctx *core.RunnableContext, indent string) {
+	jobStatus := core.NewRunnableStatus(job.Name, "job")
+	ctx.WorkflowStatus.AddChild(jobStatus)
+	defer ctx.Jobs.Record(job.Name, jobStatus, nil)
+	jobCtx := *ctx
+	jobCtx.JobStatus = jobStatus
+	jobCtx.Steps = core.NewOutputsContext()
+	jobCtx.Matrix = job.Matrix
+
+	p.printf(indent, "%s %s", kind, job.Name)
+	inner := indent + "  "
+	if len(job.Needs) > 0 {
+		p.printf(inner, "needs: %s", strings.Join(job.Needs, ", "))
+	}
+	if len(job.Matrix) > 0 {
+		matrix := make(map[string]string, len(job.Matrix))
+		for key, value := range job.Matrix {
+			matrix[key] = fmt.Sprint(value)
+		}
+		p.writeValues(inner, "matrix", matrix)
+	}
+	if job.TimeoutMinutes > 0 {
+		p.printf(inner, "timeout-minutes: %v", job.TimeoutMinutes)
+	}
+	if job.HasContinueOnError() {
+		p.printf(inner, "continue-on-error: %s", job.ContinueOnError)
+	}
+	if job.HasIf() && !p.evalIf(inner, parent, job.If, &jobCtx) {
+		jobStatus.SkippedWithReason("if evaluated as false")
+		p.printf(inner, "skipped: if evaluated as false")
+		return
+	}
+
+	jobStatus.Start()
+	jobEnv := p.env(inner, parent, job.Env, jobCtx.GenerateMap())
+	if job.UsesWorkflow() {
+		p.printf(inner, "uses: %s", job.Uses)
+		inputs := p.values(inner, "with", jobEnv, job.With, jobCtx.GenerateMap())
+		workflowCtx := &core.RunnableContext{Args: env.NewReadEnv(env.NewEmptyRWEnv(), inputs)}
+		p.workflow(job.workflow, jobEnv, workflowCtx, inner)
+	} else {
+		for i, step := range job.Steps {
+			p.step(fmt.Sprintf("step %d", i+1), step, jobEnv, &jobCtx, inner)
+		}
+	}
+	p.values(inner, "outputs", jobEnv, job.Outputs, jobCtx.GenerateMap())
+	jobStatus.Finish()
+	for i, step := range job.Post {
+		p.step(fmt.Sprintf("post step %d", i+1), step, jobEnv, &jobCtx, inner)
+	}
+}
+
+func (p *planWriter) step(kind string, step *Step, parent env.Env, ctx *core.RunnableContext, indent string) {
+	stepStatus := core.NewRunnableStatus(step.Name, "step")
+	ctx.JobStatus.AddChild(stepStatus)
+	defer ctx.Steps.Record(step.Id, stepStatus, nil)
+
+	p.printf(indent, "%s %s", kind, step.Name)
+	inner := indent + "  "
+	if len(step.Id) > 0 {
+		p.printf(inner, "id: %s", step.Id)
+	}
+	if step.inputs != nil {
+		inputs, err := step.inputs.eval(parent, p.runCtx, ctx)
+		if err != nil {
+			p.printf(inner, "inputs: unresolved, %s", p.errorLine(err))
+		} else {
+			p.writeValues(inner, "inputs", inputs)
+			inputsCtx := *ctx
+			inputsCtx.Inputs = inputs
+			ctx = &inputsCtx
+		}
+	}
+	if step.TimeoutMinutes > 0 {
+		p.printf(inner, "timeout-minutes: %v", step.TimeoutMinutes)
+	}
+	if step.HasRetry() {
+		p.printf(inner, "retry: max-attempts %d", step.Retry.MaxAttempts())
+	}
+	if step.HasContinueOnError() {
+		p.printf(inner, "continue-on-error: %s", step.ContinueOnError)
+	}
+	if step.HasIf() && !p.evalIf(inner, parent, step.If, ctx) {
+		stepStatus.SkippedWithReason("if evaluated as false")
+		p.printf(inner, "skipped: if evaluated as false")
+		return
+	}
+
+	stepStatus.Start()
+	stepEnv := p.env(inner, parent, step.Env, ctx.GenerateMap())
+	attemptCtx := *ctx
+	attemptCtx.Step = &core.StepContext{Attempt: 1}
+	switch {
+	case step.HasRun():
+		run, err := p.eval(stepEnv, step.Run, attemptCtx.GenerateMap())
+		p.writeBlock(inner, "run", run, err)
+	case step.HasScript():
+		p.writeBlock(inner, "script", step.Script, nil)
+	case step.RequirePlugin():
+		p.printf(inner, "uses: %s", step.Uses)
+		p.values(inner, "with", stepEnv, step.With, attemptCtx.GenerateMap())
+	}
+	stepStatus.Finish()
+}
+
+// evalIf writes and evaluates the if, an if which can't be evaluated is treated as true. The outputs are only known
+// after running, so an if which depends on them isn't evaluated.
+func (p *planWriter) evalIf(indent string, parent env.Env, ifExpr string, ctx *core.RunnableContext) bool {
+	if strings.Contains(ifExpr, ".outputs") {
+		p.printf(indent, "if: %s => unresolved, it depends on outputs", p.mask(ifExpr))
+		return true
+	}
+	value, err := p.runCtx.JSCtx.EvalActionScriptBool(parent, ifExpr, ctx.GenerateMap())
+	if err != nil {
+		p.printf(indent, "if: %s => unresolved, %s", p.mask(ifExpr), p.errorLine(err))
+		return true
+	}
+	p.printf(indent, "if: %s => %v", p.mask(ifExpr), value)
+	return value
+}
+
+// env writes and interpolates the envs in the name order, an env which can't be evaluated keeps its expression
+func (p *planWriter) env(indent string, parent env.Env, envs map[string]string, variables map[string]any) *env.ReadWriteEnv {
+	nadEnv := env.NewReadWriteEnv(parent, nil)
+	if len(envs) == 0 {
+		return nadEnv
+	}
+	p.printf(indent, "env:")
+	for _, key := range slices.Sorted(maps.Keys(envs)) {
+		value, err := p.eval(nadEnv, envs[key], variables)
+		nadEnv.Set(key, value)
+		p.writeValue(indent+"  ", key, value, err)
+	}
+	return nadEnv
+}
+
+// values writes and interpolates the values like with and outputs, a value which can't be evaluated keeps its
+// expression
+func (p *planWriter) values(indent string, title string, parent env.Env, values map[string]string,
+	variables map[string]any) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	p.printf(indent, "%s:", title)
+	evaluated := make(map[string]string, len(values))
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		value, err := p.eval(parent, values[key], variables)
+		evaluated[key] = value
+		p.writeValue(indent+"  ", key, value, err)
+	}
+	return evaluated
+}
+
+// eval interpolates the value, the value is returned as is with the error if it can't be evaluated
+func (p *planWriter) eval(parent env.Env, value string, variables map[string]any) (string, error) {
+	evaluated, err := p.runCtx.JSCtx.EvalActionScriptStr(parent, value, variables)
+	if err != nil {
+		return value, err
+	}
+	return evaluated, nil
+}
+
+func (p *planWriter) writeValues(indent string, title string, values map[string]string) {
+	p.printf(indent, "%s:", title)
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		p.writeValue(indent+"  ", key, values[key], nil)
+	}
+}
+
+func (p *planWriter) writeValue(indent string, key string, value string, err error) {
+	if common.IsSecretName(key) {
+		p.addSecrets(map[string]string{key: value})
+		value = common.MaskedValue
+	}
+	if err != nil {
+		p.printf(indent, "%s=%s (unresolved, %s)", key, p.mask(value), p.errorLine(err))
+		return
+	}
+	p.printf(indent, "%s=%s", key, p.mask(value))
+}
+
+func (p *planWriter) writeBlock(indent string, title string, text string, err error) {
+	if err != nil {
+		p.printf(indent, "%s: | (unresolved, %s)", title, p.errorLine(err))
+	} else {
+		p.printf(indent, "%s: |", title)
+	}
+	for _, line := range strings.Split(strings.TrimRight(p.mask(text), "\n"), "\n") {
+		p.printf(indent+"  ", "%s", line)
+	}
+}
+
+// addSecrets adds the values of the secret names
+func (p *planWriter) addSecrets(values map[string]string) {
+	for key, value := range values {
+		if common.IsSecretName(key) && len(value) >= minMaskedLen && value != common.MaskedValue {
+			p.secrets = append(p.secrets, value)
+		}
+	}
+}
+
+// addSecretArgs adds the values of the args of type secret, they're masked whatever their names and lengths are
+func (p *planWriter) addSecretArgs(checks *WorkflowCheck, args map[string]string) {
+	for _, arg := range checks.Args {
+		if value := args[arg.Name]; arg.ArgType() == ArgSecret && len(value) > 0 && value != common.MaskedValue {
+			p.secrets = append(p.secrets, value)
+		}
+	}
+}
+
+// mask replaces the secret values in the text
+func (p *planWriter) mask(text string) string {
+	for _, secret := range p.secrets {
+		text = strings.ReplaceAll(text, secret, common.MaskedValue)
+	}
+	return text
+}
+
+func (p *planWriter) errorLine(err error) string {
+	line, _, _ := strings.Cut(err.Error(), "\n")
+	return p.mask(line)
+}
+
+func (p *planWriter) printf(indent string, format string, args ...any) {
+	_, _ = fmt.Fprintf(p.out, indent+format+"\n", args...)
+}
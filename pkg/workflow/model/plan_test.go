@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"bytes"
+	"nadleeh/pkg/workflow/core"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestWorkflow_Plan(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	wf := parseMatrixWorkflow(t, `
+name: backup
+env:
+  TARGET: /tmp/backup-${{ arg.get('db') }}
+jobs:
+  dump:
+    env:
+      API_TOKEN: ${{ args.get('token') }}
+      DB_PASSWORD: hunter22
+    steps:
+      - name: pg_dump
+        id: dump
+        run: touch `+marker+` && echo "${{ args.get('db') }} ${{ args.get('token') }} $TARGET"
+      - name: check
+        script: throw new Error('must not run')
+      - name: prod only
+        if: ${{ args.get('db') == 'prod' }}
+        run: touch `+marker+`
+      - name: use output
+        if: ${{ steps.dump.outputs.file == 'dump.sql' }}
+        run: echo ${{ env.DB_PASSWORD }}
+    post:
+      - name: cleanup
+        if: ${{ job.status() == 'Pass' }}
+        run: rm -rf ${{ env.TARGET }}
+`)
+	if err := wf.Precheck(); err != nil {
+		t.Fatalf("Unexpected precheck error: %v", err)
+	}
+	args := env.NewReadEnv(env.NewEmptyRWEnv(), map[string]string{"db": "test", "token": "s3cr3t-token"})
+	var out bytes.Buffer
+	wf.Plan(&out, env.NewEmptyRWEnv(), createTestWorkflowRunContextPtrForJob(), &core.RunnableContext{Args: args})
+	plan := out.String()
+
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("Expected no step to run, got %v", err)
+	}
+	if strings.Contains(plan, "s3cr3t-token") || strings.Contains(plan, "hunter22") {
+		t.Errorf("Expected secrets to be masked, got:\n%s", plan)
+	}
+	expected := []string{
+		"TARGET=/tmp/backup-test",
+		"API_TOKEN=***",
+		"DB_PASSWORD=***",
+		`echo "test *** $TARGET"`,
+		"throw new Error('must not run')",
+		"if: ${{ args.get('db') == 'prod' }} => false",
+		"skipped: if evaluated as false",
+		"if: ${{ steps.dump.outputs.file == 'dump.sql' }} => unresolved, it depends on outputs",
+		"echo ***",
+		"if: ${{ job.status() == 'Pass' }} => true",
+		"rm -rf /tmp/backup-test",
+	}
+	for _, text := range expected {
+		if !strings.Contains(plan, text) {
+			t.Errorf("Expected %q in the plan, got:\n%s", text, plan)
+		}
+	}
+}
+
+func TestWorkflow_Plan_SecretArgs(t *testing.T) {
+	wf := parseMatrixWorkflow(t, `
+name: restore
+checks:
+  args:
+    - name: db
+      type: secret
+    - name: pin
+      type: secret
+    - name: host
+env:
+  DSN: ${{ arg.get('host') }}/${{ arg.get('db') }}
+jobs:
+  restore:
+    steps:
+      - name: psql
+        run: echo ${{ args.get('db') }} ${{ args.get('pin') }}
+`)
+	args := env.NewReadEnv(env.NewEmptyRWEnv(), map[string]string{"db": "hunter2", "pin": "42", "host": "example.com"})
+	var out bytes.Buffer
+	wf.Plan(&out, env.NewEmptyRWEnv(), createTestWorkflowRunContextPtrForJob(), &core.RunnableContext{Args: args})
+	plan := out.String()
+
+	if strings.Contains(plan, "hunter2") {
+		t.Errorf("Expected the secret arg to be masked, got:\n%s", plan)
+	}
+	for _, text := range []string{"db=***", "pin=***", "host=example.com", "DSN=example.com/***", "echo *** ***"} {
+		if !strings.Contains(plan, text) {
+			t.Errorf("Expected %q in the plan, got:\n%s", text, plan)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"nadleeh/pkg/util/js_token"
+	"nadleeh/pkg/workflow/run_context"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Position is the position of a yaml node in the workflow file, the column is 0 if it's unknown
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func nodePosition(file string, node *yaml.Node) Position {
+	return Position{File: file, Line: node.Line, Column: node.Column}
+}
+
+// IsZero returns whether the position is unknown, e.g. the definition isn't parsed from yaml
+func (p Position) IsZero() bool {
+	return p.Line == 0
+}
+
+// String formats the position like file:line:col, the file is omitted if it's unknown and the line if the position is
+// only of the file
+func (p Position) String() string {
+	if p.IsZero() {
+		return p.File
+	}
+	position := strconv.Itoa(p.Line)
+	if p.Column > 0 {
+		position = fmt.Sprintf("%s:%d", position, p.Column)
+	}
+	if len(p.File) == 0 {
+		return position
+	}
+	return p.File + ":" + position
+}
+
+// wrap annotates the error with the position, the errors joined by errors.Join are annotated one by one. An error
+// which already has a position keeps it, it's closer to the cause.
+func (p Position) wrap(err error) error {
+	if err == nil || p.IsZero() {
+		return err
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, p.wrap(e))
+		}
+		return errors.Join(errs...)
+	}
+	var positionErr *PositionError
+	if errors.As(err, &positionErr) {
+		return err
+	}
+	return &PositionError{Position: p, Err: err}
+}
+
+// PositionError is an error of the definition at the position of the workflow file
+type PositionError struct {
+	Position Position
+	Err      error
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Position, e.Err)
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// positions are the positions of a definition and of its keys, an error of a key like run or with.url is annotated with
+// the position of its value
+type positions struct {
+	self Position
+	keys map[string]Position
+}
+
+// newPositions records the positions of the node and of its keys like with.url or checks.args[0], the definition is at
+// the key if it's not nil
+func newPositions(file string, key, node *yaml.Node) positions {
+	node = resolveAlias(node)
+	p := positions{self: nodePosition(file, node), keys: make(map[string]Position)}
+	if key != nil {
+		p.self = nodePosition(file, key)
+	}
+	p.record(file, node, "")
+	return p
+}
+
+// record records the positions of the children of the node, a scalar is at its value and a collection at its key
+func (p positions) record(file string, node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			child, value := joinPath(path, node.Content[i].Value), resolveAlias(node.Content[i+1])
+			if value.Kind == yaml.ScalarNode {
+				p.keys[child] = nodePosition(file, value)
+			} else {
+				p.keys[child] = nodePosition(file, node.Content[i])
+			}
+			p.record(file, value, child)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			p.keys[child] = nodePosition(file, resolveAlias(item))
+			p.record(file, resolveAlias(item), child)
+		}
+	}
+}
+
+// of returns the position of the key like with.url, the position of its parent key or of the definition is returned if
+// the key isn't found
+func (p positions) of(key string) Position {
+	for len(key) > 0 {
+		if position, ok := p.keys[key]; ok {
+			return position
+		}
+		key = key[:max(strings.LastIndexAny(key, ".["), 0)]
+	}
+	return p.self
+}
+
+// compileExpressions scans the values by their keys and compiles their ${{ }} expressions, an invalid value is
+// reported at the position of its key
+func (p positions) compileExpressions(runCtx run_context.WorkflowRunContext, values map[string]string) error {
+	scanner := js_token.JSTokenScanner{}
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		tokens, err := scanner.Scan(values[key])
+		if err != nil {
+			errs = append(errs, p.of(key).wrap(fmt.Errorf("invalid expression of %s: %w", key, err)))
+			continue
+		}
+		for _, token := range tokens {
+			if token.Type != js_token.VarString {
+				continue
+			}
+			if err = runCtx.JSCtx.Compile(token.Value); err != nil {
+				errs = append(errs, p.of(key).wrap(fmt.Errorf("invalid expression ${{ %s }} of %s: %w",
+					strings.TrimSpace(token.Value), key, err)))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expressionValues returns the values of the map keyed like with.url, they're scanned by scanExpressions
+func expressionValues(values map[string]string, prefix string, m map[string]string) map[string]string {
+	for key, value := range m {
+		values[prefix+"."+key] = value
+	}
+	return values
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// mappingValue returns the key and value nodes of the key in the mapping node, they're nil if the key isn't found
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil, nil
+}
+
+// yamlError converts the yaml error message like line 3: ... to the error at the line of the file, what tells what
+// failed to parse
+func yamlError(file string, message string, what string) error {
+	matches := yamlLinePattern.FindStringSubmatch(message)
+	if matches == nil {
+		return fmt.Errorf("failed to parse %s: %s", what, message)
+	}
+	line, _ := strconv.Atoi(matches[1])
+	return &PositionError{Position: Position{File: file, Line: line},
+		Err: fmt.Errorf("failed to parse %s: %s", what, matches[2])}
+}
+
+// decodeNode decodes the node into v, the unmarshal errors are annotated with their lines
+func decodeNode(file string, node *yaml.Node, v any, what string) error {
+	err := node.Decode(v)
+	if err == nil {
+		return nil
+	}
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return nodePosition(file, node).wrap(fmt.Errorf("failed to parse %s: %w", what, err))
+	}
+	var errs []error
+	for _, message := range typeErr.Errors {
+		errs = append(errs, yamlError(file, message, what))
+	}
+	return errors.Join(errs...)
+}
+
+// checkKnownKeys checks the keys of the mapping nodes are the yaml keys of the struct fields, the unknown keys are
+// reported at their positions. Types which unmarshal themselves and maps aren't checked, their keys are free.
+func checkKnownKeys(file string, node *yaml.Node, typ reflect.Type, path string) []error {
+	node = resolveAlias(node)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if reflect.PointerTo(typ).Implements(reflect.TypeFor[yaml.Unmarshaler]()) {
+		return nil
+	}
+	var errs []error
+	switch {
+	case typ.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(typ)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, nodePosition(file, key).wrap(unknownKeyError(key.Value, path, fields)))
+				continue
+			}
+			errs = append(errs, checkKnownKeys(file, node.Content[i+1], field, joinPath(path, key.Value))...)
+		}
+	case typ.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			errs = append(errs, checkKnownKeys(file, item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case typ.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, checkKnownKeys(file, node.Content[i+1], typ.Elem(), joinPath(path, node.Content[i].Value))...)
+		}
+	}
+	return errs
+}
+
+// yamlFields returns the types of the struct fields by their yaml keys, a yaml.Node field holds any yaml so it isn't
+// checked
+func yamlFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = strings.ToLower(field.Name)
+		}
+		if field.Type == reflect.TypeFor[yaml.Node]() {
+			fields[name] = reflect.TypeFor[any]()
+			continue
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// unknownKeyError tells the closest known key if the unknown key looks like a typo, e.g. timeout_minutes or stpes
+func unknownKeyError(key string, path string, fields map[string]reflect.Type) error {
+	message := "unknown key " + key
+	if len(path) > 0 {
+		message += " of " + path
+	}
+	normalize := func(s string) string {
+		return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(s))
+	}
+	closest, closestDistance := "", 3
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		if distance := editDistance(normalize(name), normalize(key)); distance < closestDistance {
+			closest, closestDistance = name, distance
+		}
+	}
+	if len(closest) > 0 {
+		return fmt.Errorf("%s, did you mean %s?", message, closest)
+	}
+	return errors.New(message)
+}
+
+// editDistance returns the Levenshtein distance of the strings
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func joinPath(path string, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,190 @@
+package workflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseWorkflowFile_UnknownKeys(t *testing.T) {
+	content := `name: unknown keys
+nme: typo
+jobs:
+  build:
+    timeout_minutes: 5
+    steps:
+      - name: compile
+        run: make
+        retry:
+          attempts: 2
+          delay_seconds: 3
+      - name: test
+        runs: make test
+finally:
+  stpes: []
+`
+	_, err := ParseWorkflowFile("wf.yml", strings.NewReader(content))
+	if err == nil {
+		t.Fatal("expected unknown key errors")
+	}
+	expected := []string{
+		"wf.yml:2:1: unknown key nme, did you mean name?",
+		"wf.yml:15:3: unknown key stpes of finally, did you mean steps?",
+		"wf.yml:5:5: unknown key timeout_minutes of jobs.build, did you mean timeout-minutes?",
+		"wf.yml:11:11: unknown key delay_seconds of jobs.build.steps[0].retry",
+		"wf.yml:13:9: unknown key runs of jobs.build.steps[1], did you mean run?",
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), err)
+	}
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Errorf("expected error %q, got %q", expected[i], line)
+		}
+	}
+}
+
+func TestParseWorkflowFile_Positions(t *testing.T) {
+	t.Run("DecodeError", func(t *testing.T) {
+		content := `jobs:
+  build:
+    timeout-minutes: soon
+    steps:
+      - run: make
+`
+		_, err := ParseWorkflowFile("wf.yml", strings.NewReader(content))
+		if err == nil || !strings.HasPrefix(err.Error(), "wf.yml:3: failed to parse job build: cannot unmarshal") {
+			t.Errorf("expected the decode error at line 3, got %v", err)
+		}
+	})
+
+	t.Run("SyntaxError", func(t *testing.T) {
+		_, err := ParseWorkflowFile("wf.yml", strings.NewReader("jobs:\n  build: [\n"))
+		if err == nil || !strings.HasPrefix(err.Error(), "wf.yml:") {
+			t.Errorf("expected the syntax error at its line, got %v", err)
+		}
+	})
+
+	t.Run("UnknownNeeds", func(t *testing.T) {
+		content := `jobs:
+  build:
+    needs: [lint]
+    steps:
+      - run: make
+`
+		_, err := ParseWorkflowFile("wf.yml", strings.NewReader(content))
+		if err == nil || err.Error() != "wf.yml:3:5: job build needs unknown job lint" {
+			t.Errorf("expected the needs error at its key, got %v", err)
+		}
+	})
+
+	t.Run("NoFile", func(t *testing.T) {
+		_, err := ParseWorkflow(strings.NewReader("jobs:\n  build:\n    stps: []\n"))
+		if err == nil || err.Error() != "3:5: unknown key stps of jobs.build, did you mean steps?" {
+			t.Errorf("expected the error at line and column, got %v", err)
+		}
+	})
+}
+
+func TestWorkflow_PrecheckPositions(t *testing.T) {
+	content := `concurrency:
+  group: backup
+  mode: later
+jobs:
+  build:
+    steps:
+      - name: both
+        run: make
+        script: console.log(1)
+      - id: same
+        run: make
+      - id: same
+        run: make test
+`
+	wf, err := ParseWorkflowFile("wf.yml", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wf.Precheck()
+	if err == nil {
+		t.Fatal("expected precheck errors")
+	}
+	for _, expected := range []string{
+		"wf.yml:1:1: invalid concurrency mode later",
+		"wf.yml:7:9: multiple script/run/uses specified in step both",
+		"wf.yml:12:13: duplicate step id same in job build",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+}
+
+func TestWorkflow_CompilePositions(t *testing.T) {
+	content := `env:
+  NAME: ${{ args.name
+jobs:
+  build:
+    if: ${{ args.build }}
+    steps:
+      - name: shell
+        run: |
+          if true; then
+            echo missing fi
+      - name: js
+        script: function (
+      - name: with
+        uses: minio
+        with:
+          url: ${{ args.url
+          access-key: key
+          secret-key: secret
+          bucket: backup
+`
+	wf, err := ParseWorkflowFile("wf.yml", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = wf.Precheck(); err != nil {
+		t.Fatal(err)
+	}
+	runCtx := createTestWorkflowRunContextForBash()
+	err = wf.Compile(runCtx)
+	if err == nil {
+		t.Fatal("expected compile errors")
+	}
+	for _, expected := range []string{
+		"wf.yml:2:9: invalid expression of env.NAME: unclosed variable",
+		"wf.yml:8:14: compile shell error",
+		"wf.yml:12:17: ",
+		"wf.yml:16:16: invalid expression of with.url: unclosed variable",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "wf.yml:5:") {
+		t.Errorf("expected no error of the valid if, got %v", err)
+	}
+}
+
+func TestPosition_wrap(t *testing.T) {
+	position := Position{File: "wf.yml", Line: 3, Column: 5}
+	if err := position.wrap(nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	if err := (Position{}).wrap(errors.New("no position")); err.Error() != "no position" {
+		t.Errorf("expected the error without position, got %v", err)
+	}
+
+	inner := Position{File: "action.yml", Line: 1, Column: 1}.wrap(errors.New("inner"))
+	err := position.wrap(errors.Join(errors.New("first"), inner))
+	if err.Error() != "wf.yml:3:5: first\naction.yml:1:1: inner" {
+		t.Errorf("expected the joined errors to be annotated one by one, got %q", err.Error())
+	}
+	var positionErr *PositionError
+	if !errors.As(err, &positionErr) || positionErr.Position != position {
+		t.Errorf("expected a PositionError at %v, got %v", position, err)
+	}
+}
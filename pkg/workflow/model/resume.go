@@ -0,0 +1,79 @@
+package workflow
+
+import (
+	"fmt"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+// checkpointer returns the checkpointer of the job, the jobs of a reusable workflow are not recorded or restored
+// since the job which calls the workflow is
+func (job *Job) checkpointer(runCtx *run_context.WorkflowRunContext) *run_context.Checkpointer {
+	if job.depth > 0 {
+		return nil
+	}
+	return runCtx.Checkpointer
+}
+
+// restore marks the job as passed without running it if it passed in the resumed run, the outputs are restored
+func (job *Job) restore(runCtx *run_context.WorkflowRunContext, jobStatus *core.RunnableStatus) (*core.RunnableResult, bool) {
+	checkpointer := job.checkpointer(runCtx)
+	jobCheckpoint := checkpointer.PassedJob(job.Name)
+	if jobCheckpoint == nil {
+		return nil, false
+	}
+	log.Infof("job %s passed in run %s, restore it", job.Name, checkpointer.ResumedFrom())
+	jobStatus.Restored(checkpointer.ResumedFrom())
+	result := core.NewRunnableResult(nil)
+	result.Outputs = jobCheckpoint.Outputs
+	return result, true
+}
+
+// restore restores the step if it passed or was skipped in the resumed run, the outputs and the job env after the
+// step are restored. It returns false if the step must run.
+func (step *Step) restore(jobEnv env.Env, ctx *core.RunnableContext, stepCheckpoint *run_context.StepCheckpoint,
+	runId string) bool {
+	if stepCheckpoint == nil || stepCheckpoint.Status != core.Pass && stepCheckpoint.Status != core.Skipped {
+		return false
+	}
+	stepStatus := core.NewRunnableStatus(step.Name, "step")
+	ctx.JobStatus.AddChild(stepStatus)
+	if stepCheckpoint.Status == core.Pass {
+		log.Infof("step %s passed in run %s, restore it", step.Name, runId)
+		stepStatus.Restored(runId)
+	} else if len(stepCheckpoint.SkipReason) > 0 {
+		stepStatus.SkippedWithReason(fmt.Sprintf("skipped in run %s: %s", runId, stepCheckpoint.SkipReason))
+	} else {
+		stepStatus.SkippedWithReason(fmt.Sprintf("skipped in run %s", runId))
+	}
+	for key, value := range stepCheckpoint.Env {
+		jobEnv.Set(key, value)
+	}
+	ctx.Steps.Record(step.Id, stepStatus, stepCheckpoint.Outputs)
+	return true
+}
+
+// newStepCheckpoint creates the checkpoint of the last step of the job, the env is the job env which differs from the
+// workflow env
+func newStepCheckpoint(step *Step, parent env.Env, jobEnv env.Env, ctx *core.RunnableContext,
+	result *core.RunnableResult) *run_context.StepCheckpoint {
+	children := ctx.JobStatus.Children()
+	stepStatus := children[len(children)-1]
+	stepCheckpoint := &run_context.StepCheckpoint{
+		Name:       step.Name,
+		Status:     stepStatus.Status(),
+		SkipReason: stepStatus.SkipReason(),
+		Outputs:    result.Outputs,
+		Env:        make(map[string]string),
+	}
+	parentEnvs := parent.GetAll()
+	for key, value := range jobEnv.GetAll() {
+		if parentValue, ok := parentEnvs[key]; !ok || parentValue != value {
+			stepCheckpoint.Env[key] = value
+		}
+	}
+	return stepCheckpoint
+}
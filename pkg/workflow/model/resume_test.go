@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJob_Resume(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "counter")
+	fixed := filepath.Join(dir, "fixed")
+	newJob := func() *Job {
+		job := createTestJob("backup", []*Step{
+			{Name: "dump", Id: "dump", Run: "echo dump >> " + counter + "\necho \"DUMP_FILE=/tmp/dump.sql\" >> \"$NADLEEH_ENV\"\necho \"size=42\" >> \"$NADLEEH_OUTPUT\""},
+			{Name: "optional", If: "${{ false }}", Run: "exit 1"},
+			{Name: "upload", Run: "test -f " + fixed + " && test \"$DUMP_FILE\" = /tmp/dump.sql && test \"${{ steps.dump.outputs.size }}\" = 42"},
+		}, nil)
+		job.Post = []*Step{{Name: "cleanup", Run: "echo cleanup >> " + counter}}
+		if err := job.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		return job
+	}
+	runJob := func(checkpointer *run_context.Checkpointer) (*core.RunnableResult, *core.RunnableStatus) {
+		runCtx := createTestWorkflowRunContextPtrForJob()
+		runCtx.Checkpointer = checkpointer
+		ctx := createTestJobRunnableContext()
+		ctx.Jobs = core.NewOutputsContext()
+		job := newJob()
+		result := job.Do(&mockJobEnv{data: map[string]string{}}, runCtx, ctx)
+		return result, ctx.WorkflowStatus.GetChild(job.Name)
+	}
+
+	var failed *run_context.Checkpoint
+	result, _ := runJob(run_context.NewCheckpointer("run-1", nil, func(checkpoint *run_context.Checkpoint) error {
+		failed = checkpoint
+		return nil
+	}))
+	if result.ReturnCode == 0 || failed == nil {
+		t.Fatal("Expected the job to fail with a checkpoint")
+	}
+
+	if err := os.WriteFile(fixed, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var resumed *run_context.Checkpoint
+	result, jobStatus := runJob(run_context.NewCheckpointer("run-2", failed, func(checkpoint *run_context.Checkpoint) error {
+		resumed = checkpoint
+		return nil
+	}))
+	if result.ReturnCode != 0 {
+		t.Fatalf("Expected the resumed job to pass, got %v", result.Err)
+	}
+	if data, _ := os.ReadFile(counter); strings.Count(string(data), "dump") != 1 || strings.Count(string(data), "cleanup") != 2 {
+		t.Errorf("Expected the passed step to be restored and the post step to run again, got %q", data)
+	}
+	if restoredFrom := jobStatus.GetChild("dump").RestoredFrom(); restoredFrom != "run-1" {
+		t.Errorf("Expected step dump restored from run-1, got %q", restoredFrom)
+	}
+	if reason := jobStatus.GetChild("optional").SkipReason(); reason != "skipped in run run-1: if evaluated as false" {
+		t.Errorf("Unexpected skip reason %q", reason)
+	}
+	if !resumed.Jobs["backup"].Passed || len(resumed.Jobs["backup"].Steps) != 3 {
+		t.Errorf("Expected the checkpoint of the resumed run, got %+v", resumed.Jobs["backup"])
+	}
+
+	// the passed job isn't run again
+	result, jobStatus = runJob(run_context.NewCheckpointer("run-3", resumed, nil))
+	if result.ReturnCode != 0 || jobStatus.RestoredFrom() != "run-2" || len(jobStatus.Children()) != 0 {
+		t.Errorf("Expected the passed job to be restored, got %s %v", jobStatus.RestoredFrom(), result.Err)
+	}
+}
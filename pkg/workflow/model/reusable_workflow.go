@@ -0,0 +1,119 @@
+package workflow
+
+import (
+	"fmt"
+	"nadleeh/pkg/file"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+// maxWorkflowDepth limits how deep reusable workflows can be nested, it stops workflows calling each other
+const maxWorkflowDepth = 8
+
+// isLocalUses returns whether uses refers to a local file rather than a provider reference or a plugin
+func isLocalUses(uses string) bool {
+	return strings.HasPrefix(uses, "./") || strings.HasPrefix(uses, "../") || filepath.IsAbs(uses)
+}
+
+// loadReusableWorkflow loads and parses the workflow a job uses. A local workflow is resolved against WORKFLOW_DIR,
+// any other workflow is loaded through the provider of WORKFLOW_PROVIDER, or github by default.
+func loadReusableWorkflow(uses string) (*Workflow, error) {
+	wa := &core.WorkflowArgs{}
+	yml := uses
+	if isLocalUses(uses) {
+		if !filepath.IsAbs(yml) {
+			yml = filepath.Join(os.Getenv("WORKFLOW_DIR"), yml)
+		}
+		exists, err := file.FileExists(yml)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("workflow file %s doesn't exist", yml)
+		}
+	} else {
+		provider := os.Getenv("WORKFLOW_PROVIDER")
+		if len(provider) == 0 {
+			provider = githubProvider
+		}
+		wa.Provider = &provider
+	}
+
+	log.Infof("load reusable workflow %s", yml)
+	reader, err := LoadWorkflowFile(yml, wa)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(*os.File); ok {
+		defer closer.Close()
+	}
+	return ParseWorkflowFile(yml, reader)
+}
+
+// UsesWorkflow returns whether the job calls a reusable workflow instead of running steps
+func (job *Job) UsesWorkflow() bool {
+	return len(job.Uses) > 0
+}
+
+// precheckWorkflow loads the reusable workflow of the job and validates it
+func (job *Job) precheckWorkflow() error {
+	if len(job.Steps) > 0 {
+		return fmt.Errorf("job %s can't have both uses and steps", job.Name)
+	}
+	if job.depth >= maxWorkflowDepth {
+		return fmt.Errorf("job %s uses workflow %s nested more than %d levels, check if workflows use each other", job.Name, job.Uses, maxWorkflowDepth)
+	}
+	wf, err := loadReusableWorkflow(job.Uses)
+	if err != nil {
+		return fmt.Errorf("failed to load workflow %s of job %s: %w", job.Uses, job.Name, err)
+	}
+	wf.depth = job.depth + 1
+	if err = wf.Precheck(); err != nil {
+		return fmt.Errorf("invalid workflow %s of job %s: %w", job.Uses, job.Name, err)
+	}
+	for key := range job.With {
+		if !slices.ContainsFunc(wf.Checks.Args, func(arg WorkflowArg) bool { return arg.Name == key }) {
+			log.Warnf("input %s of job %s is not an arg of workflow %s", key, job.Name, job.Uses)
+		}
+	}
+	job.workflow = wf
+	return nil
+}
+
+// callWorkflow runs the reusable workflow with the job inputs and the arg defaults as its args, the workflow status is
+// a child of the job status and the workflow outputs are returned as the job outputs
+func (job *Job) callWorkflow(jobEnv env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) *core.RunnableResult {
+	inputs, err := run_context.InterpretPluginCfg(runCtx, jobEnv, job.With, ctx.GenerateMap())
+	if err != nil {
+		log.Errorf("failed to interpret inputs of job %s: %v", job.Name, err)
+		return core.NewRunnableResult(err)
+	}
+	args, err := job.workflow.Checks.ResolveArgs(env.NewReadEnv(env.NewEmptyRWEnv(), inputs), nil)
+	if err != nil {
+		log.Errorf("failed to resolve args of workflow %s of job %s: %v", job.Uses, job.Name, err)
+		return core.NewRunnableResult(err)
+	}
+	if err = job.workflow.checkCall(jobEnv, args, runCtx); err != nil {
+		log.Errorf("failed to call workflow %s of job %s: %v", job.Uses, job.Name, err)
+		return core.NewRunnableResult(err)
+	}
+
+	workflowStatus := core.NewRunnableStatus(job.workflow.Name, "workflow")
+	ctx.JobStatus.AddChild(workflowStatus)
+	workflowCtx := &core.RunnableContext{
+		NeedOutput:   ctx.NeedOutput,
+		Args:         args,
+		Context:      ctx.Context,
+		Cancellation: ctx.Cancellation,
+		Trigger:      ctx.Trigger,
+	}
+	log.Infof("job %s calls workflow %s", job.Name, job.Uses)
+	return job.workflow.run(jobEnv, runCtx, workflowCtx, workflowStatus)
+}
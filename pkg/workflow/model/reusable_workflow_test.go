@@ -0,0 +1,162 @@
+package workflow
+
+import (
+	"nadleeh/pkg/workflow/core"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func writeReusableWorkflow(t *testing.T, dir, name, yml string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(yml), 0644); err != nil {
+		t.Fatalf("Failed to write workflow %s: %v", name, err)
+	}
+}
+
+func TestIsLocalUses(t *testing.T) {
+	tests := map[string]bool{
+		"./backup.yml":     true,
+		"../backup.yml":    true,
+		"/tmp/backup.yml":  true,
+		"backup":           false,
+		"owner/repo/steps": false,
+	}
+	for uses, expected := range tests {
+		if isLocalUses(uses) != expected {
+			t.Errorf("Expected isLocalUses(%s) to be %v", uses, expected)
+		}
+	}
+}
+
+func TestJob_UsesWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WORKFLOW_DIR", dir)
+	writeReusableWorkflow(t, dir, "backup.yml", `
+name: backup
+checks:
+  args:
+    - name: db
+      pattern: ^[a-z]+$
+jobs:
+  dump:
+    outputs:
+      file: ${{ steps.dump.outputs.file }}
+    steps:
+      - id: dump
+        run: echo "file=/tmp/${{ args.get('db') }}.sql" >> "$NADLEEH_OUTPUT"
+outputs:
+  file: ${{ jobs.dump.outputs.file }}
+`)
+
+	runWorkflow := func(t *testing.T, yml string) (*core.RunnableResult, *core.RunnableContext) {
+		t.Helper()
+		wf, err := ParseWorkflow(strings.NewReader(yml))
+		if err != nil {
+			t.Fatalf("Unexpected parse error: %v", err)
+		}
+		if err = wf.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		ctx := &core.RunnableContext{Args: &mockEnv{}}
+		result := wf.Do(env.NewReadWriteEnv(&mockEnv{}, nil), createTestWorkflowRunContextPtrForJob(), ctx)
+		return result, ctx
+	}
+
+	t.Run("InputsAndOutputs", func(t *testing.T) {
+		result, ctx := runWorkflow(t, `
+name: caller
+jobs:
+  backup:
+    uses: ./backup.yml
+    with:
+      db: ${{ "postgres" }}
+  upload:
+    needs: [backup]
+    steps:
+      - run: test "${{ jobs.backup.outputs.file }}" = "/tmp/postgres.sql"
+`)
+		if result.ReturnCode != 0 {
+			t.Fatalf("Expected workflow to pass, got %v", result.Err)
+		}
+		backup := ctx.WorkflowStatus.GetChild("backup")
+		if backup.GetChild("backup") == nil || backup.GetChild("backup").GetChild("dump") == nil {
+			t.Error("Expected the status of the called workflow under the job")
+		}
+		if ctx.WorkflowStatus.GetChild("upload").Status() != core.Pass {
+			t.Errorf("Expected upload to pass, got %s", ctx.WorkflowStatus.GetChild("upload").Reason())
+		}
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		result, ctx := runWorkflow(t, `
+name: caller
+jobs:
+  backup:
+    uses: ./backup.yml
+    with:
+      db: Postgres-14
+`)
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected workflow to fail")
+		}
+		if !strings.Contains(ctx.WorkflowStatus.GetChild("backup").Reason(), "db") {
+			t.Errorf("Expected invalid arg error, got %s", ctx.WorkflowStatus.GetChild("backup").Reason())
+		}
+	})
+
+	precheck := func(yml string) error {
+		wf, err := ParseWorkflow(strings.NewReader(yml))
+		if err != nil {
+			return err
+		}
+		return wf.Precheck()
+	}
+
+	t.Run("MissingWorkflow", func(t *testing.T) {
+		err := precheck(`
+name: caller
+jobs:
+  backup:
+    uses: ./missing.yml
+`)
+		if err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+			t.Errorf("Expected missing workflow error, got %v", err)
+		}
+	})
+
+	t.Run("UsesWithSteps", func(t *testing.T) {
+		err := precheck(`
+name: caller
+jobs:
+  backup:
+    uses: ./backup.yml
+    steps:
+      - run: echo backup
+`)
+		if err == nil || !strings.Contains(err.Error(), "both uses and steps") {
+			t.Errorf("Expected uses and steps error, got %v", err)
+		}
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		writeReusableWorkflow(t, dir, "loop.yml", `
+name: loop
+jobs:
+  again:
+    uses: ./loop.yml
+`)
+		err := precheck(`
+name: caller
+jobs:
+  loop:
+    uses: ./loop.yml
+`)
+		if err == nil || !strings.Contains(err.Error(), "nested more than") {
+			t.Errorf("Expected nested workflow error, got %v", err)
+		}
+	})
+}
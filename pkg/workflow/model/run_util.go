@@ -1,8 +1,12 @@
 package workflow
 
 import (
+	"context"
+	"errors"
 	"nadleeh/pkg/common"
 	"nadleeh/pkg/script"
+	"nadleeh/pkg/workflow/core"
+	"time"
 
 	"github.com/zhaojunlucky/golib/pkg/env"
 )
@@ -35,3 +39,30 @@ func InterpretWriteOnParentEnv(jsContext *script.JSContext, parent env.Env, envs
 
 	return common.NewWriteOnParentEnv(parent, newEnvs), nil
 }
+
+// withTimeout returns a copy of ctx which is cancelled after the given minutes, ctx is returned as is if minutes <= 0
+func withTimeout(ctx *core.RunnableContext, minutes float64) (*core.RunnableContext, context.CancelFunc) {
+	if minutes <= 0 {
+		return ctx, func() {}
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx.GetContext(), time.Duration(minutes*float64(time.Minute)))
+	newCtx := *ctx
+	newCtx.Context = timeoutCtx
+	return &newCtx, cancel
+}
+
+// isTimedOut returns whether the context of the runnable exceeded its deadline
+func isTimedOut(ctx *core.RunnableContext) bool {
+	return errors.Is(ctx.GetContext().Err(), context.DeadlineExceeded)
+}
+
+// isCancelled returns whether the context of the runnable is cancelled, e.g. the run is interrupted or another
+// instance of the fail-fast matrix failed
+func isCancelled(ctx *core.RunnableContext) bool {
+	return errors.Is(ctx.GetContext().Err(), context.Canceled)
+}
+
+// cancelCause returns why the context of the runnable is cancelled
+func cancelCause(ctx *core.RunnableContext) error {
+	return context.Cause(ctx.GetContext())
+}
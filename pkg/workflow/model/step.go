@@ -1,6 +1,7 @@
 package workflow
 
 import (
+	"errors"
 	"fmt"
 	"nadleeh/pkg/util"
 	"nadleeh/pkg/workflow/core"
@@ -9,6 +10,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zhaojunlucky/golib/pkg/env"
+	"gopkg.in/yaml.v3"
 )
 
 type Step struct {
@@ -16,23 +18,62 @@ type Step struct {
 	Id              string
 	Script          string
 	Env             map[string]string
-	ContinueOnError string `yaml:"continue-on-error"`
+	EnvFiles        []EnvFile `yaml:"env-files"`
+	ContinueOnError string    `yaml:"continue-on-error"`
 	If              string
 	Run             string
 	Uses            string
 	With            map[string]string
-	PluginPath      string `yaml:"plugin-path"`
+	PluginPath      string  `yaml:"plugin-path"`
+	TimeoutMinutes  float64 `yaml:"timeout-minutes"`
+	Retry           *StepRetry
 
 	runner core.Runnable
+	// post is true for the post steps of a job, they always run
+	post bool
+	// inputs are set when the step is a step of a composite action
+	inputs *compositeInputs
+	pos    positions
 }
 
-// Precheck validates the step definition
+// setStepPositions records the positions of the steps which are decoded from the sequence of the key
+func setStepPositions(file string, node *yaml.Node, key string, steps []*Step) {
+	_, items := mappingValue(node, key)
+	if items == nil || items.Kind != yaml.SequenceNode || len(items.Content) != len(steps) {
+		return
+	}
+	for i, step := range steps {
+		step.pos = newPositions(file, nil, items.Content[i])
+	}
+}
+
+// loadEnvFiles adds the envs of the env files of the step to its env
+func (step *Step) loadEnvFiles() error {
+	if len(step.EnvFiles) == 0 {
+		return nil
+	}
+	env, err := parseEnv(step.Env, step.EnvFiles)
+	if err != nil {
+		return step.pos.of("env-files").wrap(fmt.Errorf("invalid env-files of step %s: %w", step.Name, err))
+	}
+	step.Env = env
+	return nil
+}
+
+// Precheck validates the step definition, the errors are annotated with the positions of the step keys
 func (step *Step) Precheck() error {
 	count := util.Bool2Int(len(step.Run) > 0) + util.Bool2Int(len(step.Script) > 0) + util.Bool2Int(len(step.Uses) > 0)
 	if count > 1 {
 		err := fmt.Errorf("multiple script/run/uses specified in step %s", step.Name)
 		log.Error(err)
-		return err
+		return step.pos.self.wrap(err)
+	}
+
+	if step.HasRetry() {
+		if err := step.Retry.Precheck(); err != nil {
+			log.Errorf("invalid retry of step %s: %v", step.Name, err)
+			return step.pos.of("retry").wrap(fmt.Errorf("invalid retry of step %s: %w", step.Name, err))
+		}
 	}
 
 	if step.HasScript() {
@@ -43,18 +84,37 @@ func (step *Step) Precheck() error {
 		plug, err := plugin.NewPlugin(step.Uses, step.PluginPath, step.With)
 		if err != nil {
 			log.Errorf("failed to create plugin %s for step %s", step.Uses, step.Name)
-			return err
+			return step.pos.of("uses").wrap(err)
 		}
 		step.runner = &PluginRunner{plug: plug, StepName: step.Name, Config: step.With}
 	} else {
-		return fmt.Errorf("no script/run/uses specified in step %s", step.Name)
+		return step.pos.self.wrap(fmt.Errorf("no script/run/uses specified in step %s", step.Name))
 	}
 	return nil
 }
 
-// Compile compiles the workflow
+// Compile compiles the step and scans its expressions, the errors are annotated with the positions of the step keys
 func (step *Step) Compile(ctx run_context.WorkflowRunContext) error {
-	return step.runner.Compile(ctx)
+	errs := []error{step.pos.compileExpressions(ctx, step.expressionValues())}
+
+	key := "uses"
+	if step.HasScript() {
+		key = "script"
+	} else if step.HasRun() {
+		key = "run"
+	}
+	errs = append(errs, step.pos.of(key).wrap(step.runner.Compile(ctx)))
+	return errors.Join(errs...)
+}
+
+// expressionValues returns the values of the step which may have expressions by their keys
+func (step *Step) expressionValues() map[string]string {
+	values := map[string]string{"if": step.If, "continue-on-error": step.ContinueOnError, "run": step.Run}
+	if step.HasRetry() {
+		values["retry.retry-on"] = step.Retry.RetryOn
+	}
+	values = expressionValues(values, "env", step.Env)
+	return expressionValues(values, "with", step.With)
 }
 
 func (step *Step) HasScript() bool {
@@ -77,12 +137,33 @@ func (step *Step) HasContinueOnError() bool {
 	return len(step.ContinueOnError) > 0
 }
 
-func (step *Step) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) *core.RunnableResult {
+func (step *Step) HasRetry() bool {
+	return step.Retry != nil
+}
+
+func (step *Step) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) (result *core.RunnableResult) {
 
 	stepStatus := core.NewRunnableStatus(step.Name, "step")
 	ctx.JobStatus.AddChild(stepStatus)
+	defer func() {
+		if result != nil {
+			ctx.Steps.Record(step.Id, stepStatus, result.Outputs)
+		}
+	}()
 
-	futureStatus := ctx.JobStatus.FutureStatus()
+	if step.inputs != nil {
+		inputs, err := step.inputs.eval(parent, runCtx, ctx)
+		if err != nil {
+			log.Errorf("failed to eval inputs for step %s", step.Name)
+			stepStatus.Finish(err)
+			return core.NewRunnable(err, -1, err.Error())
+		}
+		inputsCtx := *ctx
+		inputsCtx.Inputs = inputs
+		ctx = &inputsCtx
+	}
+
+	canContinue := ctx.JobStatus.CanContinue()
 
 	var ifVal bool
 	var err error
@@ -94,17 +175,28 @@ func (step *Step) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx
 			return core.NewRunnable(err, -1, err.Error())
 		} else if !ifVal {
 			log.Errorf("step %s if evaluated as false, skip it", step.Name)
-			stepStatus.Skipped()
+			stepStatus.SkippedWithReason("if evaluated as false")
 			return core.NewRunnableResult(nil)
 		}
-	} else if futureStatus == core.Fail {
+	} else if !canContinue && !step.post {
 		log.Warnf("step %s skipped due to previous error", step.Name)
-		stepStatus.Skipped()
+		stepStatus.SkippedWithReason("a previous step failed")
 		return core.NewRunnableResult(nil)
 	}
 
 	stepStatus.Start()
 	log.Infof("start step %s", step.Name)
+	if logFile := step.openLog(runCtx, ctx, stepStatus); logFile != nil {
+		defer logFile.Close()
+		defer func() {
+			if result != nil {
+				step.writeLog(logFile, result)
+			}
+		}()
+		logCtx := *ctx
+		logCtx.Output = logFile
+		ctx = &logCtx
+	}
 
 	stepEnv, err := InterpretWriteOnParentEnv(&runCtx.JSCtx, parent, step.Env, ctx.GenerateMap())
 	if err != nil {
@@ -113,12 +205,30 @@ func (step *Step) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx
 		return core.NewRunnableResult(err)
 	}
 
-	result := step.runner.Do(stepEnv, runCtx, ctx)
+	result, attempts, timedOut := step.runAttempts(stepEnv, runCtx, ctx, stepStatus)
+	stepStatus.SetExitCode(result.ReturnCode)
 
-	if result.ReturnCode != 0 {
-		log.Errorf("step %s failed %v", step.Name, result.Err)
-		stepStatus.Finish(result.Err)
-		if step.HasContinueOnError() {
+	if result.ReturnCode == 0 {
+		stepStatus.Finish()
+	} else {
+		stepErr := result.Err
+		if attempts > 1 {
+			// the error of each attempt is recorded by the attempt status
+			stepErr = fmt.Errorf("step %s failed after %d attempts", step.Name, attempts)
+		}
+		cancelled := !timedOut && isCancelled(ctx)
+		if timedOut {
+			log.Errorf("step %s timed out %v", step.Name, result.Err)
+			stepStatus.TimedOut(stepErr)
+		} else if cancelled {
+			log.Errorf("step %s cancelled: %v", step.Name, cancelCause(ctx))
+			stepStatus.Cancel(stepErr)
+		} else {
+			log.Errorf("step %s failed %v", step.Name, result.Err)
+			stepStatus.Finish(stepErr)
+		}
+		// a cancelled step never continues
+		if step.HasContinueOnError() && !cancelled {
 			log.Debugf("step %s failed, check continue on error", step.Name)
 			value, err := step.evalContinueOnError(runCtx, stepEnv, ctx)
 			if err != nil {
@@ -126,7 +236,7 @@ func (step *Step) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx
 
 				return core.NewRunnable(err, -1, "")
 			}
-			stepStatus.ContinueOnErr = value
+			stepStatus.SetContinueOnErr(value)
 		}
 
 	}
@@ -134,6 +244,96 @@ func (step *Step) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx
 
 }
 
+// runAttempts runs the step runner, a failed run is retried as defined by the step retry.
+// It returns the result of the last attempt, the number of attempts and whether the last attempt timed out.
+func (step *Step) runAttempts(stepEnv env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext,
+	stepStatus *core.RunnableStatus) (*core.RunnableResult, int, bool) {
+	maxAttempts := step.Retry.MaxAttempts()
+	for attempt := 1; ; attempt++ {
+		var attemptStatus *core.RunnableStatus
+		if step.HasRetry() {
+			attemptStatus = core.NewRunnableStatus(fmt.Sprintf("attempt %d", attempt), "attempt")
+			stepStatus.AddChild(attemptStatus)
+			attemptStatus.Start()
+		}
+
+		stepCtx := &core.StepContext{Attempt: attempt}
+		result, timedOut := step.runAttempt(stepEnv, runCtx, ctx, stepCtx)
+		if attemptStatus != nil {
+			attemptStatus.SetExitCode(result.ReturnCode)
+		}
+		if result.ReturnCode == 0 {
+			if attemptStatus != nil {
+				attemptStatus.Finish()
+			}
+			return result, attempt, false
+		}
+
+		if attemptStatus != nil {
+			if timedOut {
+				attemptStatus.TimedOut(result.Err)
+			} else if isCancelled(ctx) {
+				attemptStatus.Cancel(result.Err)
+			} else {
+				attemptStatus.Finish(result.Err)
+			}
+		}
+		if attempt >= maxAttempts || ctx.GetContext().Err() != nil {
+			return result, attempt, timedOut
+		}
+
+		stepCtx.ExitCode = result.ReturnCode
+		if result.Err != nil {
+			stepCtx.Error = result.Err.Error()
+		}
+		if !step.shouldRetry(runCtx, stepEnv, ctx, stepCtx) {
+			return result, attempt, timedOut
+		}
+		delay := step.Retry.delayBefore(attempt + 1)
+		log.Warnf("step %s attempt %d failed, retry in %v", step.Name, attempt, delay)
+		if !sleepContext(ctx.GetContext(), delay) {
+			return result, attempt, timedOut
+		}
+		// the failed attempt is superseded by the next attempt, it must not fail the job. The last attempt is never
+		// marked, its failure is the failure of the step.
+		attemptStatus.SetContinueOnErr(true)
+	}
+}
+
+func (step *Step) runAttempt(stepEnv env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext,
+	stepCtx *core.StepContext) (*core.RunnableResult, bool) {
+	attemptCtx := *ctx
+	attemptCtx.Step = stepCtx
+	runnerCtx, cancel := withTimeout(&attemptCtx, step.TimeoutMinutes)
+	defer cancel()
+
+	result := step.runner.Do(stepEnv, runCtx, runnerCtx)
+	return result, result.ReturnCode != 0 && isTimedOut(runnerCtx)
+}
+
+func (step *Step) shouldRetry(runCtx *run_context.WorkflowRunContext, stepEnv env.Env, ctx *core.RunnableContext,
+	stepCtx *core.StepContext) bool {
+	if !step.Retry.HasRetryOn() {
+		return true
+	}
+	retryCtx := *ctx
+	retryCtx.Step = stepCtx
+	value, err := runCtx.JSCtx.EvalActionScriptBool(stepEnv, step.Retry.RetryOn, retryCtx.GenerateMap())
+	if err != nil {
+		log.Errorf("Failed to eval retry-on for step %s, error: %v", step.Name, err)
+		return false
+	}
+	log.Infof("Retry on is %v for step %s", value, step.Name)
+	return value
+}
+
+func (step *Step) skip(ctx *core.RunnableContext, reason string) {
+	stepStatus := core.NewRunnableStatus(step.Name, "step")
+	ctx.JobStatus.AddChild(stepStatus)
+	stepStatus.SkippedWithReason(reason)
+	ctx.Steps.Record(step.Id, stepStatus, nil)
+}
+
 func (step *Step) evalContinueOnError(runCtx *run_context.WorkflowRunContext, parent env.Env, ctx *core.RunnableContext) (bool, error) {
 
 	value, err := runCtx.JSCtx.EvalActionScriptBool(parent, step.ContinueOnError, ctx.GenerateMap())
@@ -0,0 +1,54 @@
+package workflow
+
+import (
+	"fmt"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var logNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// logName replaces the characters which are not safe in a file name
+func logName(name string) string {
+	name = logNamePattern.ReplaceAllString(name, "_")
+	if len(name) == 0 {
+		return "unnamed"
+	}
+	return name
+}
+
+// openLog creates the log file of the step under the step log dir like <job>/01-<step>.log, it returns nil if the
+// step logs are disabled or the file can't be created
+func (step *Step) openLog(runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext, stepStatus *core.RunnableStatus) *os.File {
+	if len(runCtx.StepLogDir) == 0 {
+		return nil
+	}
+	dir := filepath.Join(runCtx.StepLogDir, logName(ctx.JobStatus.Name()))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Warnf("failed to create log dir of step %s: %v", step.Name, err)
+		return nil
+	}
+	logPath := filepath.Join(dir, fmt.Sprintf("%02d-%s.log", len(ctx.JobStatus.Children()), logName(step.Name)))
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Warnf("failed to create log file of step %s: %v", step.Name, err)
+		return nil
+	}
+	stepStatus.SetLogFile(logPath)
+	return logFile
+}
+
+// writeLog writes the output of the runners which don't write to the step log and the error of the step
+func (step *Step) writeLog(logFile *os.File, result *core.RunnableResult) {
+	if _, ok := step.runner.(*BashRunner); !ok && len(result.Output) > 0 {
+		_, _ = fmt.Fprintln(logFile, result.Output)
+	}
+	if result.ReturnCode != 0 {
+		_, _ = fmt.Fprintf(logFile, "step %s failed with code %d: %v\n", step.Name, result.ReturnCode, result.Err)
+	}
+}
@@ -0,0 +1,59 @@
+package workflow
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogName(t *testing.T) {
+	if logName("backup (postgres, 14)") != "backup_postgres_14_" {
+		t.Errorf("Unexpected log name %s", logName("backup (postgres, 14)"))
+	}
+	if logName("") != "unnamed" {
+		t.Errorf("Expected unnamed, got %s", logName(""))
+	}
+}
+
+func TestStep_Log(t *testing.T) {
+	wf := parseMatrixWorkflow(t, `
+name: logs
+jobs:
+  backup:
+    steps:
+      - name: dump
+        run: echo "dumped"
+      - name: check
+        script: "'checked'"
+      - name: upload
+        run: echo "uploading"; exit 2
+`)
+	job := wf.Jobs[0]
+	if err := job.Precheck(); err != nil {
+		t.Fatalf("Unexpected precheck error: %v", err)
+	}
+	runCtx := createTestWorkflowRunContextPtrForJob()
+	runCtx.StepLogDir = t.TempDir()
+	ctx := createTestJobRunnableContext()
+	job.Do(&mockJobEnv{data: map[string]string{}}, runCtx, ctx)
+
+	expected := map[string]string{
+		"dump":   "dumped\n",
+		"check":  "checked\n",
+		"upload": "uploading\nstep upload failed with code 2",
+	}
+	jobStatus := ctx.WorkflowStatus.GetChild("backup")
+	for name, content := range expected {
+		logFile := jobStatus.GetChild(name).Snapshot().LogFile
+		if !strings.HasPrefix(logFile, runCtx.StepLogDir) {
+			t.Fatalf("Expected log file of step %s, got %s", name, logFile)
+		}
+		data, err := os.ReadFile(logFile)
+		if err != nil || !strings.HasPrefix(string(data), content) {
+			t.Errorf("Expected log of step %s to start with %q, got %q %v", name, content, data, err)
+		}
+	}
+	if !strings.HasSuffix(jobStatus.GetChild("dump").Snapshot().LogFile, "backup/01-dump.log") {
+		t.Errorf("Unexpected log file %s", jobStatus.GetChild("dump").Snapshot().LogFile)
+	}
+}
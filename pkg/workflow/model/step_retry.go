@@ -0,0 +1,84 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+var (
+	BackoffConstant    = "constant"
+	BackoffExponential = "exponential"
+
+	// maxBackoffShift caps the exponential backoff multiplier
+	maxBackoffShift = 16
+)
+
+// StepRetry defines how a failed step is re-run
+type StepRetry struct {
+	Attempts int    `yaml:"attempts"`
+	Delay    string `yaml:"delay"`
+	Backoff  string `yaml:"backoff"`
+	RetryOn  string `yaml:"retry-on"`
+
+	delay time.Duration
+}
+
+// Precheck validates the retry definition
+func (r *StepRetry) Precheck() error {
+	if r.Attempts < 0 {
+		return fmt.Errorf("invalid retry attempts %d, it must be a positive number", r.Attempts)
+	}
+	if len(r.Delay) > 0 {
+		delay, err := time.ParseDuration(r.Delay)
+		if err != nil {
+			return fmt.Errorf("invalid retry delay %s: %w", r.Delay, err)
+		}
+		if delay < 0 {
+			return fmt.Errorf("invalid retry delay %s, it must not be negative", r.Delay)
+		}
+		r.delay = delay
+	}
+	switch r.Backoff {
+	case "", BackoffConstant, BackoffExponential:
+	default:
+		return fmt.Errorf("invalid retry backoff %s, only %s and %s are supported", r.Backoff, BackoffConstant, BackoffExponential)
+	}
+	return nil
+}
+
+// MaxAttempts returns how many times the step runs at most
+func (r *StepRetry) MaxAttempts() int {
+	if r == nil || r.Attempts < 1 {
+		return 1
+	}
+	return r.Attempts
+}
+
+// HasRetryOn returns whether the retry is conditional
+func (r *StepRetry) HasRetryOn() bool {
+	return len(r.RetryOn) > 0
+}
+
+// delayBefore returns the delay before running the given attempt
+func (r *StepRetry) delayBefore(attempt int) time.Duration {
+	if r.Backoff != BackoffExponential || attempt <= 2 {
+		return r.delay
+	}
+	return r.delay * time.Duration(1<<min(attempt-2, maxBackoffShift))
+}
+
+// sleepContext sleeps for the given duration, it returns false if ctx is done before that
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,205 @@
+package workflow
+
+import (
+	"context"
+	"nadleeh/pkg/workflow/core"
+	"testing"
+	"time"
+)
+
+func TestStepRetry_Precheck(t *testing.T) {
+	testCases := []struct {
+		name    string
+		retry   StepRetry
+		wantErr bool
+	}{
+		{"Empty", StepRetry{}, false},
+		{"Valid", StepRetry{Attempts: 3, Delay: "1s", Backoff: BackoffExponential}, false},
+		{"Constant", StepRetry{Attempts: 2, Backoff: BackoffConstant}, false},
+		{"NegativeAttempts", StepRetry{Attempts: -1}, true},
+		{"InvalidDelay", StepRetry{Attempts: 2, Delay: "soon"}, true},
+		{"NegativeDelay", StepRetry{Attempts: 2, Delay: "-1s"}, true},
+		{"InvalidBackoff", StepRetry{Attempts: 2, Backoff: "linear"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.retry.Precheck()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Expected error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStepRetry_Delay(t *testing.T) {
+	t.Run("MaxAttempts", func(t *testing.T) {
+		var retry *StepRetry
+		if retry.MaxAttempts() != 1 {
+			t.Errorf("Expected 1 attempt for nil retry, got %d", retry.MaxAttempts())
+		}
+		retry = &StepRetry{Attempts: 4}
+		if retry.MaxAttempts() != 4 {
+			t.Errorf("Expected 4 attempts, got %d", retry.MaxAttempts())
+		}
+	})
+
+	t.Run("ConstantBackoff", func(t *testing.T) {
+		retry := &StepRetry{Attempts: 3, Delay: "2s"}
+		_ = retry.Precheck()
+		for attempt := 2; attempt <= 4; attempt++ {
+			if retry.delayBefore(attempt) != 2*time.Second {
+				t.Errorf("Expected 2s before attempt %d, got %v", attempt, retry.delayBefore(attempt))
+			}
+		}
+	})
+
+	t.Run("ExponentialBackoff", func(t *testing.T) {
+		retry := &StepRetry{Attempts: 4, Delay: "1s", Backoff: BackoffExponential}
+		_ = retry.Precheck()
+		expected := map[int]time.Duration{2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second}
+		for attempt, delay := range expected {
+			if retry.delayBefore(attempt) != delay {
+				t.Errorf("Expected %v before attempt %d, got %v", delay, attempt, retry.delayBefore(attempt))
+			}
+		}
+	})
+
+	t.Run("SleepContextCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if sleepContext(ctx, time.Minute) {
+			t.Error("Expected sleep to be interrupted by cancelled context")
+		}
+	})
+}
+
+func TestStep_Retry(t *testing.T) {
+	t.Run("SucceedAfterRetry", func(t *testing.T) {
+		step := &Step{
+			Name:   "flaky",
+			Script: "if (step.attempt < 3) { throw new Error('attempt ' + step.attempt) }",
+			Retry:  &StepRetry{Attempts: 3},
+		}
+		if err := step.Precheck(); err != nil {
+			t.Fatal(err)
+		}
+		ctx := createTestJobRunnableContext()
+
+		result := step.Do(&mockJobEnv{}, createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode != 0 {
+			t.Fatalf("Expected step to pass, got %v", result.Err)
+		}
+		stepStatus := ctx.JobStatus.GetChild("flaky")
+		for i, expected := range []string{core.Fail, core.Fail, core.Pass} {
+			if stepStatus.GetChildByIndex(i).Status() != expected {
+				t.Errorf("Expected attempt %d status %s, got %s", i+1, expected, stepStatus.GetChildByIndex(i).Status())
+			}
+		}
+		if ctx.JobStatus.FutureStatus() != core.Pass {
+			t.Errorf("Expected retried failures not to fail the job")
+		}
+	})
+
+	t.Run("FailAfterAllAttempts", func(t *testing.T) {
+		step := &Step{
+			Name:   "broken",
+			Script: "throw new Error('broken')",
+			Retry:  &StepRetry{Attempts: 2},
+		}
+		if err := step.Precheck(); err != nil {
+			t.Fatal(err)
+		}
+		ctx := createTestJobRunnableContext()
+
+		result := step.Do(&mockJobEnv{}, createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected step to fail")
+		}
+		stepStatus := ctx.JobStatus.GetChild("broken")
+		if stepStatus.Status() != core.Fail {
+			t.Errorf("Expected step status %s, got %s", core.Fail, stepStatus.Status())
+		}
+		if stepStatus.GetChild("attempt 2") == nil {
+			t.Error("Expected 2 attempts")
+		}
+	})
+
+	t.Run("RetryOnFalse", func(t *testing.T) {
+		step := &Step{
+			Name:   "no-retry",
+			Script: "throw new Error('fatal')",
+			Retry:  &StepRetry{Attempts: 3, RetryOn: "${{ !step.error.includes('fatal') }}"},
+		}
+		if err := step.Precheck(); err != nil {
+			t.Fatal(err)
+		}
+		ctx := createTestJobRunnableContext()
+
+		result := step.Do(&mockJobEnv{}, createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected step to fail")
+		}
+		stepStatus := ctx.JobStatus.GetChild("no-retry")
+		if stepStatus.GetChild("attempt 2") != nil {
+			t.Error("Expected no retry when retry-on is false")
+		}
+	})
+
+	t.Run("InvalidRetry", func(t *testing.T) {
+		step := &Step{Name: "invalid", Script: "1", Retry: &StepRetry{Attempts: 2, Backoff: "random"}}
+		if err := step.Precheck(); err == nil {
+			t.Error("Expected precheck error for invalid retry")
+		}
+	})
+}
+
+func TestStep_RetryInJob(t *testing.T) {
+	runJob := func(t *testing.T, steps []*Step) (*core.RunnableResult, *core.RunnableStatus) {
+		t.Helper()
+		for _, step := range steps {
+			if err := step.Precheck(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ctx := createTestJobRunnableContext()
+		result := createTestJob("test-job", steps, nil).Do(&mockJobEnv{data: map[string]string{}},
+			createTestWorkflowRunContextPtrForJob(), ctx)
+		return result, ctx.WorkflowStatus.GetChild("test-job")
+	}
+
+	t.Run("FailAfterAllAttempts", func(t *testing.T) {
+		result, jobStatus := runJob(t, []*Step{
+			{Name: "broken", Id: "broken", Script: "throw new Error('broken')", Retry: &StepRetry{Attempts: 2}},
+			{Name: "report", Script: "1",
+				If: "${{ steps.broken.status == 'Fail' && steps.broken.conclusion == 'Fail' && job.failure() }}"},
+		})
+		if result.ReturnCode == 0 || jobStatus.Status() != core.Fail {
+			t.Errorf("Expected the job to fail, got %s", jobStatus.Status())
+		}
+		if status := jobStatus.GetChild("report").Status(); status != core.Pass {
+			t.Errorf("Expected steps.broken and the job to report Fail, report step got %s", status)
+		}
+		broken := jobStatus.GetChild("broken")
+		if !broken.GetChild("attempt 1").ContinueOnErr || broken.GetChild("attempt 2").ContinueOnErr {
+			t.Error("Expected only the superseded attempt to continue on error")
+		}
+	})
+
+	t.Run("ContinueOnErrorAfterAllAttempts", func(t *testing.T) {
+		_, jobStatus := runJob(t, []*Step{
+			{Name: "flaky", Id: "flaky", Script: "throw new Error('flaky')", Retry: &StepRetry{Attempts: 2},
+				ContinueOnError: "${{ true }}"},
+			{Name: "next", Script: "1"},
+			{Name: "notify", Script: "1", If: "${{ job.failure() && steps.flaky.conclusion == 'Pass' }}"},
+		})
+		for _, name := range []string{"next", "notify"} {
+			if status := jobStatus.GetChild(name).Status(); status != core.Pass {
+				t.Errorf("Expected step %s to run after the allowed failure, got %s", name, status)
+			}
+		}
+	})
+}
@@ -1,30 +1,37 @@
 package workflow
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"nadleeh/pkg/util"
 	"nadleeh/pkg/workflow/core"
 	"nadleeh/pkg/workflow/run_context"
 	"os"
-	"regexp"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zhaojunlucky/golib/pkg/env"
 )
 
 type Workflow struct {
-	Name       string
-	Version    string
-	Env        map[string]string
-	Jobs       []*Job
-	WorkingDir string
-	Checks     WorkflowCheck
-}
+	Name        string
+	Version     string
+	Env         map[string]string
+	Jobs        []*Job
+	WorkingDir  string
+	Checks      WorkflowCheck
+	MaxParallel int
+	// Finally is the job which always runs after all jobs, even if the workflow failed or is cancelled
+	Finally *Job
+	// Outputs are evaluated after all jobs passed, they're the outputs of a job which uses the workflow
+	Outputs map[string]string
+	// Concurrency prevents the runs of the workflow from overlapping, it's nil if the runs may overlap
+	Concurrency *WorkflowConcurrency
+	// On defines the triggers of the workflow, it's nil if the workflow is only run by hand
+	On *WorkflowOn
 
-type WorkflowArg struct {
-	Name    string `yaml:"name"`
-	Pattern string `yaml:"pattern"`
+	// depth is how deep the workflow is nested as a reusable workflow
+	depth int
+	pos   positions
 }
 
 type WorkflowCheck struct {
@@ -42,11 +49,7 @@ func (c *WorkflowCheck) Usage() {
 	if len(c.Args) > 0 {
 		fmt.Println("\tArgs:")
 		for _, arg := range c.Args {
-			if len(arg.Pattern) > 0 {
-				fmt.Printf("\t\tRequires arg %s: %s\n", arg.Name, arg.Pattern)
-			} else {
-				fmt.Printf("\t\tOptional arg %s\n", arg.Name)
-			}
+			fmt.Printf("\t\t%s\n", arg.usage("arg"))
 		}
 	} else {
 		fmt.Println("\tNo args required")
@@ -55,11 +58,7 @@ func (c *WorkflowCheck) Usage() {
 	if len(c.Envs) > 0 {
 		fmt.Println("\tEnvironments:")
 		for _, envItem := range c.Envs {
-			if len(envItem.Pattern) > 0 {
-				fmt.Printf("\t\tRequires env %s: %s\n", envItem.Name, envItem.Pattern)
-			} else {
-				fmt.Printf("\t\tOptional env %s\n", envItem.Name)
-			}
+			fmt.Printf("\t\t%s\n", envItem.usage("env"))
 		}
 	} else {
 		fmt.Println("\tNo environments required")
@@ -73,10 +72,37 @@ func (w *Workflow) Precheck() error {
 
 	err := w.preCheck()
 	if err != nil {
+		workflowErrs = append(workflowErrs, w.pos.of("checks.requires-root").wrap(err))
+	}
+	if err = w.checkDefinition(); err != nil {
 		workflowErrs = append(workflowErrs, err)
 	}
+	if len(workflowErrs) > 0 {
+		return errors.Join(workflowErrs...)
+	}
+	return nil
+}
 
-	for _, job := range w.Jobs {
+// checkDefinition validates the definition of the workflow and its jobs, it doesn't depend on where the workflow runs
+func (w *Workflow) checkDefinition() error {
+	var workflowErrs []error
+	err := w.Checks.checkArgs(w.pos)
+	if err != nil {
+		workflowErrs = append(workflowErrs, err)
+	}
+	if w.Concurrency != nil {
+		if err = w.Concurrency.Precheck(); err != nil {
+			workflowErrs = append(workflowErrs, w.pos.of("concurrency").wrap(err))
+		}
+	}
+	if w.On != nil {
+		if err = w.On.Precheck(); err != nil {
+			workflowErrs = append(workflowErrs, w.pos.of("on").wrap(err))
+		}
+	}
+
+	for _, job := range w.allJobs() {
+		job.depth = w.depth
 		err = job.Precheck()
 		if err != nil {
 			workflowErrs = append(workflowErrs, err)
@@ -90,9 +116,9 @@ func (w *Workflow) Precheck() error {
 
 // Compile compiles the workflow
 func (w *Workflow) Compile(ctx run_context.WorkflowRunContext) error {
-	var errs []error
+	errs := []error{w.pos.compileExpressions(ctx, w.expressionValues())}
 
-	for _, job := range w.Jobs {
+	for _, job := range w.allJobs() {
 		errs = append(errs, job.Compile(ctx))
 	}
 	if len(errs) > 0 {
@@ -101,6 +127,16 @@ func (w *Workflow) Compile(ctx run_context.WorkflowRunContext) error {
 	return nil
 }
 
+// expressionValues returns the values of the workflow which may have expressions by their keys
+func (w *Workflow) expressionValues() map[string]string {
+	values := expressionValues(make(map[string]string), "env", w.Env)
+	values = expressionValues(values, "outputs", w.Outputs)
+	if w.Concurrency != nil {
+		values["concurrency.group"] = w.Concurrency.Group
+	}
+	return values
+}
+
 // PreflightCheck validates the workflow arguments and environment variables
 func (w *Workflow) PreflightCheck(parent env.Env, args env.Env,
 	workflowRunCtx *run_context.WorkflowRunContext) error {
@@ -114,7 +150,7 @@ func (w *Workflow) PreflightCheck(parent env.Env, args env.Env,
 	envErrs := w.preflightCheck(env.NewReadEnv(parent, w.Env), w.Checks.Envs)
 	errs = append(errs, envErrs...)
 
-	for _, job := range w.Jobs {
+	for _, job := range w.allJobs() {
 		err := job.PreflightCheck(parent, args, workflowRunCtx)
 		if err != nil {
 			errs = append(errs, err)
@@ -127,26 +163,33 @@ func (w *Workflow) PreflightCheck(parent env.Env, args env.Env,
 	return nil
 }
 
+// checkCall validates the private key, args and environment variables required by a reusable workflow before it's
+// called by a job
+func (w *Workflow) checkCall(parent env.Env, args env.Env, workflowRunCtx *run_context.WorkflowRunContext) error {
+	var errs []error
+	if w.Checks.PrivateKey && !workflowRunCtx.SecureCtx.HasPrivateKey() {
+		errs = append(errs, fmt.Errorf("no private key specified"))
+	}
+	errs = append(errs, w.preflightCheck(args, w.Checks.Args)...)
+	errs = append(errs, w.preflightCheck(env.NewReadEnv(parent, w.Env), w.Checks.Envs)...)
+	return errors.Join(errs...)
+}
+
+// preflightCheck validates the values of the args or envs, a missing value is an error only if it's required
 func (w *Workflow) preflightCheck(env env.Env, checks []WorkflowArg) []error {
 	envMap := env.GetAll()
 	var errs []error
 	for _, check := range checks {
-		if !util.HasKey(envMap, check.Name) {
-			errs = append(errs, fmt.Errorf("env %s is required by the workflow", check.Name))
+		value, ok := envMap[check.Name]
+		if !ok {
+			if check.IsRequired() {
+				errs = append(errs, fmt.Errorf("env %s is required by the workflow", check.Name))
+			}
 			continue
 		}
-		if len(check.Pattern) > 0 {
-			matched, err := regexp.MatchString(check.Pattern, envMap[check.Name])
-			if err != nil {
-				errs = append(errs, err)
-				continue
-			}
-			if !matched {
-				errs = append(errs, fmt.Errorf("env %s does not match pattern %s", check.Name, check.Pattern))
-			}
-
+		if err := check.Validate(value); err != nil {
+			errs = append(errs, fmt.Errorf("env %s %w", check.Name, err))
 		}
-
 	}
 	return errs
 }
@@ -156,10 +199,14 @@ func (w *Workflow) CanRun() bool {
 }
 
 func (w *Workflow) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) *core.RunnableResult {
+	return w.run(parent, runCtx, ctx, core.NewRunnableStatus(w.Name, "workflow"))
+}
 
-	workflowStatus := core.NewRunnableStatus(w.Name, "workflow")
+func (w *Workflow) run(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext,
+	workflowStatus *core.RunnableStatus) *core.RunnableResult {
 	workflowStatus.Start()
 	ctx.WorkflowStatus = workflowStatus
+	ctx.Jobs = core.NewOutputsContext()
 
 	workflowEnv, err := InterpretNadEnv(&runCtx.JSCtx, parent, w.Env, map[string]interface{}{"arg": ctx.Args})
 	if err != nil {
@@ -171,17 +218,73 @@ func (w *Workflow) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ct
 	w.changeWorkingDir(workflowEnv)
 
 	log.Infof("Run workflow: %s", w.Name)
-	for _, job := range w.Jobs {
+	errs := newJobScheduler(w.Jobs, w.MaxParallel).Run(workflowEnv, runCtx, ctx)
+	if len(errs) > 0 {
+		log.Errorf("Run workflow %s failed due to job failed", w.Name)
+	}
+	var outputs map[string]string
+	if len(errs) == 0 {
+		if outputs, err = w.evalOutputs(runCtx, workflowEnv, ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 && isCancelled(ctx) {
+		workflowStatus.Cancel(fmt.Errorf("workflow %s cancelled: %w", w.Name, cancelCause(ctx)))
+	} else {
+		workflowStatus.Finish(errs...)
+	}
 
-		ret := job.Do(workflowEnv, runCtx, ctx)
-		if ret.ReturnCode != 0 {
-			log.Errorf("Run workflow %s failed due to job %s failed", w.Name, job.Name)
-			workflowStatus.Finish(ret.Err)
-			return ret
+	if err = w.runFinally(workflowEnv, runCtx, ctx); err != nil {
+		log.Errorf("Run workflow %s failed due to finally job failed", w.Name)
+		if workflowStatus.Status() == core.Cancelled {
+			// a cancelled workflow stays cancelled, the finally error is only added to its reason
+			workflowStatus.AddError(err)
+		} else {
+			workflowStatus.Finish(err)
 		}
+		errs = append(errs, err)
 	}
-	workflowStatus.Finish([]error{}...)
-	return core.NewRunnableResult(nil)
+	if len(errs) > 0 {
+		return core.NewRunnable(errors.Join(errs...), 255, "")
+	}
+	result := core.NewRunnableResult(nil)
+	result.Outputs = outputs
+	return result
+}
+
+// evalOutputs evaluates the workflow outputs, the jobs outputs are available as jobs.<name>.outputs
+func (w *Workflow) evalOutputs(runCtx *run_context.WorkflowRunContext, workflowEnv env.Env, ctx *core.RunnableContext) (map[string]string, error) {
+	outputs, err := run_context.InterpretPluginCfg(runCtx, workflowEnv, w.Outputs, ctx.GenerateMap())
+	if err != nil {
+		log.Errorf("Failed to eval outputs of workflow %s, error: %v", w.Name, err)
+		return nil, fmt.Errorf("failed to eval outputs of workflow %s: %w", w.Name, err)
+	}
+	return outputs, nil
+}
+
+// allJobs returns the jobs followed by the finally job
+func (w *Workflow) allJobs() []*Job {
+	if w.Finally == nil {
+		return w.Jobs
+	}
+	return append(append([]*Job{}, w.Jobs...), w.Finally)
+}
+
+// runFinally runs the finally job once the workflow status is final, so workflow.status() and workflow.reason() are
+// available. The finally job doesn't inherit the cancellation of the workflow, cancelled() tells whether the run is
+// cancelled.
+func (w *Workflow) runFinally(workflowEnv env.Env, runCtx *run_context.WorkflowRunContext, ctx *core.RunnableContext) error {
+	if w.Finally == nil {
+		return nil
+	}
+	log.Infof("Run finally job of workflow %s", w.Name)
+	finallyCtx := *ctx
+	finallyCtx.Context = context.WithoutCancel(ctx.GetContext())
+	result := w.Finally.Do(workflowEnv, runCtx, &finallyCtx)
+	if result.ReturnCode != 0 {
+		return result.Err
+	}
+	return nil
 }
 
 func (w *Workflow) changeWorkingDir(workflowEnv *env.ReadWriteEnv) {
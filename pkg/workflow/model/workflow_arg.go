@@ -0,0 +1,204 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"nadleeh/pkg/common"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+const (
+	ArgString = "string"
+	ArgInt    = "int"
+	ArgBool   = "bool"
+	ArgEnum   = "enum"
+	// ArgPath is resolved to an absolute path of the current dir
+	ArgPath = "path"
+	// ArgSecret is read without echo when it's prompted
+	ArgSecret = "secret"
+)
+
+var argTypes = []string{ArgString, ArgInt, ArgBool, ArgEnum, ArgPath, ArgSecret}
+
+// WorkflowArg is an arg or env checked before the workflow runs, an arg without default is required unless required
+// is false
+type WorkflowArg struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	// Type is one of string, int, bool, enum, path and secret, it's string if it's empty
+	Type        string   `yaml:"type"`
+	Default     *string  `yaml:"default"`
+	Description string   `yaml:"description"`
+	Choices     []string `yaml:"choices"`
+	Required    *bool    `yaml:"required"`
+}
+
+// ArgPrompter reads the value of a missing required arg, e.g. from the terminal
+type ArgPrompter func(arg WorkflowArg) (string, error)
+
+// ArgType returns the type of the arg, string is the default type
+func (a *WorkflowArg) ArgType() string {
+	if len(a.Type) == 0 {
+		return ArgString
+	}
+	return a.Type
+}
+
+// IsRequired returns whether the arg must be given, it's required by default unless it has a default
+func (a *WorkflowArg) IsRequired() bool {
+	if a.Required != nil {
+		return *a.Required
+	}
+	return a.Default == nil
+}
+
+// Precheck validates the definition of the arg, the default must be a valid value of the arg
+func (a *WorkflowArg) Precheck() error {
+	if len(a.Name) == 0 {
+		return errors.New("name of the arg is required")
+	}
+	var errs []error
+	if !slices.Contains(argTypes, a.ArgType()) {
+		errs = append(errs, fmt.Errorf("invalid type %s of arg %s, it must be one of %s", a.Type, a.Name,
+			strings.Join(argTypes, ", ")))
+	}
+	if a.ArgType() == ArgEnum && len(a.Choices) == 0 {
+		errs = append(errs, fmt.Errorf("enum arg %s requires choices", a.Name))
+	} else if a.ArgType() != ArgEnum && len(a.Choices) > 0 {
+		errs = append(errs, fmt.Errorf("choices of arg %s are only allowed for type enum", a.Name))
+	}
+	if _, err := regexp.Compile(a.Pattern); err != nil {
+		errs = append(errs, fmt.Errorf("invalid pattern of arg %s: %w", a.Name, err))
+	}
+	if len(errs) == 0 && a.Default != nil {
+		if err := a.Validate(*a.Default); err != nil {
+			errs = append(errs, fmt.Errorf("default of arg %s %w", a.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate validates the value by the type, choices and pattern of the arg, the error doesn't contain the value which
+// may be a secret
+func (a *WorkflowArg) Validate(value string) error {
+	switch a.ArgType() {
+	case ArgInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return errors.New("is not an int")
+		}
+	case ArgBool:
+		if value != "true" && value != "false" {
+			return errors.New("is not true or false")
+		}
+	case ArgEnum:
+		if !slices.Contains(a.Choices, value) {
+			return fmt.Errorf("is not one of %s", strings.Join(a.Choices, ", "))
+		}
+	}
+	if len(a.Pattern) > 0 {
+		matched, err := regexp.MatchString(a.Pattern, value)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("does not match pattern %s", a.Pattern)
+		}
+	}
+	return nil
+}
+
+// usage describes the arg in a line of the workflow usage
+func (a *WorkflowArg) usage(kind string) string {
+	requirement := "Optional"
+	if a.IsRequired() {
+		requirement = "Requires"
+	}
+	line := fmt.Sprintf("%s %s %s (%s)", requirement, kind, a.Name, a.ArgType())
+	if len(a.Description) > 0 {
+		line += ": " + a.Description
+	}
+	var details []string
+	if len(a.Choices) > 0 {
+		details = append(details, "choices: "+strings.Join(a.Choices, ", "))
+	}
+	if len(a.Pattern) > 0 {
+		details = append(details, "pattern: "+a.Pattern)
+	}
+	if a.Default != nil {
+		details = append(details, "default: "+*a.Default)
+	}
+	if len(details) > 0 {
+		line += " [" + strings.Join(details, "; ") + "]"
+	}
+	return line
+}
+
+// ResolveArgs returns the args with the defaults of the missing args, the missing required args are read by prompt if
+// it's not nil. The path args are resolved to absolute paths. The args are validated by PreflightCheck later.
+func (c *WorkflowCheck) ResolveArgs(args env.Env, prompt ArgPrompter) (env.Env, error) {
+	values := args.GetAll()
+	resolved := maps.Clone(values)
+	if resolved == nil {
+		resolved = make(map[string]string)
+	}
+	for _, arg := range c.Args {
+		value, ok := values[arg.Name]
+		if !ok && arg.Default != nil {
+			value, ok = *arg.Default, true
+		} else if !ok && arg.IsRequired() && prompt != nil {
+			input, err := prompt(arg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read arg %s: %w", arg.Name, err)
+			}
+			value, ok = input, true
+		}
+		if !ok {
+			continue
+		}
+		if arg.ArgType() == ArgPath && len(value) > 0 {
+			path, err := filepath.Abs(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve path arg %s: %w", arg.Name, err)
+			}
+			value = path
+		}
+		resolved[arg.Name] = value
+	}
+	return env.NewReadEnv(env.NewEmptyRWEnv(), resolved), nil
+}
+
+// MaskSecretArgs returns a copy of the args with the values of the secret args masked
+func (c *WorkflowCheck) MaskSecretArgs(args map[string]string) map[string]string {
+	masked := maps.Clone(args)
+	for _, arg := range c.Args {
+		if _, ok := masked[arg.Name]; ok && arg.ArgType() == ArgSecret {
+			masked[arg.Name] = common.MaskedValue
+		}
+	}
+	return masked
+}
+
+// checkArgs validates the definitions of the args and envs of the checks, an env can't have a default since it's
+// not set by the checks
+func (c *WorkflowCheck) checkArgs(pos positions) error {
+	var errs []error
+	for i, arg := range c.Args {
+		errs = append(errs, pos.of(fmt.Sprintf("checks.args[%d]", i)).wrap(arg.Precheck()))
+	}
+	for i, arg := range c.Envs {
+		position := pos.of(fmt.Sprintf("checks.envs[%d]", i))
+		if arg.Default != nil {
+			errs = append(errs, pos.of(fmt.Sprintf("checks.envs[%d].default", i)).wrap(
+				fmt.Errorf("env %s can't have a default, define it in env instead", arg.Name)))
+		}
+		errs = append(errs, position.wrap(arg.Precheck()))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,186 @@
+package workflow
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestWorkflowArg_Precheck(t *testing.T) {
+	content := `checks:
+  args:
+    - name: count
+      type: int
+      default: 3
+    - name: mode
+      type: enum
+      choices: [full, incremental]
+      default: full
+    - name: level
+      type: number
+    - name: region
+      type: enum
+    - name: debug
+      type: bool
+      default: yes
+    - name: token
+      choices: [a]
+  envs:
+    - name: HOME
+      default: /root
+jobs:
+  build:
+    steps:
+      - run: make
+`
+	wf, err := ParseWorkflowFile("wf.yml", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wf.Precheck()
+	if err == nil {
+		t.Fatal("expected precheck errors")
+	}
+	for _, expected := range []string{
+		"wf.yml:10:7: invalid type number of arg level",
+		"wf.yml:12:7: enum arg region requires choices",
+		"wf.yml:14:7: default of arg debug is not true or false",
+		"wf.yml:17:7: choices of arg token are only allowed for type enum",
+		"wf.yml:21:16: env HOME can't have a default",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "arg count") || strings.Contains(err.Error(), "arg mode") {
+		t.Errorf("expected the valid args to pass, got %v", err)
+	}
+}
+
+func TestWorkflowArg_Validate(t *testing.T) {
+	tests := []struct {
+		arg      WorkflowArg
+		value    string
+		expected string
+	}{
+		{WorkflowArg{Name: "count", Type: ArgInt}, "12", ""},
+		{WorkflowArg{Name: "count", Type: ArgInt}, "twelve", "is not an int"},
+		{WorkflowArg{Name: "debug", Type: ArgBool}, "false", ""},
+		{WorkflowArg{Name: "debug", Type: ArgBool}, "no", "is not true or false"},
+		{WorkflowArg{Name: "mode", Type: ArgEnum, Choices: []string{"full", "incremental"}}, "full", ""},
+		{WorkflowArg{Name: "mode", Type: ArgEnum, Choices: []string{"full", "incremental"}}, "diff",
+			"is not one of full, incremental"},
+		{WorkflowArg{Name: "tag", Pattern: "^v[0-9]+$"}, "v1", ""},
+		{WorkflowArg{Name: "tag", Pattern: "^v[0-9]+$"}, "latest", "does not match pattern ^v[0-9]+$"},
+		{WorkflowArg{Name: "password", Type: ArgSecret}, "s3cret", ""},
+	}
+	for _, test := range tests {
+		err := test.arg.Validate(test.value)
+		if len(test.expected) == 0 && err != nil {
+			t.Errorf("expected %s of arg %s to be valid, got %v", test.value, test.arg.Name, err)
+		} else if len(test.expected) > 0 && (err == nil || err.Error() != test.expected) {
+			t.Errorf("expected %s of arg %s to fail with %q, got %v", test.value, test.arg.Name, test.expected, err)
+		}
+	}
+}
+
+func TestWorkflowArg_IsRequired(t *testing.T) {
+	value, no, yes := "1", false, true
+	tests := []struct {
+		arg      WorkflowArg
+		expected bool
+	}{
+		{WorkflowArg{Name: "plain"}, true},
+		{WorkflowArg{Name: "default", Default: &value}, false},
+		{WorkflowArg{Name: "optional", Required: &no}, false},
+		{WorkflowArg{Name: "required", Default: &value, Required: &yes}, true},
+	}
+	for _, test := range tests {
+		if test.arg.IsRequired() != test.expected {
+			t.Errorf("expected required of arg %s to be %v", test.arg.Name, test.expected)
+		}
+	}
+}
+
+func TestWorkflowCheck_ResolveArgs(t *testing.T) {
+	count, dir, no := "3", "backup", false
+	checks := &WorkflowCheck{Args: []WorkflowArg{
+		{Name: "count", Type: ArgInt, Default: &count},
+		{Name: "dir", Type: ArgPath, Default: &dir},
+		{Name: "password", Type: ArgSecret},
+		{Name: "note", Required: &no},
+		{Name: "given"},
+	}}
+	args := env.NewReadEnv(env.NewEmptyRWEnv(), map[string]string{"given": "yes", "extra": "kept"})
+
+	t.Run("NoPrompt", func(t *testing.T) {
+		resolved, err := checks.ResolveArgs(args, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := resolved.GetAll()
+		abs, _ := filepath.Abs(dir)
+		if values["count"] != "3" || values["dir"] != abs || values["given"] != "yes" || values["extra"] != "kept" {
+			t.Errorf("expected the defaults and given args, got %v", values)
+		}
+		if resolved.Contains("password") || resolved.Contains("note") {
+			t.Errorf("expected the missing args to stay missing, got %v", values)
+		}
+		errs := (&Workflow{}).preflightCheck(resolved, checks.Args)
+		if len(errs) != 1 || errs[0].Error() != "env password is required by the workflow" {
+			t.Errorf("expected only the missing required arg to fail, got %v", errs)
+		}
+	})
+
+	t.Run("Prompt", func(t *testing.T) {
+		var prompted []string
+		resolved, err := checks.ResolveArgs(args, func(arg WorkflowArg) (string, error) {
+			prompted = append(prompted, arg.Name)
+			return "s3cret", nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prompted) != 1 || prompted[0] != "password" {
+			t.Errorf("expected only the missing required arg to be prompted, got %v", prompted)
+		}
+		if resolved.Get("password") != "s3cret" {
+			t.Errorf("expected the prompted value, got %s", resolved.Get("password"))
+		}
+	})
+
+	t.Run("PromptError", func(t *testing.T) {
+		_, err := checks.ResolveArgs(args, func(arg WorkflowArg) (string, error) {
+			return "", errors.New("EOF")
+		})
+		if err == nil || err.Error() != "failed to read arg password: EOF" {
+			t.Errorf("expected the prompt error, got %v", err)
+		}
+	})
+}
+
+func TestWorkflowCheck_MaskSecretArgs(t *testing.T) {
+	checks := &WorkflowCheck{Args: []WorkflowArg{{Name: "pin", Type: ArgSecret}, {Name: "host"}}}
+	masked := checks.MaskSecretArgs(map[string]string{"pin": "1234", "host": "example.com"})
+	if masked["pin"] != "***" || masked["host"] != "example.com" {
+		t.Errorf("expected only the secret arg to be masked, got %v", masked)
+	}
+}
+
+func TestWorkflowArg_usage(t *testing.T) {
+	mode := "full"
+	arg := WorkflowArg{Name: "mode", Type: ArgEnum, Description: "backup mode", Choices: []string{"full", "diff"},
+		Default: &mode}
+	expected := "Optional arg mode (enum): backup mode [choices: full, diff; default: full]"
+	if line := arg.usage("arg"); line != expected {
+		t.Errorf("expected %q, got %q", expected, line)
+	}
+	arg = WorkflowArg{Name: "TOKEN", Pattern: "^[a-z]+$"}
+	expected = "Requires env TOKEN (string) [pattern: ^[a-z]+$]"
+	if line := arg.usage("env"); line != expected {
+		t.Errorf("expected %q, got %q", expected, line)
+	}
+}
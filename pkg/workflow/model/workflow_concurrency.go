@@ -0,0 +1,81 @@
+package workflow
+
+import (
+	"fmt"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/run_context"
+	"strings"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ConcurrencyQueue            = "queue"
+	ConcurrencySkip             = "skip"
+	ConcurrencyCancelInProgress = "cancel-in-progress"
+)
+
+// WorkflowConcurrency prevents the runs of the same group from overlapping, e.g. a cron backup which overruns. Only
+// the concurrency of the workflow which is run is enforced, the one of a reusable workflow is ignored.
+type WorkflowConcurrency struct {
+	// Group is the name of the group, it may have expressions of the args
+	Group string `yaml:"group"`
+	// Mode tells what a new run does while a run of the group is in progress: queue waits for it, skip doesn't run
+	// and cancel-in-progress cancels it and waits for it to stop. It's queue by default.
+	Mode string `yaml:"mode"`
+	// TimeoutMinutes is how long a new run waits for the run in progress, it waits forever by default
+	TimeoutMinutes float64 `yaml:"timeout-minutes"`
+}
+
+// UnmarshalYAML accepts the group name as the short form of the concurrency
+func (c *WorkflowConcurrency) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Group = node.Value
+		return nil
+	}
+	type plain WorkflowConcurrency
+	return node.Decode((*plain)(c))
+}
+
+// Precheck validates the concurrency definition
+func (c *WorkflowConcurrency) Precheck() error {
+	if len(strings.TrimSpace(c.Group)) == 0 {
+		return fmt.Errorf("invalid concurrency, group is required")
+	}
+	switch c.Mode {
+	case "", ConcurrencyQueue, ConcurrencySkip, ConcurrencyCancelInProgress:
+	default:
+		return fmt.Errorf("invalid concurrency mode %s, only %s, %s and %s are supported", c.Mode,
+			ConcurrencyQueue, ConcurrencySkip, ConcurrencyCancelInProgress)
+	}
+	if c.TimeoutMinutes < 0 {
+		return fmt.Errorf("invalid concurrency timeout-minutes %v, it must not be negative", c.TimeoutMinutes)
+	}
+	return nil
+}
+
+// GetMode returns the mode of the concurrency, it's queue if not set
+func (c *WorkflowConcurrency) GetMode() string {
+	if len(c.Mode) == 0 {
+		return ConcurrencyQueue
+	}
+	return c.Mode
+}
+
+// ConcurrencyGroup evaluates the concurrency group of the workflow with the args, it's empty if the workflow has no
+// concurrency
+func (w *Workflow) ConcurrencyGroup(parent env.Env, args env.Env, runCtx *run_context.WorkflowRunContext) (string, error) {
+	if w.Concurrency == nil {
+		return "", nil
+	}
+	ctx := &core.RunnableContext{Args: args}
+	group, err := runCtx.JSCtx.EvalActionScriptStr(parent, w.Concurrency.Group, ctx.GenerateMap())
+	if err != nil {
+		return "", fmt.Errorf("failed to eval concurrency group %s: %w", w.Concurrency.Group, err)
+	}
+	if len(strings.TrimSpace(group)) == 0 {
+		return "", fmt.Errorf("concurrency group %s is evaluated as empty", w.Concurrency.Group)
+	}
+	return group, nil
+}
@@ -0,0 +1,77 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestWorkflowConcurrency(t *testing.T) {
+	parse := func(t *testing.T, concurrency string) (*Workflow, error) {
+		t.Helper()
+		wf, err := ParseWorkflow(strings.NewReader(`
+name: backup
+concurrency:` + concurrency + `
+jobs:
+  backup:
+    steps:
+      - script: "1"
+`))
+		if err != nil {
+			t.Fatalf("Unexpected parse error: %v", err)
+		}
+		return wf, wf.Precheck()
+	}
+
+	t.Run("ShortForm", func(t *testing.T) {
+		wf, err := parse(t, " backup")
+		if err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		if wf.Concurrency.Group != "backup" || wf.Concurrency.GetMode() != ConcurrencyQueue {
+			t.Errorf("Expected group backup queued, got %+v", wf.Concurrency)
+		}
+	})
+
+	t.Run("GroupExpression", func(t *testing.T) {
+		wf, err := parse(t, `
+  group: backup-${{ args.get('db') }}
+  mode: cancel-in-progress
+  timeout-minutes: 5`)
+		if err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		args := env.NewReadEnv(env.NewEmptyRWEnv(), map[string]string{"db": "mysql"})
+		group, err := wf.ConcurrencyGroup(env.NewEmptyRWEnv(), args, createTestWorkflowRunContextPtrForJob())
+		if err != nil {
+			t.Fatalf("Unexpected group error: %v", err)
+		}
+		if group != "backup-mysql" {
+			t.Errorf("Expected group backup-mysql, got %s", group)
+		}
+		if wf.Concurrency.GetMode() != ConcurrencyCancelInProgress || wf.Concurrency.TimeoutMinutes != 5 {
+			t.Errorf("Unexpected concurrency %+v", wf.Concurrency)
+		}
+	})
+
+	t.Run("NoConcurrency", func(t *testing.T) {
+		wf := &Workflow{}
+		group, err := wf.ConcurrencyGroup(env.NewEmptyRWEnv(), env.NewEmptyRWEnv(), createTestWorkflowRunContextPtrForJob())
+		if err != nil || group != "" {
+			t.Errorf("Expected no group, got %q, %v", group, err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for concurrency, expected := range map[string]string{
+			"\n  mode: skip":                           "group is required",
+			"\n  group: backup\n  mode: abort":         "invalid concurrency mode abort",
+			"\n  group: backup\n  timeout-minutes: -1": "timeout-minutes -1",
+		} {
+			if _, err := parse(t, concurrency); err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("Expected error %q, got %v", expected, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package workflow
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"nadleeh/pkg/shell"
+	"nadleeh/pkg/util/js_token"
+	"nadleeh/pkg/workflow/run_context"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+var (
+	// referencePattern matches the getter calls like args.get('name') and the properties like env.NAME, steps.id and
+	// env['NAME'] of args, arg, env and steps. The groups are the object, the name of the getter, the property, the
+	// parenthesis of a method call and the name of the bracket form.
+	referencePattern = regexp.MustCompile(`(?:^|[^\w$.])(args|arg|env|steps)\s*(?:\.\s*get\s*\(\s*['"]([^'"]+)['"]\s*\)|` +
+		`\.\s*([A-Za-z_$][\w$]*)(\s*\()?|\[\s*['"]([^'"]+)['"]\s*])`)
+	// shellVarPattern matches $NAME and ${NAME} of a bash script, the args are envs of the bash steps
+	shellVarPattern = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)`)
+	// stepEnvPattern matches NAME=value written to $NADLEEH_ENV by a bash step
+	stepEnvPattern = regexp.MustCompile(`(?m)\b([A-Za-z_][A-Za-z0-9_]*)=.*>>\s*"?\$\{?` + shell.EnvFileEnv + `\b`)
+
+	secretKeyPattern    = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[-_]?key|access[-_]?key|private[-_]?key)`)
+	secretValuePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\bghp_[A-Za-z0-9]{36}\b`),
+		regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),
+		regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+		regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+		regexp.MustCompile(`\b\d{8,10}:[A-Za-z0-9_-]{35}\b`),
+	}
+
+	// argsMethods are the methods of args and arg in javascript, e.g. get, their properties aren't args
+	argsMethods = envMethods()
+
+	// runtimeEnvs are set by nadleeh when the workflow runs
+	runtimeEnvs = []string{"WORKFLOW_FILE", "WORKFLOW_DIR", "WORKFLOW_VERSION", "WORKFLOW_BUILD_DATE",
+		"WORKFLOW_PROVIDER", "HOME", "USER"}
+)
+
+// LintProblem is a problem of the workflow found by lint, it's at the position of the yaml node which causes it
+type LintProblem struct {
+	Position Position
+	Severity string
+	Message  string
+}
+
+func (p *LintProblem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Position, p.Severity, p.Message)
+}
+
+// Lint checks the definition of the workflow, compiles its scripts and expressions and reports the references of
+// undefined args, envs and step ids, the unused checks.args and the plaintext values which look like secrets. The
+// workflow isn't run and the environment isn't checked, e.g. checks.requires-root. The problems are sorted by their
+// positions.
+func (w *Workflow) Lint(file string, runCtx run_context.WorkflowRunContext) []*LintProblem {
+	err := w.checkDefinition()
+	if err == nil {
+		// the steps can't be compiled without their runners which are created by the check
+		err = w.Compile(runCtx)
+	}
+	problems := ErrorProblems(file, err)
+	linter := &workflowLinter{workflow: w, declaredArgs: make(map[string]bool), usedArgs: make(map[string]bool)}
+	for _, arg := range w.Checks.Args {
+		linter.declaredArgs[arg.Name] = true
+	}
+	problems = append(problems, linter.lint()...)
+	return sortProblems(problems)
+}
+
+// ErrorProblems converts the joined errors to the problems, an error without position is at the file
+func ErrorProblems(file string, err error) []*LintProblem {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var problems []*LintProblem
+		for _, e := range joined.Unwrap() {
+			problems = append(problems, ErrorProblems(file, e)...)
+		}
+		return problems
+	}
+	var positionErr *PositionError
+	if errors.As(err, &positionErr) && positionErr == err {
+		return []*LintProblem{{Position: positionErr.Position, Severity: LintError, Message: positionErr.Err.Error()}}
+	}
+	return []*LintProblem{{Position: Position{File: file}, Severity: LintError, Message: err.Error()}}
+}
+
+// sortProblems sorts the problems by their positions and removes the duplicates, e.g. of the matrix instances
+func sortProblems(problems []*LintProblem) []*LintProblem {
+	slices.SortStableFunc(problems, func(a, b *LintProblem) int {
+		return cmp.Or(cmp.Compare(a.Position.File, b.Position.File), cmp.Compare(a.Position.Line, b.Position.Line),
+			cmp.Compare(a.Position.Column, b.Position.Column))
+	})
+	return slices.CompactFunc(problems, func(a, b *LintProblem) bool {
+		return a.String() == b.String()
+	})
+}
+
+// workflowLinter checks the references and values of the workflow definitions
+type workflowLinter struct {
+	workflow     *Workflow
+	declaredArgs map[string]bool
+	usedArgs     map[string]bool
+	problems     []*LintProblem
+}
+
+// lintScope is what the values of a definition can refer to
+type lintScope struct {
+	pos     positions
+	envs    map[string]bool
+	stepIds map[string]bool
+}
+
+func (l *workflowLinter) lint() []*LintProblem {
+	w := l.workflow
+	envs := make(map[string]bool)
+	for _, name := range runtimeEnvs {
+		envs[name] = true
+	}
+	for _, check := range w.Checks.Envs {
+		envs[check.Name] = true
+	}
+	for name := range w.Env {
+		envs[name] = true
+	}
+	l.lintValues(&lintScope{pos: w.pos, envs: envs}, w.expressionValues())
+
+	for _, job := range w.allJobs() {
+		l.lintJob(job, maps.Clone(envs))
+	}
+
+	for i, arg := range w.Checks.Args {
+		if !l.usedArgs[arg.Name] {
+			l.warn(w.pos.of(fmt.Sprintf("checks.args[%d]", i)), "arg %s of checks.args is never used", arg.Name)
+		}
+		if arg.ArgType() == ArgSecret && arg.Default != nil && !strings.HasPrefix(*arg.Default, "ENC(") {
+			l.warn(w.pos.of(fmt.Sprintf("checks.args[%d].default", i)),
+				"secret arg %s has a plaintext default, encrypt it by nadleeh encrypt or remove it", arg.Name)
+		}
+	}
+	return l.problems
+}
+
+func (l *workflowLinter) lintJob(job *Job, envs map[string]bool) {
+	for name := range job.Env {
+		envs[name] = true
+	}
+	jobIds := make(map[string]bool)
+	for _, step := range job.allSteps() {
+		if len(step.Id) > 0 {
+			jobIds[step.Id] = true
+		}
+	}
+	values := job.expressionValues()
+	outputs := expressionValues(make(map[string]string), "outputs", job.Outputs)
+	for key := range outputs {
+		delete(values, key)
+	}
+	l.lintValues(&lintScope{pos: job.pos, envs: envs, stepIds: map[string]bool{}}, values)
+	l.lintValues(&lintScope{pos: job.pos, envs: envs, stepIds: jobIds}, outputs)
+
+	stepIds := make(map[string]bool)
+	for _, step := range job.allSteps() {
+		stepEnvs := maps.Clone(envs)
+		for name := range step.Env {
+			stepEnvs[name] = true
+		}
+		scope := &lintScope{pos: step.pos, envs: stepEnvs, stepIds: stepIds}
+		l.lintValues(scope, step.expressionValues())
+		if step.HasScript() {
+			l.lintScript(scope, step.Script)
+		}
+		for _, match := range stepEnvPattern.FindAllStringSubmatch(step.Run, -1) {
+			envs[match[1]] = true
+		}
+		for _, match := range shellVarPattern.FindAllStringSubmatch(step.Run, -1) {
+			l.usedArgs[match[1]] = true
+		}
+		if len(step.Id) > 0 {
+			stepIds[step.Id] = true
+		}
+	}
+}
+
+// lintValues checks the references of the expressions and the plaintext secrets of the values by their keys
+func (l *workflowLinter) lintValues(scope *lintScope, values map[string]string) {
+	scanner := js_token.JSTokenScanner{}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		value := values[key]
+		if l.lintSecret(scope.pos.of(key), key, value) {
+			continue
+		}
+		tokens, err := scanner.Scan(value)
+		if err != nil {
+			// it's reported by compile
+			continue
+		}
+		for _, token := range tokens {
+			if token.Type == js_token.VarString {
+				l.lintReferences(scope, key, token.Value)
+			}
+		}
+	}
+}
+
+// lintScript checks the references and the plaintext secrets of the javascript of a step
+func (l *workflowLinter) lintScript(scope *lintScope, script string) {
+	if !l.lintSecret(scope.pos.of("script"), "script", script) {
+		l.lintReferences(scope, "script", script)
+	}
+}
+
+// lintReferences checks the args, envs and step ids referred by the javascript are defined. The args are env.Env
+// which are only read by get, the workflow env refers to them by arg.
+func (l *workflowLinter) lintReferences(scope *lintScope, key string, js string) {
+	position := scope.pos.of(key)
+	for _, match := range referencePattern.FindAllStringSubmatch(js, -1) {
+		object, getter, method := match[1], len(match[2]) > 0, len(match[4]) > 0
+		if method {
+			// a method like env.set or a getter of a variable isn't a reference
+			continue
+		}
+		name := match[2] + match[3] + match[5]
+		switch object {
+		case "args", "arg":
+			if !getter && argsMethods[name] {
+				// a method which isn't called, e.g. passed as a function
+				continue
+			}
+			l.usedArgs[name] = true
+			if !getter {
+				l.warn(position, "%s refers to arg %s by %s.%s which is undefined, use %s.get('%s')", key, name, object,
+					name, object, name)
+			} else if !l.declaredArgs[name] {
+				l.warn(position, "%s refers to arg %s which isn't declared in checks.args", key, name)
+			}
+		case "env":
+			if key == "script" && !getter {
+				// env of a script is an object with get and set
+				continue
+			}
+			if _, ok := os.LookupEnv(name); !ok && !scope.envs[name] {
+				l.warn(position, "%s refers to env %s which isn't defined by the workflow", key, name)
+			}
+		case "steps":
+			if !getter && scope.stepIds != nil && !scope.stepIds[name] {
+				l.error(position, "%s refers to step %s which isn't the id of a step run before it", key, name)
+			}
+		}
+	}
+}
+
+// envMethods returns the names of the env.Env methods in javascript, the field names are uncapped by the js runtime
+func envMethods() map[string]bool {
+	methods := make(map[string]bool)
+	envType := reflect.TypeFor[env.Env]()
+	for i := range envType.NumMethod() {
+		name := []rune(envType.Method(i).Name)
+		name[0] = unicode.ToLower(name[0])
+		methods[string(name)] = true
+	}
+	return methods
+}
+
+// lintSecret warns the plaintext value which looks like a secret, it returns whether the value is warned
+func (l *workflowLinter) lintSecret(position Position, key string, value string) bool {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 || strings.HasPrefix(value, "ENC(") {
+		return false
+	}
+	for _, pattern := range secretValuePatterns {
+		if pattern.MatchString(value) {
+			l.warn(position, "%s has a plaintext secret, encrypt it by nadleeh encrypt or pass it by an arg or env", key)
+			return true
+		}
+	}
+	if key == "run" || key == "script" || !secretKeyPattern.MatchString(key[strings.LastIndex(key, ".")+1:]) {
+		return false
+	}
+	if strings.ContainsAny(value, "$/") || strings.HasPrefix(value, "~") || value == "true" || value == "false" {
+		// an expression, env or path of the secret
+		return false
+	}
+	l.warn(position, "%s looks like a plaintext secret, encrypt it by nadleeh encrypt or pass it by an arg or env", key)
+	return true
+}
+
+func (l *workflowLinter) warn(position Position, format string, args ...any) {
+	l.problems = append(l.problems, &LintProblem{Position: position, Severity: LintWarning,
+		Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *workflowLinter) error(position Position, format string, args ...any) {
+	l.problems = append(l.problems, &LintProblem{Position: position, Severity: LintError,
+		Message: fmt.Sprintf(format, args...)})
+}
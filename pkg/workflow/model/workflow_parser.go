@@ -1,90 +1,96 @@
 package workflow
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
-	"nadleeh/pkg/util"
-	"os"
-	"path/filepath"
-	"strings"
+	"reflect"
 
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
 type workflowDefinition struct {
-	Name       string
-	Checks     WorkflowCheck `yaml:"checks"`
-	Version    string
-	EnvFiles   []string `yaml:"env-files"`
-	Env        map[string]string
-	WorkingDir string `yaml:"working-dir"`
-	Jobs       yaml.Node
+	Name        string
+	Checks      WorkflowCheck `yaml:"checks"`
+	Version     string
+	EnvFiles    []EnvFile `yaml:"env-files"`
+	Env         map[string]string
+	WorkingDir  string `yaml:"working-dir"`
+	MaxParallel int    `yaml:"max-parallel"`
+	Jobs        yaml.Node
+	Finally     *Job
+	Outputs     map[string]string
+	Concurrency *WorkflowConcurrency
+	On          *WorkflowOn `yaml:"on"`
 }
 
-func parseEnv(env map[string]string, envFiles []string) (map[string]string, error) {
-	if env == nil {
-		env = make(map[string]string)
-	}
-	for _, envFile := range envFiles {
-		log.Debugf("check env file: %s", envFile)
-		_, err := os.Stat(envFile)
-		if os.IsNotExist(err) {
-			if !strings.HasPrefix(envFile, "/") {
-				envFile = filepath.Join(os.Getenv("HOME"), ".nadleeh", envFile)
-				_, err = os.Stat(envFile)
-				if os.IsNotExist(err) {
-					log.Fatal(fmt.Sprintf("env file %s does not exist", envFile))
-				}
-			}
-		}
-		file, err := os.Open(envFile)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		log.Debugf("parsing env file: %s", envFile)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
-				continue
-			}
-			kv := strings.SplitN(line, "=", 2)
-			if len(kv) != 2 {
-				return nil, fmt.Errorf("env file line %s is not valid", line)
-			}
-
-			key := strings.TrimSpace(kv[0])
-			value := strings.TrimSpace(kv[1])
-			if util.HasKey(env, key) {
-				log.Warnf("override env key %s from file %s", key, envFile)
-			}
-			env[key] = value
+// validateFinallyJob validates the finally job, it runs after all jobs so it can't need a job or have a matrix
+func validateFinallyJob(job *Job, jobs []*Job) error {
+	if len(job.Name) == 0 {
+		job.Name = "finally"
+	}
+	for _, other := range jobs {
+		if other.Name == job.Name {
+			return fmt.Errorf("finally job %s has the same name as a job", job.Name)
 		}
-
 	}
-
-	return env, nil
+	if len(job.Needs) > 0 {
+		return fmt.Errorf("finally job %s can't have needs", job.Name)
+	}
+	if job.Strategy != nil {
+		return fmt.Errorf("finally job %s can't have a strategy", job.Name)
+	}
+	return nil
 }
 
+// ParseWorkflow parses the workflow, the errors are annotated with their lines and columns
 func ParseWorkflow(ymlFile io.Reader) (*Workflow, error) {
+	return ParseWorkflowFile("", ymlFile)
+}
+
+// ParseWorkflowFile parses the workflow of the file, the errors are annotated with the file, lines and columns. The
+// unknown keys of the workflow, jobs and steps are rejected.
+func ParseWorkflowFile(file string, ymlFile io.Reader) (*Workflow, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(ymlFile).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, yamlError(file, err.Error(), "workflow")
+	}
+	if len(doc.Content) == 0 {
+		return nil, io.EOF
+	}
+	root := doc.Content[0]
+	if err := checkWorkflowKeys(file, root); err != nil {
+		return nil, err
+	}
 	var rawWorkflow workflowDefinition
-	if err := yaml.NewDecoder(ymlFile).Decode(&rawWorkflow); err != nil {
+	if err := decodeNode(file, root, &rawWorkflow, "workflow"); err != nil {
 		return nil, err
 	}
+	workflowPos := newPositions(file, nil, root)
 	wfEnv, err := parseEnv(rawWorkflow.Env, rawWorkflow.EnvFiles)
 	if err != nil {
-		return nil, err
+		return nil, workflowPos.of("env-files").wrap(err)
+	}
+	if rawWorkflow.MaxParallel < 0 {
+		return nil, workflowPos.of("max-parallel").wrap(
+			fmt.Errorf("invalid max-parallel %d, it must be a positive number", rawWorkflow.MaxParallel))
 	}
 	workflow := &Workflow{
-		Name:       rawWorkflow.Name,
-		Version:    rawWorkflow.Version,
-		Env:        wfEnv,
-		WorkingDir: rawWorkflow.WorkingDir,
-		Jobs:       []*Job{},
-		Checks:     rawWorkflow.Checks,
+		Name:        rawWorkflow.Name,
+		Version:     rawWorkflow.Version,
+		Env:         wfEnv,
+		WorkingDir:  rawWorkflow.WorkingDir,
+		Jobs:        []*Job{},
+		Checks:      rawWorkflow.Checks,
+		MaxParallel: rawWorkflow.MaxParallel,
+		Outputs:     rawWorkflow.Outputs,
+		Concurrency: rawWorkflow.Concurrency,
+		On:          rawWorkflow.On,
+		pos:         workflowPos,
 	}
 
 	if workflow.Version == "" {
@@ -100,15 +106,64 @@ func ParseWorkflow(ymlFile io.Reader) (*Workflow, error) {
 		var job Job
 		job.Name = node.Value
 
-		err = rawWorkflow.Jobs.Content[i+1].Decode(&job)
+		err = decodeNode(file, rawWorkflow.Jobs.Content[i+1], &job, "job "+job.Name)
 		if err != nil {
 			log.Errorf("failed to parse job %s: %v", job.Name, err)
-			return nil, fmt.Errorf("failed to parse job %s: %w", job.Name, err)
+			return nil, err
+		}
+		job.setPositions(file, node, rawWorkflow.Jobs.Content[i+1])
+
+		if job.Strategy != nil && job.Strategy.Matrix != nil {
+			instances, err := expandMatrixJob(&job, rawWorkflow.Jobs.Content[i+1])
+			if err != nil {
+				log.Errorf("failed to expand matrix of job %s: %v", job.Name, err)
+				return nil, job.pos.of("strategy").wrap(err)
+			}
+			for _, instance := range instances {
+				instance.setPositions(file, node, rawWorkflow.Jobs.Content[i+1])
+			}
+			workflow.Jobs = append(workflow.Jobs, instances...)
+			continue
 		}
 
 		workflow.Jobs = append(workflow.Jobs, &job)
 	}
 	workflow.Checks = rawWorkflow.Checks
+	resolveMatrixNeeds(workflow.Jobs)
+
+	if err = validateJobNeeds(workflow.Jobs); err != nil {
+		log.Errorf("invalid job needs: %v", err)
+		return nil, err
+	}
+
+	if rawWorkflow.Finally != nil {
+		key, value := mappingValue(root, "finally")
+		rawWorkflow.Finally.setPositions(file, key, value)
+		if err = validateFinallyJob(rawWorkflow.Finally, workflow.Jobs); err != nil {
+			log.Errorf("invalid finally job: %v", err)
+			return nil, rawWorkflow.Finally.pos.self.wrap(err)
+		}
+		workflow.Finally = rawWorkflow.Finally
+	}
+
+	var envErrs []error
+	for _, job := range workflow.allJobs() {
+		envErrs = append(envErrs, job.loadEnvFiles())
+	}
+	if err = errors.Join(envErrs...); err != nil {
+		return nil, err
+	}
 
 	return workflow, nil
 }
+
+// checkWorkflowKeys rejects the unknown keys of the workflow, its jobs and steps, all of them are reported at once
+func checkWorkflowKeys(file string, root *yaml.Node) error {
+	errs := checkKnownKeys(file, root, reflect.TypeFor[workflowDefinition](), "")
+	if _, jobs := mappingValue(root, "jobs"); jobs != nil && jobs.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(jobs.Content); i += 2 {
+			errs = append(errs, checkKnownKeys(file, jobs.Content[i+1], reflect.TypeFor[Job](), "jobs."+jobs.Content[i].Value)...)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -28,7 +28,7 @@ func TestWorkflow(t *testing.T) {
 
 func TestParseEnv(t *testing.T) {
 	t.Run("EmptyEnvAndFiles", func(t *testing.T) {
-		result, err := parseEnv(nil, []string{})
+		result, err := parseEnv(nil, nil)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -45,7 +45,7 @@ func TestParseEnv(t *testing.T) {
 			"EXISTING_KEY": "existing_value",
 			"ANOTHER_KEY":  "another_value",
 		}
-		result, err := parseEnv(existingEnv, []string{})
+		result, err := parseEnv(existingEnv, nil)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -74,7 +74,7 @@ KEY_WITH_EQUALS=value=with=equals
 			t.Fatalf("Failed to create env file: %v", err)
 		}
 
-		result, err := parseEnv(nil, []string{envFile})
+		result, err := parseEnv(nil, []EnvFile{{Path: envFile}})
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -117,7 +117,7 @@ SHARED_KEY=from_file2`
 			t.Fatalf("Failed to create env file 2: %v", err)
 		}
 
-		result, err := parseEnv(nil, []string{envFile1, envFile2})
+		result, err := parseEnv(nil, []EnvFile{{Path: envFile1}, {Path: envFile2}})
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -149,7 +149,7 @@ OVERRIDE_KEY=from_file`
 			"OVERRIDE_KEY": "from_map",
 		}
 
-		result, err := parseEnv(existingEnv, []string{envFile})
+		result, err := parseEnv(existingEnv, []EnvFile{{Path: envFile}})
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -177,7 +177,7 @@ ANOTHER_VALID=value`
 			t.Fatalf("Failed to create env file: %v", err)
 		}
 
-		_, err := parseEnv(nil, []string{envFile})
+		_, err := parseEnv(nil, []EnvFile{{Path: envFile}})
 		if err == nil {
 			t.Error("Expected error for invalid env file line")
 		}
@@ -187,9 +187,12 @@ ANOTHER_VALID=value`
 	})
 
 	t.Run("NonexistentEnvFile", func(t *testing.T) {
-		// This test will trigger log.Fatal, so we need to handle it carefully
-		// For now, we'll skip this test as it would cause the test to exit
-		t.Skip("Skipping test that would trigger log.Fatal")
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WORKFLOW_DIR", "")
+		_, err := parseEnv(nil, []EnvFile{{Path: "nonexistent.env"}})
+		if err == nil || !strings.Contains(err.Error(), "env file nonexistent.env does not exist") {
+			t.Errorf("Expected error for nonexistent env file, got: %v", err)
+		}
 	})
 
 	t.Run("EnvFileOpenError", func(t *testing.T) {
@@ -200,14 +203,10 @@ ANOTHER_VALID=value`
 			t.Fatalf("Failed to create directory: %v", err)
 		}
 
-		// parseEnv doesn't actually validate file types, it just tries to read
-		// Reading a directory will not cause an error but will return empty content
-		result, err := parseEnv(nil, []string{invalidFile})
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
-		}
-		if len(result) != 0 {
-			t.Errorf("Expected 0 env vars from directory, got %d", len(result))
+		// a directory can't be read as an env file
+		_, err := parseEnv(nil, []EnvFile{{Path: invalidFile}})
+		if err == nil || !strings.Contains(err.Error(), "is a directory") {
+			t.Errorf("Expected error reading a directory, got: %v", err)
 		}
 	})
 
@@ -232,7 +231,7 @@ KEY3=value3
 			t.Fatalf("Failed to create env file: %v", err)
 		}
 
-		result, err := parseEnv(nil, []string{envFile})
+		result, err := parseEnv(nil, []EnvFile{{Path: envFile}})
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -871,7 +870,7 @@ KEY5=value5
 		"EXISTING1": "existing_value1",
 		"EXISTING2": "existing_value2",
 	}
-	envFiles := []string{envFile}
+	envFiles := []EnvFile{{Path: envFile}}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
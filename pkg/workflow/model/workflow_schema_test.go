@@ -0,0 +1,64 @@
+package workflow
+
+import (
+	"encoding/json"
+	"nadleeh/pkg/workflow/schema"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestWorkflowSchema_Keys checks the workflow schema has the yaml keys of the workflow definition
+func TestWorkflowSchema_Keys(t *testing.T) {
+	var workflowSchema map[string]any
+	if err := json.Unmarshal([]byte(schema.WorkflowSchema), &workflowSchema); err != nil {
+		t.Fatalf("Invalid workflow schema: %v", err)
+	}
+	properties := func(path ...string) []string {
+		var node any = workflowSchema
+		for _, key := range append(path, "properties") {
+			node = node.(map[string]any)[key]
+		}
+		var keys []string
+		for key := range node.(map[string]any) {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	for _, tc := range []struct {
+		value any
+		path  []string
+	}{
+		{workflowDefinition{}, nil},
+		{Job{}, []string{"$defs", "job"}},
+		{Step{}, []string{"$defs", "step"}},
+		{StepRetry{}, []string{"$defs", "retry"}},
+		{JobStrategy{}, []string{"$defs", "strategy"}},
+		{WorkflowCheck{}, []string{"$defs", "checks"}},
+		{WorkflowArg{}, []string{"$defs", "arg"}},
+		{WorkflowConcurrency{}, []string{"$defs", "concurrency"}},
+		{WorkflowOn{}, []string{"$defs", "on"}},
+		{WorkflowSchedule{}, []string{"$defs", "on", "properties", "schedule", "items"}},
+		{WorkflowWatch{}, []string{"$defs", "on", "properties", "watch"}},
+	} {
+		typ := reflect.TypeOf(tc.value)
+		t.Run(typ.Name(), func(t *testing.T) {
+			keys := properties(tc.path...)
+			for i := 0; i < typ.NumField(); i++ {
+				field := typ.Field(i)
+				name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+				if !field.IsExported() || name == "-" {
+					continue
+				}
+				if len(name) == 0 {
+					name = strings.ToLower(field.Name)
+				}
+				if !slices.Contains(keys, name) {
+					t.Errorf("Expected key %s of %s in the workflow schema", name, typ.Name())
+				}
+			}
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package workflow
 
 import (
+	"context"
 	"fmt"
 	"nadleeh/pkg/encrypt"
+	"nadleeh/pkg/workflow/core"
 	"nadleeh/pkg/workflow/run_context"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/zhaojunlucky/golib/pkg/env"
 )
@@ -514,3 +518,166 @@ func BenchmarkWorkflow_preCheck(b *testing.B) {
 		_ = workflow.preCheck()
 	}
 }
+
+func TestWorkflow_Finally(t *testing.T) {
+	runWorkflow := func(t *testing.T, yml string) (*core.RunnableResult, *core.RunnableContext) {
+		t.Helper()
+		wf, err := ParseWorkflow(strings.NewReader(yml))
+		if err != nil {
+			t.Fatalf("Unexpected parse error: %v", err)
+		}
+		if err = wf.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		ctx := &core.RunnableContext{Args: &mockEnv{}}
+		result := wf.Do(env.NewReadWriteEnv(&mockEnv{}, nil), createTestWorkflowRunContextPtrForJob(), ctx)
+		return result, ctx
+	}
+
+	t.Run("RunsAfterFailure", func(t *testing.T) {
+		result, ctx := runWorkflow(t, `
+name: finally
+jobs:
+  backup:
+    steps:
+      - script: throw new Error("boom")
+  upload:
+    steps:
+      - script: "1"
+finally:
+  steps:
+    - name: notify
+      if: ${{ workflow.status() == 'Fail' && workflow.reason().indexOf('boom') >= 0 }}
+      script: "1"
+`)
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected workflow to fail")
+		}
+		finally := ctx.WorkflowStatus.GetChild("finally")
+		if finally == nil || finally.Status() != core.Pass {
+			t.Fatalf("Expected finally job to pass")
+		}
+		if finally.GetChild("notify").Status() != core.Pass {
+			t.Errorf("Expected notify to run, got %s", finally.GetChild("notify").Status())
+		}
+		if ctx.WorkflowStatus.GetChild("upload").Status() != core.Skipped {
+			t.Errorf("Expected upload to be skipped")
+		}
+	})
+
+	t.Run("RunsAfterCancel", func(t *testing.T) {
+		wf, err := ParseWorkflow(strings.NewReader(`
+name: finally
+jobs:
+  backup:
+    steps:
+      - run: sleep 30
+  upload:
+    steps:
+      - script: "1"
+finally:
+  steps:
+    - name: notify
+      if: ${{ cancelled() && workflow.status() == 'Cancelled' }}
+      script: "1"
+`))
+		if err != nil {
+			t.Fatalf("Unexpected parse error: %v", err)
+		}
+		if err = wf.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		cancelCtx, cancel := context.WithCancel(context.Background())
+		ctx := &core.RunnableContext{Args: &mockEnv{}, Context: cancelCtx, Cancellation: cancelCtx}
+		time.AfterFunc(100*time.Millisecond, cancel)
+		result := wf.Do(env.NewReadWriteEnv(&mockEnv{}, nil), createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected workflow to fail")
+		}
+		if ctx.WorkflowStatus.Status() != core.Cancelled {
+			t.Errorf("Expected workflow status Cancelled, got %s", ctx.WorkflowStatus.Status())
+		}
+		upload := ctx.WorkflowStatus.GetChild("upload")
+		if upload.Status() != core.Skipped || upload.SkipReason() != "workflow cancelled" {
+			t.Errorf("Expected upload to be skipped as cancelled, got %s %s", upload.Status(), upload.SkipReason())
+		}
+		if ctx.WorkflowStatus.GetChild("finally").GetChild("notify").Status() != core.Pass {
+			t.Errorf("Expected notify to run after cancel")
+		}
+	})
+
+	t.Run("FinallyFailureAfterCancel", func(t *testing.T) {
+		wf, err := ParseWorkflow(strings.NewReader(`
+name: finally
+jobs:
+  backup:
+    steps:
+      - run: sleep 30
+finally:
+  steps:
+    - name: notify
+      script: throw new Error("notify failed")
+`))
+		if err != nil {
+			t.Fatalf("Unexpected parse error: %v", err)
+		}
+		if err = wf.Precheck(); err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		cancelCtx, cancel := context.WithCancel(context.Background())
+		ctx := &core.RunnableContext{Args: &mockEnv{}, Context: cancelCtx, Cancellation: cancelCtx}
+		time.AfterFunc(100*time.Millisecond, cancel)
+		result := wf.Do(env.NewReadWriteEnv(&mockEnv{}, nil), createTestWorkflowRunContextPtrForJob(), ctx)
+
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected workflow to fail")
+		}
+		if ctx.WorkflowStatus.Status() != core.Cancelled {
+			t.Errorf("Expected workflow status Cancelled, got %s", ctx.WorkflowStatus.Status())
+		}
+		if !strings.Contains(ctx.WorkflowStatus.Reason(), "notify failed") {
+			t.Errorf("Expected the finally error in the reason, got %s", ctx.WorkflowStatus.Reason())
+		}
+	})
+
+	t.Run("FinallyFailureFailsWorkflow", func(t *testing.T) {
+		result, ctx := runWorkflow(t, `
+name: finally
+jobs:
+  backup:
+    steps:
+      - script: "1"
+finally:
+  name: cleanup
+  steps:
+    - script: throw new Error("cleanup failed")
+`)
+		if result.ReturnCode == 0 {
+			t.Fatal("Expected workflow to fail")
+		}
+		if ctx.WorkflowStatus.Status() != core.Fail {
+			t.Errorf("Expected workflow status Fail, got %s", ctx.WorkflowStatus.Status())
+		}
+		if ctx.WorkflowStatus.GetChild("cleanup").Status() != core.Fail {
+			t.Errorf("Expected finally job cleanup to fail")
+		}
+	})
+
+	t.Run("InvalidFinally", func(t *testing.T) {
+		_, err := ParseWorkflow(strings.NewReader(`
+name: finally
+jobs:
+  backup:
+    steps:
+      - script: "1"
+finally:
+  needs: [backup]
+  steps:
+    - script: "1"
+`))
+		if err == nil || !strings.Contains(err.Error(), "can't have needs") {
+			t.Errorf("Expected needs error, got %v", err)
+		}
+	})
+}
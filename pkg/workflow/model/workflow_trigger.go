@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"nadleeh/pkg/cron"
+	"nadleeh/pkg/fswatch"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultWatchDebounce is how long a changed path must be quiet before the watch triggers the workflow
+const defaultWatchDebounce = time.Second
+
+// WorkflowOn defines the triggers which run the workflow, e.g. the cron schedules which the scheduler runs it by
+type WorkflowOn struct {
+	Schedule []*WorkflowSchedule `yaml:"schedule"`
+	Watch    *WorkflowWatch      `yaml:"watch"`
+}
+
+// WorkflowSchedule is a cron schedule of the workflow, e.g. cron: "0 3 * * *" runs it at 3am every day
+type WorkflowSchedule struct {
+	Cron string `yaml:"cron"`
+}
+
+// Precheck validates the triggers
+func (o *WorkflowOn) Precheck() error {
+	var errs []error
+	for _, schedule := range o.Schedule {
+		if _, err := cron.Parse(schedule.Cron); err != nil {
+			errs = append(errs, fmt.Errorf("invalid schedule: %w", err))
+		}
+	}
+	if o.Watch != nil {
+		if err := o.Watch.Precheck(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid watch: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WorkflowWatch runs the workflow when the files of the paths change, the path is a directory or a file which may be a
+// glob like uploads/*.csv. The changed path is available as trigger.path.
+type WorkflowWatch struct {
+	Paths  []string `yaml:"paths"`
+	Events []string `yaml:"events"`
+	// Debounce is how long the changed path must be quiet, e.g. 10s, the workflow runs once for the changes in it
+	Debounce string `yaml:"debounce"`
+}
+
+// Precheck validates the paths, events and debounce
+func (w *WorkflowWatch) Precheck() error {
+	if len(w.Paths) == 0 {
+		return fmt.Errorf("paths is required")
+	}
+	for _, path := range w.Paths {
+		if len(strings.TrimSpace(path)) == 0 {
+			return fmt.Errorf("path must not be empty")
+		}
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(fswatch.Ops, event) {
+			return fmt.Errorf("invalid event %s, it must be one of %s", event, strings.Join(fswatch.Ops, ", "))
+		}
+	}
+	if _, err := w.GetDebounce(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetEvents returns the events which trigger the workflow, it's create and write by default
+func (w *WorkflowWatch) GetEvents() []string {
+	if len(w.Events) == 0 {
+		return []string{fswatch.Create, fswatch.Write}
+	}
+	return w.Events
+}
+
+// GetDebounce returns the debounce, it's 1s by default
+func (w *WorkflowWatch) GetDebounce() (time.Duration, error) {
+	if len(w.Debounce) == 0 {
+		return defaultWatchDebounce, nil
+	}
+	debounce, err := time.ParseDuration(w.Debounce)
+	if err != nil || debounce < 0 {
+		return 0, fmt.Errorf("invalid debounce %s, it must be a duration like 10s", w.Debounce)
+	}
+	return debounce, nil
+}
+
+// Schedules returns the parsed cron schedules of the workflow
+func (w *Workflow) Schedules() ([]*cron.Schedule, error) {
+	if w.On == nil {
+		return nil, nil
+	}
+	var schedules []*cron.Schedule
+	for _, schedule := range w.On.Schedule {
+		parsed, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, parsed)
+	}
+	return schedules, nil
+}
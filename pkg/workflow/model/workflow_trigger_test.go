@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+func TestWorkflowOn(t *testing.T) {
+	parse := func(t *testing.T, on string) (*Workflow, error) {
+		t.Helper()
+		wf, err := ParseWorkflow(strings.NewReader(`
+name: backup
+on:` + on + `
+jobs:
+  backup:
+    steps:
+      - script: "1"
+`))
+		if err != nil {
+			t.Fatalf("Unexpected parse error: %v", err)
+		}
+		return wf, wf.Precheck()
+	}
+
+	t.Run("Schedule", func(t *testing.T) {
+		wf, err := parse(t, `
+  schedule:
+    - cron: "0 3 * * *"
+    - cron: "@hourly"`)
+		if err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		schedules, err := wf.Schedules()
+		if err != nil {
+			t.Fatalf("Unexpected schedules error: %v", err)
+		}
+		if len(schedules) != 2 || schedules[0].String() != "0 3 * * *" || schedules[1].String() != "@hourly" {
+			t.Errorf("Unexpected schedules %v", schedules)
+		}
+	})
+
+	t.Run("InvalidCron", func(t *testing.T) {
+		_, err := parse(t, `
+  schedule:
+    - cron: "0 25 * * *"`)
+		if err == nil || !strings.Contains(err.Error(), "invalid hour \"25\"") {
+			t.Errorf("Expected invalid hour error, got %v", err)
+		}
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		wf, err := parse(t, `
+  watch:
+    paths: [uploads/*.csv]
+    events: [create]
+    debounce: 10s`)
+		if err != nil {
+			t.Fatalf("Unexpected precheck error: %v", err)
+		}
+		debounce, _ := wf.On.Watch.GetDebounce()
+		if wf.On.Watch.Paths[0] != "uploads/*.csv" || len(wf.On.Watch.GetEvents()) != 1 || debounce != 10*time.Second {
+			t.Errorf("Unexpected watch %+v", wf.On.Watch)
+		}
+	})
+
+	t.Run("WatchDefaults", func(t *testing.T) {
+		watch := &WorkflowWatch{Paths: []string{"uploads"}}
+		debounce, err := watch.GetDebounce()
+		if err != nil || debounce != time.Second || strings.Join(watch.GetEvents(), ",") != "create,write" {
+			t.Errorf("Unexpected defaults %v %v %v", watch.GetEvents(), debounce, err)
+		}
+	})
+
+	t.Run("InvalidWatch", func(t *testing.T) {
+		for watch, expected := range map[string]string{
+			"{events: [create]}":                  "paths is required",
+			"{paths: [uploads], events: [chmod]}": "invalid event chmod",
+			"{paths: [uploads], debounce: 10}":    "invalid debounce 10",
+			"{paths: [uploads], debounce: -1s}":   "invalid debounce -1s",
+		} {
+			_, err := parse(t, " {watch: "+watch+"}")
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("Expected error %q of %s, got %v", expected, watch, err)
+			}
+		}
+	})
+
+	t.Run("TriggerExpression", func(t *testing.T) {
+		ctx := createTestJobRunnableContext()
+		ctx.Trigger = map[string]string{"type": "watch", "path": "/uploads/orders.csv", "event": "create"}
+		value, err := createTestWorkflowRunContextPtrForJob().JSCtx.EvalActionScriptStr(env.NewEmptyRWEnv(),
+			"${{ trigger.path }}", ctx.GenerateMap())
+		if err != nil || value != "/uploads/orders.csv" {
+			t.Errorf("Expected the trigger path, got %s %v", value, err)
+		}
+	})
+
+	t.Run("NoTrigger", func(t *testing.T) {
+		wf := &Workflow{}
+		if schedules, err := wf.Schedules(); err != nil || len(schedules) != 0 {
+			t.Errorf("Expected no schedules, got %v %v", schedules, err)
+		}
+	})
+}
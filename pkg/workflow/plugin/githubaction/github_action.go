@@ -1,7 +1,6 @@
 package githubaction
 
 import (
-	"context"
 	"fmt"
 	"nadleeh/pkg/script"
 	"nadleeh/pkg/util"
@@ -64,13 +63,13 @@ func (g *GitHubAction) Do(parent env.Env, runCtx *run_context.WorkflowRunContext
 	client = client.WithAuthToken(g.token)
 	var pr *github.PullRequest
 	if g.pr > 0 {
-		pr, _, err = client.PullRequests.Get(context.Background(), g.organization, g.repository, g.pr)
+		pr, _, err = client.PullRequests.Get(ctx.GetContext(), g.organization, g.repository, g.pr)
 		if err != nil {
 			return core.NewRunnableResult(err)
 		}
 	}
 
-	artifacts, _, err := client.Actions.ListArtifacts(context.Background(), g.organization, g.repository, nil)
+	artifacts, _, err := client.Actions.ListArtifacts(ctx.GetContext(), g.organization, g.repository, nil)
 	if err != nil {
 		return core.NewRunnableResult(err)
 	}
@@ -96,7 +95,7 @@ func (g *GitHubAction) Do(parent env.Env, runCtx *run_context.WorkflowRunContext
 
 		log.Infof("found artifact %s", arti.GetName())
 
-		_, _, err := runCtx.ShellCtx.Run(parent, fmt.Sprintf("mkdir -p %s", g.path), false)
+		_, _, err := runCtx.ShellCtx.RunContext(ctx.GetContext(), parent, fmt.Sprintf("mkdir -p %s", g.path), false)
 		if err != nil {
 			return core.NewRunnableResult(err)
 		}
@@ -118,7 +117,9 @@ func (g *GitHubAction) Do(parent env.Env, runCtx *run_context.WorkflowRunContext
 		}
 		log.Infof("set downloaded artifact path as env %s=%s", artiEnv, artifactPath)
 		parent.Set(artiEnv, artifactPath)
-		break
+		result := core.NewRunnableResult(nil)
+		result.Outputs = map[string]string{"artifact": arti.GetName(), "path": artifactPath}
+		return result
 	}
 	return core.NewRunnableResult(nil)
 }
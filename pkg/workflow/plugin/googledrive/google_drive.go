@@ -59,7 +59,7 @@ func (g *GoogleDrive) Do(parent env.Env, runCtx *run_context.WorkflowRunContext,
 		return core.NewRunnableResult(err)
 	}
 	client := g.ServiceAccount(runCtx, g.cred)
-	srv, err := drive.NewService(context.Background(), option.WithHTTPClient(client), option.WithScopes(SCOPE))
+	srv, err := drive.NewService(ctx.GetContext(), option.WithHTTPClient(client), option.WithScopes(SCOPE))
 	if err != nil {
 		return core.NewRunnableResult(err)
 	}
@@ -73,12 +73,16 @@ func (g *GoogleDrive) Do(parent env.Env, runCtx *run_context.WorkflowRunContext,
 		Create(f).
 		Media(file).
 		ProgressUpdater(func(now, size int64) { log.Infof("%d, %d\r", now, size) }).
+		Context(ctx.GetContext()).
 		Do()
 	if err != nil {
 		return core.NewRunnableResult(err)
 	}
-	log.Infof("https://drive.google.com/file/d/%s/view?usp=drive_link", res.Id)
-	return core.NewRunnableResult(nil)
+	url := fmt.Sprintf("https://drive.google.com/file/d/%s/view?usp=drive_link", res.Id)
+	log.Info(url)
+	result := core.NewRunnableResult(nil)
+	result.Outputs = map[string]string{"id": res.Id, "url": url}
+	return result
 }
 
 // ServiceAccount : Use Service account
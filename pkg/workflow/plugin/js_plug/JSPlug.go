@@ -73,11 +73,13 @@ func (j *JSPlug) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx
 	j.Config["PLUGIN_PATH"] = j.PluginPath
 
 	plugEnv := workflow.NewWriteOnParentEnv(parent, j.Config)
-	ret, output, err := runCtx.JSCtx.RunFile(plugEnv, j.pm.MainFile, argMaps)
+	ret, output, err := runCtx.JSCtx.RunFileContext(ctx.GetContext(), plugEnv, j.pm.MainFile, argMaps)
 	if err != nil {
 		log.Errorf("plugin %s failed %v", j.PluginName, err)
 	}
-	return core.NewRunnable(err, ret, output)
+	result := core.NewRunnable(err, ret, output)
+	result.Outputs = core.ParseOutputs(output)
+	return result
 }
 
 func (j *JSPlug) CanRun() bool {
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -168,10 +169,14 @@ func formatPluginKey(name, version string) string {
 
 type PluginManager struct {
 	LoadedPlugin map[string]*PluginMetadata
+
+	mu sync.Mutex
 }
 
 func (p *PluginManager) LoadPlugin(name, version, token, localPath string) (*PluginMetadata, error) {
 	key := formatPluginKey(name, version)
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	pm := p.LoadedPlugin[key]
 	if pm != nil {
 		return pm, nil
@@ -1,7 +1,6 @@
 package minio
 
 import (
-	"context"
 	"fmt"
 	"nadleeh/pkg/workflow/core"
 	"nadleeh/pkg/workflow/run_context"
@@ -73,14 +72,16 @@ func (m *Minio) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ctx *
 		return core.NewRunnableResult(fmt.Errorf("error getting file info: %v", err))
 	}
 	defer file.Close()
-	info, err := minioClient.PutObject(context.Background(), m.Bucket, name, file, fi.Size(), minio.PutObjectOptions{})
+	info, err := minioClient.PutObject(ctx.GetContext(), m.Bucket, name, file, fi.Size(), minio.PutObjectOptions{})
 	if err != nil {
 		return core.NewRunnableResult(fmt.Errorf("error uploading file: %v", err))
 	}
 
 	log.Infof("uploaded file: %s", info.Location)
 
-	return core.NewRunnableResult(nil)
+	result := core.NewRunnableResult(nil)
+	result.Outputs = map[string]string{"bucket": m.Bucket, "name": name, "location": info.Location}
+	return result
 
 }
 
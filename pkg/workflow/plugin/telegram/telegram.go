@@ -50,7 +50,11 @@ func (t *Telegram) Do(parent env.Env, runCtx *run_context.WorkflowRunContext, ct
 	}
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage?chat_id=%s&text=%s", t.tgBotKey, t.channel, t.message)
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx.GetContext(), http.MethodGet, url, nil)
+	if err != nil {
+		return core.NewRunnableResult(fmt.Errorf("error creating GET request: %v", err))
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return core.NewRunnableResult(fmt.Errorf("error sending GET request: %v", err))
 	}
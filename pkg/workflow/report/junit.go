@@ -0,0 +1,156 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"nadleeh/pkg/workflow/core"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr,omitempty"`
+	Cases     []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+func junitTime(durationMs int64) string {
+	return fmt.Sprintf("%.3f", float64(durationMs)/1000)
+}
+
+// junit converts the report to JUnit XML, each job is a test suite and each step is a test case. The jobs of a
+// reusable workflow are test suites named like caller / job.
+func (r *Report) junit() ([]byte, error) {
+	suites := &junitTestSuites{
+		Name: r.Workflow,
+		Time: junitTime(r.DurationMs),
+	}
+	for _, job := range r.Tree.Children {
+		suites.addJob(r.Workflow, "", job)
+	}
+	for _, suite := range suites.Suites {
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+	}
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(xml.Header), data...), '\n'), nil
+}
+
+func (s *junitTestSuites) addJob(className string, prefix string, job *core.StatusSnapshot) {
+	name := prefix + job.Name
+	suite := &junitTestSuite{
+		Name: name,
+		Time: junitTime(job.DurationMs),
+	}
+	if job.StartTime != nil {
+		suite.Timestamp = job.StartTime.Format("2006-01-02T15:04:05")
+	}
+	s.Suites = append(s.Suites, suite)
+
+	stepFailed := false
+	for _, child := range job.Children {
+		if child.Type == "workflow" {
+			for _, nested := range child.Children {
+				s.addJob(className, name+" / ", nested)
+			}
+			continue
+		}
+		testCase := newTestCase(className+"."+name, child)
+		stepFailed = stepFailed || testCase.Failure != nil
+		suite.add(testCase)
+	}
+	// the job itself is a test case if it has no step or it failed without a failed step, the failures of a reusable
+	// workflow are reported by its jobs
+	failed := job.Conclusion == core.Fail || job.Conclusion == core.TimedOut || job.Conclusion == core.Cancelled
+	if !hasWorkflow(job) && (len(suite.Cases) == 0 || failed && !stepFailed) {
+		suite.add(newTestCase(className+"."+name, job))
+	}
+}
+
+func hasWorkflow(job *core.StatusSnapshot) bool {
+	for _, child := range job.Children {
+		if child.Type == "workflow" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *junitTestSuite) add(testCase *junitTestCase) {
+	s.Cases = append(s.Cases, testCase)
+	s.Tests++
+	if testCase.Failure != nil {
+		s.Failures++
+	}
+	if testCase.Skipped != nil {
+		s.Skipped++
+	}
+}
+
+func newTestCase(className string, snapshot *core.StatusSnapshot) *junitTestCase {
+	testCase := &junitTestCase{
+		Name:      snapshot.Name,
+		ClassName: className,
+		Time:      junitTime(snapshot.DurationMs),
+	}
+	errs := strings.Join(snapshot.Errors, "\n")
+	switch snapshot.Conclusion {
+	case core.Fail, core.TimedOut, core.Cancelled:
+		message := snapshot.Conclusion
+		if len(snapshot.Errors) > 0 {
+			message = snapshot.Errors[0]
+		}
+		testCase.Failure = &junitMessage{Message: message, Type: snapshot.Conclusion, Text: errs}
+	case core.Skipped:
+		testCase.Skipped = &junitMessage{Message: snapshot.SkipReason}
+	case core.NotStart:
+		testCase.Skipped = &junitMessage{Message: "not started"}
+	case core.Running:
+		testCase.Skipped = &junitMessage{Message: "still running"}
+	default:
+		if snapshot.Status != snapshot.Conclusion {
+			testCase.SystemOut = fmt.Sprintf("%s %s but continued on error:\n%s", snapshot.Type, snapshot.Status, errs)
+		} else if len(snapshot.RestoredFrom) > 0 {
+			testCase.SystemOut = fmt.Sprintf("%s passed in run %s", snapshot.Type, snapshot.RestoredFrom)
+		}
+	}
+	if len(snapshot.LogFile) > 0 {
+		if len(testCase.SystemOut) > 0 {
+			testCase.SystemOut += "\n"
+		}
+		testCase.SystemOut += "log: " + snapshot.LogFile
+	}
+	return testCase
+}
@@ -0,0 +1,115 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nadleeh/pkg/workflow/core"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FormatJson  = "json"
+	FormatJUnit = "junit"
+)
+
+// Spec is a report to write, it's parsed from format=path, e.g. junit=report.xml
+type Spec struct {
+	Format string
+	Path   string
+}
+
+// ParseSpec parses the report spec like json=report.json
+func ParseSpec(value string) (*Spec, error) {
+	format, path, found := strings.Cut(value, "=")
+	if !found || len(strings.TrimSpace(path)) == 0 {
+		return nil, fmt.Errorf("invalid report %s, it must be format=path", value)
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != FormatJson && format != FormatJUnit {
+		return nil, fmt.Errorf("unsupported report format %s, it must be %s or %s", format, FormatJson, FormatJUnit)
+	}
+	return &Spec{Format: format, Path: strings.TrimSpace(path)}, nil
+}
+
+// ParseSpecs parses the report specs, all the invalid specs are reported
+func ParseSpecs(values []string) ([]*Spec, error) {
+	var specs []*Spec
+	var errs []error
+	for _, value := range values {
+		spec, err := ParseSpec(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	return specs, errors.Join(errs...)
+}
+
+// Report is the result of a workflow run
+type Report struct {
+	Workflow   string               `json:"workflow"`
+	File       string               `json:"file"`
+	RunId      string               `json:"runId,omitempty"`
+	Status     string               `json:"status"`
+	StartTime  *time.Time           `json:"startTime,omitempty"`
+	EndTime    *time.Time           `json:"endTime,omitempty"`
+	DurationMs int64                `json:"durationMs"`
+	Tree       *core.StatusSnapshot `json:"tree"`
+}
+
+// NewReport creates the report of the workflow status
+func NewReport(file string, runId string, status *core.RunnableStatus) *Report {
+	tree := status.Snapshot()
+	return &Report{
+		Workflow:   tree.Name,
+		File:       file,
+		RunId:      runId,
+		Status:     tree.Conclusion,
+		StartTime:  tree.StartTime,
+		EndTime:    tree.EndTime,
+		DurationMs: tree.DurationMs,
+		Tree:       tree,
+	}
+}
+
+// Write writes the report in the format of each spec, all the failed reports are returned
+func (r *Report) Write(specs []*Spec) error {
+	var errs []error
+	for _, spec := range specs {
+		var data []byte
+		var err error
+		switch spec.Format {
+		case FormatJson:
+			data, err = json.MarshalIndent(r, "", "  ")
+		case FormatJUnit:
+			data, err = r.junit()
+		default:
+			err = fmt.Errorf("unsupported report format %s", spec.Format)
+		}
+		if err == nil {
+			err = writeFile(spec.Path, data)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to write %s report %s: %w", spec.Format, spec.Path, err))
+			continue
+		}
+		log.Infof("%s report is written to %s", spec.Format, spec.Path)
+	}
+	return errors.Join(errs...)
+}
+
+func writeFile(path string, data []byte) error {
+	if dir := filepath.Dir(path); len(dir) > 0 {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"nadleeh/pkg/workflow/core"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec("JUnit=out/report.xml")
+	if err != nil || spec.Format != FormatJUnit || spec.Path != "out/report.xml" {
+		t.Errorf("Unexpected spec %v %v", spec, err)
+	}
+	for _, value := range []string{"json", "json=", "xml=report.xml"} {
+		if _, err = ParseSpec(value); err == nil {
+			t.Errorf("Expected error for report %s", value)
+		}
+	}
+	if _, err = ParseSpecs([]string{"json=a.json", "yaml=a.yml", "junit"}); err == nil || strings.Count(err.Error(), "\n") != 1 {
+		t.Errorf("Expected all invalid reports to be reported, got %v", err)
+	}
+}
+
+// createStatus creates the status of a workflow with a failed job and a skipped job
+func createStatus() *core.RunnableStatus {
+	workflowStatus := core.NewRunnableStatus("backup", "workflow")
+	workflowStatus.Start()
+
+	dump := core.NewRunnableStatus("dump", "job")
+	workflowStatus.AddChild(dump)
+	dump.Start()
+	for name, err := range map[string]error{"pg_dump": nil, "upload": errors.New("exit status 3")} {
+		step := core.NewRunnableStatus(name, "step")
+		dump.AddChild(step)
+		step.Start()
+		if err != nil {
+			step.Finish(err)
+		} else {
+			step.Finish()
+		}
+	}
+	notify := core.NewRunnableStatus("notify", "step")
+	dump.AddChild(notify)
+	notify.SkippedWithReason("a previous step failed")
+	dump.Finish(errors.New("exit status 3"))
+
+	cleanup := core.NewRunnableStatus("cleanup", "job")
+	workflowStatus.AddChild(cleanup)
+	cleanup.SkippedWithReason("needed job dump didn't pass")
+
+	workflowStatus.Finish(errors.New("exit status 3"))
+	return workflowStatus
+}
+
+func TestReport_WriteJson(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "report.json")
+	if err := NewReport("backup.yml", "run-1", createStatus()).Write([]*Spec{{Format: FormatJson, Path: path}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the report file, got %v", err)
+	}
+	var report Report
+	if err = json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Invalid json report: %v", err)
+	}
+	if report.Workflow != "backup" || report.RunId != "run-1" || report.Status != core.Fail || report.StartTime == nil {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	cleanup := report.Tree.Children[1]
+	if cleanup.Status != core.Skipped || cleanup.SkipReason != "needed job dump didn't pass" {
+		t.Errorf("Expected the skip reason of job cleanup, got %+v", cleanup)
+	}
+}
+
+func TestReport_WriteJUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xml")
+	if err := NewReport("backup.yml", "", createStatus()).Write([]*Spec{{Format: FormatJUnit, Path: path}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the report file, got %v", err)
+	}
+	xml := string(data)
+	for _, expected := range []string{
+		`<testsuites name="backup" tests="4" failures="1" skipped="2"`,
+		`<testsuite name="dump" tests="3" failures="1" skipped="1"`,
+		`<failure message="exit status 3" type="Fail">exit status 3</failure>`,
+		`<skipped message="a previous step failed"></skipped>`,
+		`<testcase name="cleanup" classname="backup.cleanup"`,
+		`<skipped message="needed job dump didn&#39;t pass"></skipped>`,
+	} {
+		if !strings.Contains(xml, expected) {
+			t.Errorf("Expected %s in report:\n%s", expected, xml)
+		}
+	}
+}
+
+func TestReport_JUnitReusableWorkflow(t *testing.T) {
+	workflowStatus := core.NewRunnableStatus("caller", "workflow")
+	job := core.NewRunnableStatus("backup", "job")
+	workflowStatus.AddChild(job)
+	called := core.NewRunnableStatus("backup", "workflow")
+	job.AddChild(called)
+	nested := core.NewRunnableStatus("dump", "job")
+	called.AddChild(nested)
+	step := core.NewRunnableStatus("pg_dump", "step")
+	nested.AddChild(step)
+	step.Finish(errors.New("exit status 1"))
+	nested.Finish(errors.New("exit status 1"))
+	called.Finish(errors.New("exit status 1"))
+	job.Finish(errors.New("exit status 1"))
+	workflowStatus.Finish(errors.New("exit status 1"))
+
+	data, err := NewReport("caller.yml", "", workflowStatus).junit()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	xml := string(data)
+	if !strings.Contains(xml, `<testsuites name="caller" tests="1" failures="1"`) ||
+		!strings.Contains(xml, `<testsuite name="backup / dump" tests="1" failures="1"`) {
+		t.Errorf("Expected the failure reported by the nested job only:\n%s", xml)
+	}
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"nadleeh/pkg/workflow/core"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorGray   = "\033[90m"
+
+	// maxDetailLen truncates the error shown in the summary, the full errors are in the log and the reports
+	maxDetailLen = 80
+)
+
+var statusColors = map[string]string{
+	core.Pass:      colorGreen,
+	core.Fail:      colorRed,
+	core.TimedOut:  colorRed,
+	core.Cancelled: colorRed,
+	core.Skipped:   colorYellow,
+	core.NotStart:  colorGray,
+}
+
+// summaryRow is a job or a step of the summary table
+type summaryRow struct {
+	name     string
+	status   string
+	exitCode string
+	duration string
+	detail   string
+}
+
+// UseColor returns whether the summary written to f is colored, NO_COLOR disables the color
+func UseColor(f *os.File) bool {
+	return len(os.Getenv("NO_COLOR")) == 0 && term.IsTerminal(int(f.Fd()))
+}
+
+// WriteSummary writes a table of the result and time of each job and step
+func WriteSummary(w io.Writer, status *core.RunnableStatus, color bool) {
+	tree := status.Snapshot()
+	var rows []summaryRow
+	for _, job := range tree.Children {
+		rows = appendSummaryRows(rows, job, "")
+	}
+
+	header := summaryRow{name: "JOB / STEP", status: "STATUS", exitCode: "EXIT", duration: "DURATION", detail: "DETAIL"}
+	widths := [4]int{}
+	for _, row := range append([]summaryRow{header}, rows...) {
+		for i, cell := range []string{row.name, row.status, row.exitCode, row.duration} {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	writeRow := func(row summaryRow, statusColor string) {
+		status := fmt.Sprintf("%-*s", widths[1], row.status)
+		if color && len(statusColor) > 0 {
+			status = statusColor + status + colorReset
+		}
+		line := fmt.Sprintf("%-*s  %s  %*s  %*s  %s", widths[0], row.name, status, widths[2], row.exitCode,
+			widths[3], row.duration, row.detail)
+		_, _ = fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+
+	_, _ = fmt.Fprintf(w, "Workflow %s: %s in %s\n", tree.Name, tree.Conclusion, formatDuration(tree.DurationMs))
+	writeRow(header, "")
+	for _, row := range rows {
+		writeRow(row, statusColors[row.status])
+	}
+}
+
+// appendSummaryRows appends the job and its steps, the jobs of a reusable workflow are named like caller / job
+func appendSummaryRows(rows []summaryRow, job *core.StatusSnapshot, prefix string) []summaryRow {
+	name := prefix + job.Name
+	rows = append(rows, newSummaryRow(name, job))
+	for _, child := range job.Children {
+		if child.Type == "workflow" {
+			for _, nested := range child.Children {
+				rows = appendSummaryRows(rows, nested, name+" / ")
+			}
+			continue
+		}
+		rows = append(rows, newSummaryRow("  "+child.Name, child))
+	}
+	return rows
+}
+
+func newSummaryRow(name string, snapshot *core.StatusSnapshot) summaryRow {
+	row := summaryRow{name: name, status: snapshot.Status}
+	// a step which failed before its command ran has no exit code, 0 is never shown for a failed step
+	if snapshot.Type == "step" && snapshot.ExitCode != nil && (*snapshot.ExitCode != 0 || snapshot.Status == core.Pass) {
+		row.exitCode = fmt.Sprintf("%d", *snapshot.ExitCode)
+	}
+	if snapshot.StartTime != nil {
+		row.duration = formatDuration(snapshot.DurationMs)
+	}
+	switch {
+	case len(snapshot.RestoredFrom) > 0:
+		row.detail = "restored from run " + snapshot.RestoredFrom
+	case snapshot.Status == core.Skipped:
+		row.detail = snapshot.SkipReason
+	case snapshot.Status != snapshot.Conclusion:
+		row.detail = "continued on error"
+	case snapshot.Type == "step" && len(snapshot.Errors) > 0:
+		row.detail = snapshot.Errors[len(snapshot.Errors)-1]
+	}
+	row.detail = strings.ReplaceAll(row.detail, "\n", " ")
+	if len(row.detail) > maxDetailLen {
+		row.detail = row.detail[:maxDetailLen-3] + "..."
+	}
+	return row
+}
+
+func formatDuration(durationMs int64) string {
+	duration := time.Duration(durationMs) * time.Millisecond
+	if duration < time.Second {
+		return duration.String()
+	}
+	return duration.Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,86 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"nadleeh/pkg/workflow/core"
+	"strings"
+	"testing"
+)
+
+func TestWriteSummary(t *testing.T) {
+	status := createStatus()
+	status.GetChild("dump").GetChild("upload").SetExitCode(3)
+	status.GetChild("dump").GetChild("pg_dump").SetExitCode(0)
+
+	var buf bytes.Buffer
+	WriteSummary(&buf, status, false)
+	out := buf.String()
+	if strings.Contains(out, "\033[") {
+		t.Errorf("Expected no color, got %q", out)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("Expected the title, the header and 5 rows, got:\n%s", out)
+	}
+	if !strings.HasPrefix(lines[0], "Workflow backup: Fail in ") {
+		t.Errorf("Unexpected title %s", lines[0])
+	}
+	expected := map[string][]string{
+		"dump":      {"Fail"},
+		"  upload":  {"Fail", " 3 ", "exit status 3"},
+		"  notify":  {"Skipped", "a previous step failed"},
+		"cleanup":   {"Skipped", "needed job dump didn't pass"},
+		"  pg_dump": {"Pass", " 0 "},
+	}
+	for _, line := range lines[2:] {
+		name := strings.SplitN(line, "  ", 2)[0]
+		if strings.HasPrefix(line, "  ") {
+			name = "  " + strings.SplitN(line[2:], "  ", 2)[0]
+		}
+		fields, ok := expected[name]
+		if !ok {
+			t.Errorf("Unexpected row %q", line)
+			continue
+		}
+		for _, field := range fields {
+			if !strings.Contains(line, field) {
+				t.Errorf("Expected %q in row %q", field, line)
+			}
+		}
+	}
+
+	buf.Reset()
+	WriteSummary(&buf, status, true)
+	if !strings.Contains(buf.String(), colorRed+"Fail") || !strings.Contains(buf.String(), colorYellow+"Skipped") {
+		t.Errorf("Expected colored status, got %q", buf.String())
+	}
+}
+
+func TestWriteSummary_NoExitCode(t *testing.T) {
+	status := core.NewRunnableStatus("backup", "workflow")
+	status.Start()
+	job := core.NewRunnableStatus("dump", "job")
+	status.AddChild(job)
+	job.Start()
+	for _, name := range []string{"env", "plugin"} {
+		step := core.NewRunnableStatus(name, "step")
+		job.AddChild(step)
+		step.Start()
+		if name == "plugin" {
+			// a failed plugin returns 0 with its error
+			step.SetExitCode(0)
+		}
+		step.Finish(errors.New("failed to interpret env"))
+	}
+	job.Finish(errors.New("failed to interpret env"))
+	status.Finish(errors.New("failed to interpret env"))
+
+	var buf bytes.Buffer
+	WriteSummary(&buf, status, false)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "Fail") && strings.Contains(line, " 0 ") {
+			t.Errorf("Expected no exit code 0 of a failed step, got %q", line)
+		}
+	}
+}
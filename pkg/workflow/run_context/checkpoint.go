@@ -0,0 +1,131 @@
+package run_context
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StepCheckpoint is the result of a step and the job env after it
+type StepCheckpoint struct {
+	Name       string            `json:"name"`
+	Status     string            `json:"status"`
+	SkipReason string            `json:"skipReason,omitempty"`
+	Outputs    map[string]string `json:"outputs,omitempty"`
+	// Env is the job env after the step which differs from the workflow env
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// JobCheckpoint is the result of a job and its steps in the running order, the post steps are not included
+type JobCheckpoint struct {
+	Passed  bool              `json:"passed"`
+	Outputs map[string]string `json:"outputs,omitempty"`
+	Steps   []*StepCheckpoint `json:"steps,omitempty"`
+}
+
+// Checkpoint is the result of the jobs of a run, a failed run is resumed from it
+type Checkpoint struct {
+	RunId string                    `json:"runId"`
+	Jobs  map[string]*JobCheckpoint `json:"jobs"`
+}
+
+func NewCheckpoint(runId string) *Checkpoint {
+	return &Checkpoint{
+		RunId: runId,
+		Jobs:  make(map[string]*JobCheckpoint),
+	}
+}
+
+// Checkpointer records the checkpoint of the running workflow and restores the passed jobs and steps of the resumed
+// run. The jobs of a reusable workflow are not recorded, the job which calls it is. A nil checkpointer does nothing.
+type Checkpointer struct {
+	mu      sync.Mutex
+	current *Checkpoint
+	resumed *Checkpoint
+	save    func(checkpoint *Checkpoint) error
+}
+
+// NewCheckpointer creates the checkpointer of the run, resumed is nil if the run isn't resumed. The checkpoint is
+// saved by save after each change.
+func NewCheckpointer(runId string, resumed *Checkpoint, save func(checkpoint *Checkpoint) error) *Checkpointer {
+	return &Checkpointer{
+		current: NewCheckpoint(runId),
+		resumed: resumed,
+		save:    save,
+	}
+}
+
+// ResumedFrom returns the id of the resumed run, it's empty if the run isn't resumed
+func (c *Checkpointer) ResumedFrom() string {
+	if c == nil || c.resumed == nil {
+		return ""
+	}
+	return c.resumed.RunId
+}
+
+// PassedJob returns the checkpoint of the job if it passed in the resumed run
+func (c *Checkpointer) PassedJob(job string) *JobCheckpoint {
+	if c == nil || c.resumed == nil {
+		return nil
+	}
+	jobCheckpoint := c.resumed.Jobs[job]
+	if jobCheckpoint == nil || !jobCheckpoint.Passed {
+		return nil
+	}
+	return jobCheckpoint
+}
+
+// ResumedStep returns the checkpoint of the step at index of the job in the resumed run, it returns nil if the step
+// didn't run or the step at index has another name
+func (c *Checkpointer) ResumedStep(job string, index int, step string) *StepCheckpoint {
+	if c == nil || c.resumed == nil {
+		return nil
+	}
+	jobCheckpoint := c.resumed.Jobs[job]
+	if jobCheckpoint == nil || index >= len(jobCheckpoint.Steps) || jobCheckpoint.Steps[index].Name != step {
+		return nil
+	}
+	return jobCheckpoint.Steps[index]
+}
+
+// RecordStep records the result of the next step of the job
+func (c *Checkpointer) RecordStep(job string, step *StepCheckpoint) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	jobCheckpoint := c.current.Jobs[job]
+	if jobCheckpoint == nil {
+		jobCheckpoint = &JobCheckpoint{}
+		c.current.Jobs[job] = jobCheckpoint
+	}
+	jobCheckpoint.Steps = append(jobCheckpoint.Steps, step)
+	c.saveLocked()
+}
+
+// RecordJob records the result of the job
+func (c *Checkpointer) RecordJob(job string, passed bool, outputs map[string]string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	jobCheckpoint := c.current.Jobs[job]
+	if jobCheckpoint == nil {
+		jobCheckpoint = &JobCheckpoint{}
+		c.current.Jobs[job] = jobCheckpoint
+	}
+	jobCheckpoint.Passed = passed
+	jobCheckpoint.Outputs = outputs
+	c.saveLocked()
+}
+
+func (c *Checkpointer) saveLocked() {
+	if c.save == nil {
+		return
+	}
+	if err := c.save(c.current); err != nil {
+		log.Warnf("failed to save checkpoint of run %s: %v", c.current.RunId, err)
+	}
+}
@@ -0,0 +1,38 @@
+package run_context
+
+import "testing"
+
+func TestCheckpointer(t *testing.T) {
+	var nilCheckpointer *Checkpointer
+	nilCheckpointer.RecordJob("dump", true, nil)
+	if nilCheckpointer.ResumedFrom() != "" || nilCheckpointer.PassedJob("dump") != nil {
+		t.Error("Expected a nil checkpointer to do nothing")
+	}
+
+	resumed := NewCheckpoint("run-1")
+	resumed.Jobs["prepare"] = &JobCheckpoint{Passed: true, Outputs: map[string]string{"dir": "/tmp"}}
+	resumed.Jobs["dump"] = &JobCheckpoint{Steps: []*StepCheckpoint{{Name: "dump", Status: "Pass"}, {Name: "upload", Status: "Fail"}}}
+	saved := 0
+	checkpointer := NewCheckpointer("run-2", resumed, func(checkpoint *Checkpoint) error {
+		saved++
+		return nil
+	})
+	if checkpointer.ResumedFrom() != "run-1" {
+		t.Errorf("Expected resumed from run-1, got %s", checkpointer.ResumedFrom())
+	}
+	if checkpointer.PassedJob("prepare") == nil || checkpointer.PassedJob("dump") != nil {
+		t.Error("Expected only the passed job to be returned")
+	}
+	if checkpointer.ResumedStep("dump", 0, "dump") == nil {
+		t.Error("Expected the step at index 0")
+	}
+	if checkpointer.ResumedStep("dump", 0, "renamed") != nil || checkpointer.ResumedStep("dump", 2, "notify") != nil {
+		t.Error("Expected no step for another name or a step which didn't run")
+	}
+
+	checkpointer.RecordStep("dump", &StepCheckpoint{Name: "dump", Status: "Pass"})
+	checkpointer.RecordJob("dump", true, map[string]string{"file": "dump.sql"})
+	if saved != 2 || !checkpointer.current.Jobs["dump"].Passed || len(checkpointer.current.Jobs["dump"].Steps) != 1 {
+		t.Errorf("Expected the checkpoint to be saved after each record, got %d saves", saved)
+	}
+}
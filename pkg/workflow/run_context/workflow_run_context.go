@@ -10,6 +10,10 @@ type WorkflowRunContext struct {
 	JSCtx     script.JSContext
 	ShellCtx  shell.ShellContext
 	SecureCtx encrypt.SecureContext
+	// StepLogDir is the directory the step logs are written to, the step logs are disabled if it's empty
+	StepLogDir string
+	// Checkpointer records the checkpoint of the run and restores the resumed run, it's nil if there is no checkpoint
+	Checkpointer *Checkpointer
 }
 
 func NewWorkflowRunContext(pPriFile *string) *WorkflowRunContext {
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"fmt"
+	"nadleeh/pkg/script"
+	workflow "nadleeh/pkg/workflow/model"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// maxPromptAttempts is how many times an invalid value of a prompted arg is read again
+const maxPromptAttempts = 3
+
+// terminalArgPrompter returns the prompter which reads the missing required args from the terminal, it's nil if stdin
+// isn't a terminal, e.g. the workflow is run by the scheduler
+func terminalArgPrompter() workflow.ArgPrompter {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	core := &script.NJSCore{}
+	return promptArg(core.ReadLine, core.ReadPassword)
+}
+
+// promptArg reads the arg by readLine, a secret arg is read by readPassword without echo. An invalid value is read
+// again.
+func promptArg(readLine, readPassword func(prompt string) (string, error)) workflow.ArgPrompter {
+	return func(arg workflow.WorkflowArg) (string, error) {
+		read := readLine
+		if arg.ArgType() == workflow.ArgSecret {
+			read = readPassword
+		}
+		var err error
+		for range maxPromptAttempts {
+			var value string
+			if value, err = read(argPrompt(arg)); err != nil {
+				return "", err
+			}
+			if err = arg.Validate(value); err == nil {
+				return value, nil
+			}
+			fmt.Printf("invalid arg %s, it %v\n", arg.Name, err)
+		}
+		return "", fmt.Errorf("it %w", err)
+	}
+}
+
+// argPrompt returns the prompt of the arg like "name (description) [a/b]: "
+func argPrompt(arg workflow.WorkflowArg) string {
+	prompt := arg.Name
+	if len(arg.Description) > 0 {
+		prompt += fmt.Sprintf(" (%s)", arg.Description)
+	}
+	if len(arg.Choices) > 0 {
+		prompt += fmt.Sprintf(" [%s]", strings.Join(arg.Choices, "/"))
+	}
+	return prompt + ": "
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	workflow "nadleeh/pkg/workflow/model"
+	"testing"
+)
+
+func TestPromptArg(t *testing.T) {
+	var prompts []string
+	inputs := []string{"many", "5"}
+	readLine := func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		input := inputs[0]
+		inputs = inputs[1:]
+		return input, nil
+	}
+	readPassword := func(prompt string) (string, error) {
+		prompts = append(prompts, "hidden "+prompt)
+		return "s3cret", nil
+	}
+	prompt := promptArg(readLine, readPassword)
+
+	value, err := prompt(workflow.WorkflowArg{Name: "count", Type: workflow.ArgInt, Description: "backups to keep"})
+	if err != nil || value != "5" {
+		t.Errorf("expected the valid value read again, got %s, %v", value, err)
+	}
+	value, err = prompt(workflow.WorkflowArg{Name: "password", Type: workflow.ArgSecret})
+	if err != nil || value != "s3cret" {
+		t.Errorf("expected the secret read without echo, got %s, %v", value, err)
+	}
+	expected := []string{"count (backups to keep): ", "count (backups to keep): ", "hidden password: "}
+	if len(prompts) != len(expected) {
+		t.Fatalf("expected prompts %v, got %v", expected, prompts)
+	}
+	for i := range expected {
+		if prompts[i] != expected[i] {
+			t.Errorf("expected prompt %q, got %q", expected[i], prompts[i])
+		}
+	}
+
+	inputs = []string{"a", "b", "c"}
+	_, err = prompt(workflow.WorkflowArg{Name: "mode", Type: workflow.ArgEnum, Choices: []string{"full", "diff"}})
+	if err == nil || err.Error() != "it is not one of full, diff" {
+		t.Errorf("expected the error after %d attempts, got %v", maxPromptAttempts, err)
+	}
+}
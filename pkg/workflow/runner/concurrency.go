@@ -0,0 +1,193 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nadleeh/pkg/file"
+	workflow "nadleeh/pkg/workflow/model"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// concurrencyLock is the lock of the concurrency group, it's held by the run until the workflow finished
+type concurrencyLock struct {
+	group string
+	lock  *file.FileLock
+}
+
+// locksDir returns ~/.nadleeh/locks
+func locksDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home dir: %w", err)
+	}
+	return filepath.Join(home, ".nadleeh", "locks"), nil
+}
+
+// lockOwner is the run which holds the lock of a concurrency group, the start time of the process tells the run from
+// a process which reused its pid
+type lockOwner struct {
+	pid       int
+	startTime string
+	group     string
+}
+
+// newLockOwner returns the owner of the current process for the group
+func newLockOwner(group string) (*lockOwner, error) {
+	startTime, err := processStartTime(os.Getpid())
+	if err != nil {
+		return nil, err
+	}
+	return &lockOwner{pid: os.Getpid(), startTime: startTime, group: group}, nil
+}
+
+// parseLockOwner parses the owner written to the lock file as "pid start-time group"
+func parseLockOwner(owner string) (*lockOwner, error) {
+	fields := strings.SplitN(owner, " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid owner %q", owner)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || pid <= 0 {
+		return nil, fmt.Errorf("invalid pid of owner %q", owner)
+	}
+	return &lockOwner{pid: pid, startTime: fields[1], group: fields[2]}, nil
+}
+
+func (o *lockOwner) String() string {
+	return fmt.Sprintf("%d %s %s", o.pid, o.startTime, o.group)
+}
+
+// verify checks the process of the pid is still the nadleeh run of the group which wrote the owner
+func (o *lockOwner) verify(group string) error {
+	if o.group != group {
+		return fmt.Errorf("it's the owner of concurrency group %s", o.group)
+	}
+	startTime, err := processStartTime(o.pid)
+	if err != nil {
+		return err
+	} else if startTime != o.startTime {
+		return fmt.Errorf("pid %d was reused by another process", o.pid)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", o.pid))
+	if err != nil {
+		return err
+	}
+	name, _, _ := strings.Cut(string(cmdline), "\x00")
+	if filepath.Base(name) != filepath.Base(exe) {
+		return fmt.Errorf("pid %d is %s, not a nadleeh run", o.pid, name)
+	}
+	return nil
+}
+
+// processStartTime returns the start time of the process in clock ticks after boot, it's the 22nd field of
+// /proc/<pid>/stat
+func processStartTime(pid int) (string, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the stat of pid %d: %w", pid, err)
+	}
+	// the command name in parentheses may have spaces, the fields after it start at the 3rd field
+	end := strings.LastIndexByte(string(stat), ')')
+	fields := strings.Fields(string(stat[end+1:]))
+	if end < 0 || len(fields) < 20 {
+		return "", fmt.Errorf("invalid stat of pid %d", pid)
+	}
+	return fields[19], nil
+}
+
+// acquireConcurrency acquires the lock of the concurrency group under dir before the workflow runs, the lock file
+// has the pid, the process start time and the group of the run which holds it. It returns nil if the workflow has no concurrency, skipped is true if the
+// run must not run since a run of the group is in progress.
+func acquireConcurrency(ctx context.Context, concurrency *workflow.WorkflowConcurrency, group string,
+	dir string) (lock *concurrencyLock, skipped bool, err error) {
+	if concurrency == nil {
+		return nil, false, nil
+	}
+	fileLock := file.NewFileLock(filepath.Join(dir, url.PathEscape(group)+".lock"))
+	locked, err := fileLock.TryLock()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to lock concurrency group %s: %w", group, err)
+	}
+	if !locked {
+		owner, _ := fileLock.ReadOwner()
+		if inProgress, err := parseLockOwner(owner); err == nil {
+			owner = strconv.Itoa(inProgress.pid)
+		}
+		switch concurrency.GetMode() {
+		case workflow.ConcurrencySkip:
+			log.Warnf("a run of concurrency group %s is in progress (pid %s), skip this run", group, owner)
+			return nil, true, nil
+		case workflow.ConcurrencyCancelInProgress:
+			cancelInProgress(group, fileLock)
+		default:
+			log.Infof("a run of concurrency group %s is in progress (pid %s), wait for it", group, owner)
+		}
+		waitCtx := ctx
+		if concurrency.TimeoutMinutes > 0 {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeoutCause(ctx, time.Duration(concurrency.TimeoutMinutes*float64(time.Minute)),
+				fmt.Errorf("timed out after %v minutes", concurrency.TimeoutMinutes))
+			defer cancel()
+		}
+		if err = fileLock.LockContext(waitCtx); err != nil {
+			return nil, false, fmt.Errorf("failed to wait for the run of concurrency group %s: %w", group, err)
+		}
+	}
+	if owner, err := newLockOwner(group); err != nil {
+		log.Warnf("failed to get the owner of concurrency group %s: %v", group, err)
+	} else if err = fileLock.WriteOwner(owner.String()); err != nil {
+		log.Warnf("failed to write the owner to the lock of concurrency group %s: %v", group, err)
+	}
+	log.Infof("acquired concurrency group %s", group)
+	return &concurrencyLock{group: group, lock: fileLock}, false, nil
+}
+
+// cancelInProgress sends SIGTERM to the run in progress, the run cancels its running step and runs the post steps
+// before it releases the lock. The pid of the lock owner is only signalled if it's still the nadleeh run of the group,
+// e.g. the owner may be stale if it's read before the run holding the lock wrote it.
+func cancelInProgress(group string, fileLock *file.FileLock) {
+	content, err := fileLock.ReadOwner()
+	if err != nil {
+		log.Warnf("a run of concurrency group %s is in progress but its owner is unknown, wait for it: %v", group, err)
+		return
+	}
+	owner, err := parseLockOwner(content)
+	if err == nil && owner.pid == os.Getpid() {
+		err = errors.New("it's the current run")
+	} else if err == nil {
+		err = owner.verify(group)
+	}
+	if err != nil {
+		log.Warnf("a run of concurrency group %s is in progress but its owner %q can't be cancelled, wait for it: %v",
+			group, content, err)
+		return
+	}
+	pid := owner.pid
+	log.Warnf("cancel the run of concurrency group %s in progress (pid %d)", group, pid)
+	if err = syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		log.Warnf("failed to cancel the run of concurrency group %s in progress: %v", group, err)
+	}
+}
+
+// release releases the lock of the concurrency group
+func (l *concurrencyLock) release() {
+	if l == nil {
+		return
+	}
+	if err := l.lock.Unlock(); err != nil {
+		log.Warnf("failed to release concurrency group %s: %v", l.group, err)
+	}
+}
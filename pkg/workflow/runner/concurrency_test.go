@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"nadleeh/pkg/file"
+	workflow "nadleeh/pkg/workflow/model"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestConcurrencyRunInProgress is the run in progress started by the tests, it's the test binary as nadleeh runs are
+// the nadleeh binary
+func TestConcurrencyRunInProgress(t *testing.T) {
+	if os.Getenv("NADLEEH_TEST_RUN_IN_PROGRESS") != "1" {
+		t.Skip("only run as the run in progress")
+	}
+	time.Sleep(30 * time.Second)
+}
+
+// holdLockOwner holds the lock of the group with the owner
+func holdLockOwner(t *testing.T, dir string, group string, owner string) *file.FileLock {
+	t.Helper()
+	lock := file.NewFileLock(filepath.Join(dir, url.PathEscape(group)+".lock"))
+	if err := lock.Lock(); err != nil {
+		t.Fatalf("Failed to hold lock: %v", err)
+	}
+	if err := lock.WriteOwner(owner); err != nil {
+		t.Fatalf("Failed to write owner: %v", err)
+	}
+	return lock
+}
+
+func TestAcquireConcurrency(t *testing.T) {
+	// holdLock holds the lock of the group as the run of pid does
+	holdLock := func(t *testing.T, dir string, group string, pid int) *file.FileLock {
+		t.Helper()
+		startTime, err := processStartTime(pid)
+		if err != nil {
+			t.Fatalf("Failed to get the start time: %v", err)
+		}
+		return holdLockOwner(t, dir, group, fmt.Sprintf("%d %s %s", pid, startTime, group))
+	}
+
+	t.Run("NoConcurrency", func(t *testing.T) {
+		lock, skipped, err := acquireConcurrency(context.Background(), nil, "", t.TempDir())
+		if lock != nil || skipped || err != nil {
+			t.Errorf("Expected nothing to lock, got %v, %v, %v", lock, skipped, err)
+		}
+		lock.release()
+	})
+
+	t.Run("Free", func(t *testing.T) {
+		dir := t.TempDir()
+		lock, skipped, err := acquireConcurrency(context.Background(), &workflow.WorkflowConcurrency{Group: "backup/db"},
+			"backup/db", dir)
+		if err != nil || skipped || lock == nil {
+			t.Fatalf("Expected the lock to be acquired, got %v, %v", skipped, err)
+		}
+		owner, _ := file.NewFileLock(filepath.Join(dir, "backup%2Fdb.lock")).ReadOwner()
+		if owner == "" {
+			t.Error("Expected the owner in the lock file")
+		}
+		lock.release()
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		dir := t.TempDir()
+		held := holdLock(t, dir, "backup", 1)
+		defer held.Unlock()
+		concurrency := &workflow.WorkflowConcurrency{Group: "backup", Mode: workflow.ConcurrencySkip}
+		lock, skipped, err := acquireConcurrency(context.Background(), concurrency, "backup", dir)
+		if lock != nil || !skipped || err != nil {
+			t.Errorf("Expected the run to be skipped, got %v, %v, %v", lock, skipped, err)
+		}
+	})
+
+	t.Run("QueueTimeout", func(t *testing.T) {
+		dir := t.TempDir()
+		held := holdLock(t, dir, "backup", 1)
+		defer held.Unlock()
+		concurrency := &workflow.WorkflowConcurrency{Group: "backup", TimeoutMinutes: 0.005}
+		_, _, err := acquireConcurrency(context.Background(), concurrency, "backup", dir)
+		if err == nil || !strings.Contains(err.Error(), "timed out after 0.005 minutes") {
+			t.Errorf("Expected the wait to time out, got %v", err)
+		}
+	})
+
+	t.Run("QueueWaits", func(t *testing.T) {
+		dir := t.TempDir()
+		held := holdLock(t, dir, "backup", 1)
+		time.AfterFunc(300*time.Millisecond, func() { held.Unlock() })
+		lock, _, err := acquireConcurrency(context.Background(), &workflow.WorkflowConcurrency{Group: "backup"}, "backup", dir)
+		if err != nil || lock == nil {
+			t.Fatalf("Expected the lock to be acquired once released, got %v", err)
+		}
+		lock.release()
+	})
+
+	// startInProgress starts the process of the run in progress
+	startInProgress := func(t *testing.T, name string, args ...string) *exec.Cmd {
+		t.Helper()
+		inProgress := exec.Command(name, args...)
+		inProgress.Env = append(os.Environ(), "NADLEEH_TEST_RUN_IN_PROGRESS=1")
+		if err := inProgress.Start(); err != nil {
+			t.Fatalf("Failed to start the run in progress: %v", err)
+		}
+		t.Cleanup(func() { _ = inProgress.Process.Kill() })
+		return inProgress
+	}
+
+	t.Run("CancelInProgress", func(t *testing.T) {
+		dir := t.TempDir()
+		inProgress := startInProgress(t, os.Args[0], "-test.run=^TestConcurrencyRunInProgress$")
+		held := holdLock(t, dir, "backup", inProgress.Process.Pid)
+		go func() {
+			_ = inProgress.Wait()
+			held.Unlock()
+		}()
+		concurrency := &workflow.WorkflowConcurrency{Group: "backup", Mode: workflow.ConcurrencyCancelInProgress,
+			TimeoutMinutes: 0.5}
+		start := time.Now()
+		lock, _, err := acquireConcurrency(context.Background(), concurrency, "backup", dir)
+		if err != nil || lock == nil {
+			t.Fatalf("Expected the lock to be acquired once the run in progress is cancelled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("Expected the run in progress to be cancelled, waited %v", elapsed)
+		}
+		lock.release()
+	})
+
+	t.Run("CancelVerifiesOwner", func(t *testing.T) {
+		inProgress := startInProgress(t, os.Args[0], "-test.run=^TestConcurrencyRunInProgress$")
+		other := startInProgress(t, "sleep", "30")
+		pid := inProgress.Process.Pid
+		startTime, err := processStartTime(pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherStartTime, err := processStartTime(other.Process.Pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		concurrency := &workflow.WorkflowConcurrency{Group: "backup", Mode: workflow.ConcurrencyCancelInProgress,
+			TimeoutMinutes: 0.005}
+		for name, owner := range map[string]string{
+			"ReusedPid":  fmt.Sprintf("%d 1 backup", pid),
+			"OtherGroup": fmt.Sprintf("%d %s restore", pid, startTime),
+			"NotNadleeh": fmt.Sprintf("%d %s backup", other.Process.Pid, otherStartTime),
+			"PidOnly":    strconv.Itoa(pid),
+		} {
+			dir := t.TempDir()
+			held := holdLockOwner(t, dir, "backup", owner)
+			_, _, err := acquireConcurrency(context.Background(), concurrency, "backup", dir)
+			held.Unlock()
+			if err == nil || !strings.Contains(err.Error(), "timed out") {
+				t.Errorf("Expected %s to wait for the run in progress, got %v", name, err)
+			}
+		}
+		for _, process := range []*exec.Cmd{inProgress, other} {
+			if err := process.Process.Signal(syscall.Signal(0)); err != nil {
+				t.Errorf("Expected the process %d not to be cancelled, got %v", process.Process.Pid, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"nadleeh/pkg/common"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/history"
+	"nadleeh/pkg/workflow/report"
+	"nadleeh/pkg/workflow/run_context"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhaojunlucky/golib/pkg/env"
+)
+
+// runHistory saves the record of the running workflow, a failure to save the record doesn't fail the workflow
+type runHistory struct {
+	store  *history.Store
+	record *history.RunRecord
+}
+
+// resumedRun is the failed run which is resumed
+type resumedRun struct {
+	record     *history.RunRecord
+	checkpoint *run_context.Checkpoint
+}
+
+// loadResumedRun loads the failed run and its checkpoint, the workflow file and provider of the run are used unless
+// they're given. The args are the args of the run overridden by the given args, the secret args are masked in the
+// record so they must be given again.
+func loadResumedRun(wa *core.WorkflowArgs, argEnv env.Env) (*resumedRun, env.Env, error) {
+	store, err := history.NewDefaultStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	record, err := store.Load(*wa.Resume)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record.EndTime == nil {
+		return nil, nil, fmt.Errorf("run %s is still running or was killed, it has no result to resume", record.Id)
+	}
+	if record.Status == core.Pass {
+		return nil, nil, fmt.Errorf("run %s passed, there is nothing to resume", record.Id)
+	}
+	checkpoint, err := store.LoadCheckpoint(record.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if wa.File == nil || len(*wa.File) == 0 {
+		wa.File = &record.Workflow
+	}
+	if (wa.Provider == nil || len(*wa.Provider) == 0) && len(record.Provider) > 0 {
+		wa.Provider = &record.Provider
+	}
+	args := make(map[string]string, len(record.Args))
+	var missing []string
+	for key, value := range record.Args {
+		if value == common.MaskedValue {
+			if !argEnv.Contains(key) {
+				missing = append(missing, key)
+			}
+			continue
+		}
+		args[key] = value
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return nil, nil, fmt.Errorf("the secret args %s of run %s are masked, pass them again with --arg",
+			strings.Join(missing, ", "), record.Id)
+	}
+	maps.Copy(args, argEnv.GetAll())
+	log.Infof("resume run %s of workflow %s", record.Id, record.Workflow)
+	return &resumedRun{record: record, checkpoint: checkpoint}, env.NewReadEnv(env.NewEmptyRWEnv(), args), nil
+}
+
+// checkWorkflow warns if the workflow changed since the resumed run, the steps are restored by their position and name
+func (resumed *resumedRun) checkWorkflow(content []byte) {
+	if resumed != nil && resumed.record.Sha != fmt.Sprintf("%x", sha256.Sum256(content)) {
+		log.Warnf("workflow %s changed since run %s, the passed steps are matched by their position and name",
+			resumed.record.Workflow, resumed.record.Id)
+	}
+}
+
+// startRunHistory saves the record of the workflow run under ~/.nadleeh/runs, it returns nil if the record can't be
+// saved
+func startRunHistory(yml string, content []byte, args map[string]string, provider *string,
+	resumed *resumedRun) *runHistory {
+	store, err := history.NewDefaultStore()
+	if err != nil {
+		log.Warnf("run history is disabled: %v", err)
+		return nil
+	}
+	run := &runHistory{
+		store:  store,
+		record: history.NewRunRecord(yml, content, args),
+	}
+	if provider != nil {
+		run.record.Provider = *provider
+	}
+	if resumed != nil {
+		run.record.ResumedFrom = resumed.record.Id
+	}
+	if err = store.Save(run.record); err != nil {
+		log.Warnf("run history is disabled: %v", err)
+		return nil
+	}
+	log.Infof("run id: %s", run.record.Id)
+	return run
+}
+
+// id returns the run id, it's empty if the run history is disabled
+func (run *runHistory) id() string {
+	if run == nil {
+		return ""
+	}
+	return run.record.Id
+}
+
+// newCheckpointer creates the checkpointer which saves the checkpoint of the run and restores the resumed run, the
+// checkpoint isn't saved if the run history is disabled
+func (run *runHistory) newCheckpointer(resumed *resumedRun) *run_context.Checkpointer {
+	var resumedCheckpoint *run_context.Checkpoint
+	if resumed != nil {
+		resumedCheckpoint = resumed.checkpoint
+	}
+	if run == nil {
+		if resumedCheckpoint == nil {
+			return nil
+		}
+		return run_context.NewCheckpointer("", resumedCheckpoint, nil)
+	}
+	return run_context.NewCheckpointer(run.record.Id, resumedCheckpoint, run.store.SaveCheckpoint)
+}
+
+// finish saves the status tree of the finished workflow
+func (run *runHistory) finish(status *core.RunnableStatus) {
+	if run == nil || status == nil {
+		return
+	}
+	run.record.Finish(status)
+	if err := run.store.Save(run.record); err != nil {
+		log.Warnf("failed to save run %s: %v", run.record.Id, err)
+	}
+}
+
+// writeReports writes the reports of the workflow status
+func writeReports(specs []*report.Spec, yml string, run *runHistory, status *core.RunnableStatus) error {
+	if len(specs) == 0 || status == nil {
+		return nil
+	}
+	if err := report.NewReport(yml, run.id(), status).Write(specs); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package runner
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"nadleeh/internal/argument"
 	"nadleeh/pkg/common"
 	"nadleeh/pkg/file"
 	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/history"
 	workflow "nadleeh/pkg/workflow/model"
+	"nadleeh/pkg/workflow/report"
 	"nadleeh/pkg/workflow/run_context"
 	"os"
 	"os/user"
@@ -41,10 +46,23 @@ func detectSudoUser() (home string, username string, detected bool) {
 	return "", "", false
 }
 
-func RunWorkflow(wa *core.WorkflowArgs, argEnv env.Env) {
+// RunWorkflow runs the workflow, the running step is cancelled once ctx is cancelled, then the post steps and the
+// finally job run and the run is marked as Cancelled
+func RunWorkflow(ctx context.Context, wa *core.WorkflowArgs, argEnv env.Env) {
+	var resumed *resumedRun
+	if wa.Resume != nil && len(*wa.Resume) > 0 {
+		var err error
+		if resumed, argEnv, err = loadResumedRun(wa, argEnv); err != nil {
+			log.Fatalf("failed to resume run %s: %v", *wa.Resume, err)
+		}
+	}
 	if wa.File == nil || len(*wa.File) == 0 {
 		log.Fatalf("invalid workflow file")
 	}
+	reportSpecs, err := report.ParseSpecs(wa.Reports)
+	if err != nil {
+		log.Fatal(err)
+	}
 	yml := *wa.File
 	val, err := file.FileExists(yml)
 	if err != nil {
@@ -67,6 +85,11 @@ func RunWorkflow(wa *core.WorkflowArgs, argEnv env.Env) {
 		"WORKFLOW_BUILD_DATE": common.BuildDate,
 	}
 
+	// reusable workflows of the workflow are loaded with the same provider
+	if wa.Provider != nil && len(*wa.Provider) > 0 {
+		requiredEnvs["WORKFLOW_PROVIDER"] = *wa.Provider
+	}
+
 	// Detect sudo user and override HOME/USER if needed
 	if home, username, detected := detectSudoUser(); detected {
 		requiredEnvs["HOME"] = home
@@ -79,9 +102,13 @@ func RunWorkflow(wa *core.WorkflowArgs, argEnv env.Env) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	content, err := io.ReadAll(ymlFile)
+	if err != nil {
+		log.Fatalf("failed to read workflow file %s: %v", yml, err)
+	}
 
 	log.Debugf("parse workflow file %s", yml)
-	wf, err := workflow.ParseWorkflow(ymlFile)
+	wf, err := workflow.ParseWorkflowFile(yml, bytes.NewReader(content))
 	if err != nil {
 		log.Fatalf("failed to parse workflow %v", err)
 	}
@@ -100,30 +127,87 @@ func RunWorkflow(wa *core.WorkflowArgs, argEnv env.Env) {
 
 	runCtx := run_context.NewWorkflowRunContext(wa.PrivateFile)
 
+	// the defaults are applied and the missing required args are prompted before they're checked
+	if argEnv, err = wf.Checks.ResolveArgs(argEnv, terminalArgPrompter()); err != nil {
+		log.Fatal(err)
+	}
+
 	log.Debugf("preflight workflow")
 	if err = wf.PreflightCheck(env.NewOSEnv(), argEnv, runCtx); err != nil {
 		log.Fatalf("failed to PreflightCheck workflow: %v", err)
 	}
 
+	log.Debugf("compile workflow")
+	if err = wf.Compile(*runCtx); err != nil {
+		log.Fatalf("failed to compile workflow: %v", err)
+	}
+
 	if wa.Check != nil && *wa.Check {
 		log.Infof("workflow check completed")
 		return
 	}
 
+	if wa.Plan != nil && *wa.Plan {
+		log.Infof("workflow plan, no step is run")
+		wf.Plan(os.Stdout, env.NewOSEnv(), runCtx, &core.RunnableContext{Args: argEnv, Trigger: history.TriggerFromEnv()})
+		return
+	}
+
+	group, err := wf.ConcurrencyGroup(env.NewOSEnv(), argEnv, runCtx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lockDir, err := locksDir()
+	if err != nil && wf.Concurrency != nil {
+		log.Fatalf("failed to get the concurrency locks dir: %v", err)
+	}
+	lock, skipped, err := acquireConcurrency(ctx, wf.Concurrency, group, lockDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if skipped {
+		log.Warnf("run workflow skipped, a run of concurrency group %s is in progress", group)
+		return
+	}
+	defer lock.release()
+
+	resumed.checkWorkflow(content)
+	run := startRunHistory(yml, content, wf.Checks.MaskSecretArgs(argEnv.GetAll()), wa.Provider, resumed)
+	if run != nil {
+		runCtx.StepLogDir = run.store.LogDir(run.record.Id)
+	}
+	runCtx.Checkpointer = run.newCheckpointer(resumed)
+
 	log.Debugf("run workflow file: %s", yml)
-	result := wf.Do(env.NewOSEnv(), runCtx, &core.RunnableContext{
-		NeedOutput: false,
-		Args:       argEnv,
-	})
-	if result.ReturnCode != 0 {
+	runnableCtx := &core.RunnableContext{
+		NeedOutput:   false,
+		Args:         argEnv,
+		Context:      ctx,
+		Cancellation: ctx,
+		Trigger:      history.TriggerFromEnv(),
+	}
+	result := wf.Do(env.NewOSEnv(), runCtx, runnableCtx)
+	run.finish(runnableCtx.WorkflowStatus)
+	if runnableCtx.WorkflowStatus != nil {
+		report.WriteSummary(os.Stdout, runnableCtx.WorkflowStatus, report.UseColor(os.Stdout))
+	}
+	reportErr := writeReports(reportSpecs, yml, run, runnableCtx.WorkflowStatus)
+	if result.ReturnCode != 0 && runnableCtx.Cancelled() {
+		log.Fatalf("run workflow cancelled: %v", context.Cause(ctx))
+	} else if result.ReturnCode != 0 {
 		log.Fatalf("run workflow failed, code %d, err %v", result.ReturnCode, result.Err)
+	} else if reportErr != nil {
+		log.Fatalf("run workflow passed, but %v", reportErr)
 	} else {
 		log.Info("run workflow passed")
 	}
 }
 
-func RunWorkflowConfig(wfArgs *argument.WorkflowArgs) {
-	argEnv := argument.CreateArgsEnv(wfArgs.Args)
+func RunWorkflowConfig(ctx context.Context, wfArgs *argument.WorkflowArgs) {
+	argEnv, err := argument.CreateArgsEnv(wfArgs.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
 	allArgs := argEnv.GetAll()
 
 	log.Infof("Loading workflow config file %s", wfArgs.ConfigFile)
@@ -141,7 +225,8 @@ func RunWorkflowConfig(wfArgs *argument.WorkflowArgs) {
 	}
 
 	wa := &core.WorkflowArgs{
-		File: &workflowCfg.Workflow,
+		File:    &workflowCfg.Workflow,
+		Reports: wfArgs.Reports,
 	}
 	if len(workflowCfg.Provider) > 0 {
 		wa.Provider = &workflowCfg.Provider
@@ -154,5 +239,5 @@ func RunWorkflowConfig(wfArgs *argument.WorkflowArgs) {
 		allArgs[k] = v
 	}
 
-	RunWorkflow(wa, env.NewReadEnv(env.NewEmptyRWEnv(), allArgs))
+	RunWorkflow(ctx, wa, env.NewReadEnv(env.NewEmptyRWEnv(), allArgs))
 }
@@ -1,12 +1,15 @@
 package runner
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"nadleeh/internal/argument"
 	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/history"
+	"nadleeh/pkg/workflow/run_context"
 
 	"github.com/zhaojunlucky/golib/pkg/env"
 )
@@ -234,7 +237,7 @@ jobs:
 		argEnv := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{})
 
 		// This should not panic since we're only checking
-		RunWorkflow(wa, argEnv)
+		RunWorkflow(context.Background(), wa, argEnv)
 	})
 }
 
@@ -261,9 +264,18 @@ jobs:
 			File: &workflowFile,
 		}
 		argEnv := env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{})
+		t.Setenv("HOME", tmpDir)
 
 		// This should execute successfully
-		RunWorkflow(wa, argEnv)
+		RunWorkflow(context.Background(), wa, argEnv)
+
+		records, err := history.NewStore(filepath.Join(tmpDir, ".nadleeh", "runs")).List()
+		if err != nil || len(records) != 1 {
+			t.Fatalf("Expected a run record, got %v %v", records, err)
+		}
+		if records[0].Status != core.Pass || len(records[0].StepLogs()) != 1 {
+			t.Errorf("Expected a passed run with the step log, got %s", records[0].Status)
+		}
 	})
 }
 
@@ -283,3 +295,40 @@ func BenchmarkRunWorkflow_ArgumentParsing(b *testing.B) {
 		_ = core.NewWorkflowArgsFromRunArgs(runArgs)
 	}
 }
+
+func TestLoadResumedRun(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	store := history.NewStore(filepath.Join(home, ".nadleeh", "runs"))
+	record := history.NewRunRecord("/tmp/backup.yml", []byte("name: backup"), map[string]string{"db": "prod", "token": "abcd"})
+	record.Provider = "github"
+	workflowStatus := core.NewRunnableStatus("backup", "workflow")
+	workflowStatus.Finish(os.ErrNotExist)
+	record.Finish(workflowStatus)
+	if err := store.Save(record); err != nil {
+		t.Fatal(err)
+	}
+	resume := record.Id
+
+	wa := &core.WorkflowArgs{Resume: &resume}
+	if _, _, err := loadResumedRun(wa, env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{"token": "abcd"})); err == nil {
+		t.Error("Expected an error for the run without a checkpoint")
+	}
+	if err := store.SaveCheckpoint(run_context.NewCheckpoint(record.Id)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadResumedRun(wa, env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{})); err == nil {
+		t.Error("Expected an error for the masked secret arg which isn't given again")
+	}
+
+	resumed, args, err := loadResumedRun(wa, env.NewReadEnv(env.NewEmptyReadEnv(), map[string]string{"token": "abcd"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resumed.record.Id != record.Id || *wa.File != "/tmp/backup.yml" || *wa.Provider != "github" {
+		t.Errorf("Expected the workflow of the resumed run, got %v %v", wa.File, wa.Provider)
+	}
+	if args.Get("db") != "prod" || args.Get("token") != "abcd" {
+		t.Errorf("Expected the args of the resumed run, got %v", args.GetAll())
+	}
+}
@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"nadleeh/pkg/cron"
+	"nadleeh/pkg/workflow/core"
+	workflow "nadleeh/pkg/workflow/model"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	KindWorkflow = "workflow"
+	KindConfig   = "config"
+)
+
+// Entry is a workflow or a wf config file of the scheduler directory, it's run at the cron schedules of the workflow
+// and when the watched files change
+type Entry struct {
+	// File is the absolute path of the workflow or wf config file
+	File      string
+	Kind      string
+	Schedules []*cron.Schedule
+	Watch     *workflow.WorkflowWatch
+
+	// next is when the entry fires next and schedule is the schedule which fires then
+	next     time.Time
+	schedule *cron.Schedule
+	// running is the number of the running runs
+	running int
+}
+
+// plan sets when the entry fires next after t, next is zero if no schedule fires in the next years
+func (e *Entry) plan(t time.Time) {
+	e.next = time.Time{}
+	e.schedule = nil
+	for _, schedule := range e.Schedules {
+		next := schedule.Next(t)
+		if !next.IsZero() && (e.next.IsZero() || next.Before(e.next)) {
+			e.next = next
+			e.schedule = schedule
+		}
+	}
+}
+
+// watchPaths returns the watched paths, a relative path is relative to the directory of the entry
+func (e *Entry) watchPaths() []string {
+	var paths []string
+	for _, path := range e.Watch.Paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(e.File), path)
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+	return paths
+}
+
+// LoadEntries loads the workflows and wf config files of dir which have on.schedule or on.watch. The workflow of a wf config file
+// is loaded by its provider, a relative workflow path is relative to dir. A file which can't be loaded is logged and
+// skipped, so the other workflows are still scheduled.
+func LoadEntries(dir string) ([]*Entry, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduler dir %s: %w", dir, err)
+	}
+	var entries []*Entry
+	for _, fi := range files {
+		ext := strings.ToLower(filepath.Ext(fi.Name()))
+		if fi.IsDir() || ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		file := filepath.Join(dir, fi.Name())
+		entry, err := loadEntry(dir, file)
+		if err != nil {
+			log.Errorf("failed to load %s, it's not scheduled: %v", file, err)
+			continue
+		}
+		if entry == nil {
+			log.Debugf("%s isn't a workflow or a wf config file", file)
+			continue
+		}
+		if len(entry.Schedules) == 0 && entry.Watch == nil {
+			log.Infof("%s has no on.schedule or on.watch, it's not scheduled", file)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// loadEntry loads the workflow or wf config file, it returns nil if the file is neither of them
+func loadEntry(dir string, file string) (*Entry, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]any
+	if err = yaml.Unmarshal(content, &keys); err != nil {
+		return nil, err
+	}
+	entry := &Entry{File: file}
+	if _, ok := keys["jobs"]; ok {
+		entry.Kind = KindWorkflow
+	} else if _, ok = keys["workflow"]; ok {
+		entry.Kind = KindConfig
+		if file, content, err = loadConfigWorkflow(dir, content); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, nil
+	}
+
+	wf, err := workflow.ParseWorkflowFile(file, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if wf.On != nil {
+		if err = wf.On.Precheck(); err != nil {
+			return nil, err
+		}
+		entry.Watch = wf.On.Watch
+	}
+	if entry.Schedules, err = wf.Schedules(); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// loadConfigWorkflow returns the file and content of the workflow of the wf config file
+func loadConfigWorkflow(dir string, content []byte) (string, []byte, error) {
+	var config workflow.WorkflowConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return "", nil, err
+	}
+	if len(config.Workflow) == 0 {
+		return "", nil, fmt.Errorf("workflow is required")
+	}
+	wa := &core.WorkflowArgs{File: &config.Workflow}
+	yml := config.Workflow
+	if len(config.Provider) > 0 {
+		wa.Provider = &config.Provider
+	} else {
+		if !filepath.IsAbs(yml) {
+			yml = filepath.Join(dir, yml)
+		}
+		// the workflow file loader exits if a local file doesn't exist
+		if _, err := os.Stat(yml); err != nil {
+			return "", nil, err
+		}
+	}
+	reader, err := workflow.LoadWorkflowFile(yml, wa)
+	if err != nil {
+		return "", nil, err
+	}
+	content, err = io.ReadAll(reader)
+	return yml, content, err
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ListEntries writes the entries and when they fire next after now
+func ListEntries(w io.Writer, entries []*Entry, now time.Time) {
+	sorted := slices.Clone(entries)
+	for _, entry := range sorted {
+		entry.plan(now)
+	}
+	// the entries which don't fire by a schedule are listed last
+	slices.SortStableFunc(sorted, func(a, b *Entry) int {
+		if a.next.IsZero() || b.next.IsZero() {
+			return cmp.Compare(boolInt(a.next.IsZero()), boolInt(b.next.IsZero()))
+		}
+		return a.next.Compare(b.next)
+	})
+	for _, entry := range sorted {
+		_, _ = fmt.Fprintf(w, "%s (%s)\n", entry.File, entry.Kind)
+		if len(entry.Schedules) > 0 {
+			var crons []string
+			for _, schedule := range entry.Schedules {
+				crons = append(crons, schedule.String())
+			}
+			next := "never"
+			if !entry.next.IsZero() {
+				next = entry.next.Format(time.DateTime)
+			}
+			_, _ = fmt.Fprintf(w, "  schedule: %s\n  next: %s\n", strings.Join(crons, ", "), next)
+		}
+		if entry.Watch != nil {
+			debounce, _ := entry.Watch.GetDebounce()
+			_, _ = fmt.Fprintf(w, "  watch: %s on %s after %s\n", strings.Join(entry.watchPaths(), ", "),
+				strings.Join(entry.Watch.GetEvents(), ", "), debounce)
+		}
+	}
+}
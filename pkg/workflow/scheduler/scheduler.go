@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nadleeh/internal/argument"
+	"nadleeh/pkg/workflow/history"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HandleScheduler runs the workflows of the scheduler directory at their schedules until ctx is done
+func HandleScheduler(ctx context.Context, args *argument.SchedulerArgs) {
+	entries, err := LoadEntries(args.Dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if args.List {
+		ListEntries(os.Stdout, entries, time.Now())
+		return
+	}
+	if len(entries) == 0 {
+		log.Fatalf("no workflow of %s has on.schedule or on.watch", args.Dir)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	scheduler := NewScheduler(entries, func(ctx context.Context, entry *Entry) *exec.Cmd {
+		return NadleehCommand(ctx, executable, args, entry)
+	})
+	if err = scheduler.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NadleehCommand returns the nadleeh command which runs the entry, the workflow is run by nadleeh run and the wf config
+// file by nadleeh wf. The command is terminated by SIGTERM once ctx is done, so the workflow is cancelled gracefully.
+func NadleehCommand(ctx context.Context, executable string, args *argument.SchedulerArgs, entry *Entry) *exec.Cmd {
+	var cmdArgs []string
+	if entry.Kind == KindConfig {
+		cmdArgs = []string{"wf", entry.File}
+	} else {
+		cmdArgs = []string{"run", "-f", entry.File}
+		if len(args.PrivateFile) > 0 {
+			cmdArgs = append(cmdArgs, "--private", args.PrivateFile)
+		}
+	}
+	if argument.Verbose {
+		cmdArgs = append(cmdArgs, "-v")
+	}
+	cmd := exec.CommandContext(ctx, executable, cmdArgs...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.Dir = args.Dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// Scheduler runs the entries at their schedules and when their watched files change. A scheduled run is skipped if the
+// previous run of the entry is still running, the runs of the watch aren't skipped and may use concurrency to queue.
+type Scheduler struct {
+	entries []*Entry
+	command func(ctx context.Context, entry *Entry) *exec.Cmd
+	now     func() time.Time
+
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler which runs an entry by the command
+func NewScheduler(entries []*Entry, command func(ctx context.Context, entry *Entry) *exec.Cmd) *Scheduler {
+	return &Scheduler{
+		entries: entries,
+		command: command,
+		now:     time.Now,
+	}
+}
+
+// Run runs the entries at their schedules and when their watched files change until ctx is done, then it waits for
+// the running workflows which are cancelled by ctx
+func (s *Scheduler) Run(ctx context.Context) error {
+	now := s.now()
+	for _, entry := range s.entries {
+		entry.plan(now)
+		if len(entry.Schedules) > 0 {
+			s.logNext(entry)
+		}
+	}
+	defer s.wg.Wait()
+	watched, err := s.watch(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		next := s.nextTime()
+		if next.IsZero() && watched == nil {
+			log.Warn("no scheduled workflow will run anymore, the scheduler exits")
+			return nil
+		}
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(s.now()))
+			timerC = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			stopTimer(timer)
+			log.Infof("scheduler stopped: %v, waiting for the running workflows", context.Cause(ctx))
+			return nil
+		case change := <-watched:
+			stopTimer(timer)
+			s.fire(ctx, change.entry, change.trigger)
+			continue
+		case <-timerC:
+		}
+		now = s.now()
+		for _, entry := range s.entries {
+			if entry.next.IsZero() || entry.next.After(now) {
+				continue
+			}
+			s.fire(ctx, entry, &trigger{
+				description: "schedule " + entry.schedule.String(),
+				skipRunning: true,
+			})
+			entry.plan(now)
+			s.logNext(entry)
+		}
+	}
+}
+
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// nextTime returns when the earliest entry fires next
+func (s *Scheduler) nextTime() time.Time {
+	var next time.Time
+	for _, entry := range s.entries {
+		if !entry.next.IsZero() && (next.IsZero() || entry.next.Before(next)) {
+			next = entry.next
+		}
+	}
+	return next
+}
+
+func (s *Scheduler) logNext(entry *Entry) {
+	if entry.next.IsZero() {
+		log.Warnf("%s will not run anymore", entry.File)
+		return
+	}
+	log.Infof("%s runs next at %s (%s)", entry.File, entry.next.Format(time.DateTime), entry.schedule)
+}
+
+// trigger is what fires an entry, it's passed to nadleeh by envs and recorded in the run history
+type trigger struct {
+	description string
+	path        string
+	event       string
+	// skipRunning skips the run if the previous run of the entry is still running
+	skipRunning bool
+}
+
+func (t *trigger) envs() []string {
+	envs := []string{fmt.Sprintf("%s=%s", history.TriggerEnv, t.description)}
+	if len(t.path) > 0 {
+		envs = append(envs, fmt.Sprintf("%s=%s", history.TriggerPathEnv, t.path),
+			fmt.Sprintf("%s=%s", history.TriggerEventEnv, t.event))
+	}
+	return envs
+}
+
+// fire starts the run of the entry by the trigger, the run is logged into the history with the trigger
+func (s *Scheduler) fire(ctx context.Context, entry *Entry, trigger *trigger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if trigger.skipRunning && entry.running > 0 {
+		log.Warnf("%s is skipped by %s, its previous run is still running", entry.File, trigger.description)
+		return
+	}
+	cmd := s.command(ctx, entry)
+	cmd.Env = append(os.Environ(), trigger.envs()...)
+	log.Infof("run %s by %s", entry.File, trigger.description)
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		log.Errorf("failed to run %s: %v", entry.File, err)
+		return
+	}
+	entry.running++
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := cmd.Wait()
+		s.mu.Lock()
+		entry.running--
+		s.mu.Unlock()
+		duration := time.Since(start).Round(time.Millisecond)
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			log.Infof("%s succeeded in %s", entry.File, duration)
+		case errors.As(err, &exitErr):
+			log.Errorf("%s failed with exit code %d in %s", entry.File, exitErr.ExitCode(), duration)
+		default:
+			log.Errorf("%s failed in %s: %v", entry.File, duration, err)
+		}
+	}()
+}
@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nadleeh/pkg/cron"
+	workflow "nadleeh/pkg/workflow/model"
+)
+
+const scheduledWorkflow = `
+name: backup
+on:
+  schedule:
+    - cron: "0 3 * * *"
+jobs:
+  backup:
+    steps:
+      - script: "1"
+`
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadEntries(t *testing.T) {
+	dir := t.TempDir()
+	workflowFile := writeFile(t, dir, "backup.yml", scheduledWorkflow)
+	configFile := writeFile(t, dir, "nightly.yaml", "workflow: workflows/cleanup.yml\nargs:\n  days: \"7\"\n")
+	writeFile(t, dir, "workflows/cleanup.yml", strings.Replace(scheduledWorkflow, "0 3 * * *", "@daily", 1))
+	writeFile(t, dir, "manual.yml", "name: manual\njobs:\n  manual:\n    steps:\n      - script: \"1\"\n")
+	writeFile(t, dir, "broken.yml", strings.Replace(scheduledWorkflow, "0 3 * * *", "0 3 * *", 1))
+	writeFile(t, dir, "missing.yml", "workflow: workflows/missing.yml\n")
+	writeFile(t, dir, "other.yml", "foo: bar\n")
+	watchFile := writeFile(t, dir, "watch.yml", strings.Replace(scheduledWorkflow, "schedule:\n    - cron: \"0 3 * * *\"", "watch:\n    paths: [uploads]", 1))
+	writeFile(t, dir, "readme.txt", scheduledWorkflow)
+
+	entries, err := LoadEntries(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if entries[0].File != workflowFile || entries[0].Kind != KindWorkflow || entries[0].Schedules[0].String() != "0 3 * * *" {
+		t.Errorf("Unexpected workflow entry %+v", entries[0])
+	}
+	if entries[1].File != configFile || entries[1].Kind != KindConfig || entries[1].Schedules[0].String() != "@daily" {
+		t.Errorf("Unexpected config entry %+v", entries[1])
+	}
+	if entries[2].File != watchFile || len(entries[2].Schedules) != 0 || entries[2].Watch.Paths[0] != "uploads" {
+		t.Errorf("Unexpected watch entry %+v", entries[2])
+	}
+
+	t.Run("NotDir", func(t *testing.T) {
+		if _, err := LoadEntries(filepath.Join(dir, "not-exist")); err == nil {
+			t.Error("Expected an error of a missing dir")
+		}
+	})
+}
+
+func TestListEntries(t *testing.T) {
+	daily, _ := cron.Parse("0 3 * * *")
+	hourly, _ := cron.Parse("@hourly")
+	never, _ := cron.Parse("0 0 30 2 *")
+	entries := []*Entry{
+		{File: "/wf/backup.yml", Kind: KindWorkflow, Schedules: []*cron.Schedule{daily, hourly}},
+		{File: "/wf/feb.yml", Kind: KindConfig, Schedules: []*cron.Schedule{never}},
+		{File: "/wf/daily.yml", Kind: KindWorkflow, Schedules: []*cron.Schedule{daily}},
+		{File: "/wf/import.yml", Kind: KindWorkflow, Watch: &workflow.WorkflowWatch{Paths: []string{"uploads/*.csv"}, Debounce: "10s"}},
+	}
+	var buf bytes.Buffer
+	ListEntries(&buf, entries, time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC))
+	expected := `/wf/backup.yml (workflow)
+  schedule: 0 3 * * *, @hourly
+  next: 2024-01-31 11:00:00
+/wf/daily.yml (workflow)
+  schedule: 0 3 * * *
+  next: 2024-02-01 03:00:00
+/wf/feb.yml (config)
+  schedule: 0 0 30 2 *
+  next: never
+/wf/import.yml (workflow)
+  watch: /wf/uploads/*.csv on create, write after 10s
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected list:\n%s", buf.String())
+	}
+}
+
+func TestScheduler_Fire(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	daily, _ := cron.Parse("0 3 * * *")
+	entry := &Entry{File: "backup.yml", Kind: KindWorkflow, Schedules: []*cron.Schedule{daily}}
+	entry.plan(time.Now())
+	scheduler := NewScheduler([]*Entry{entry}, func(ctx context.Context, entry *Entry) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", `echo "$NADLEEH_TRIGGER" >> "$0"; sleep 0.3`, out)
+	})
+
+	scheduled := &trigger{description: "schedule 0 3 * * *", skipRunning: true}
+	scheduler.fire(context.Background(), entry, scheduled)
+	// the previous run is still running
+	scheduler.fire(context.Background(), entry, scheduled)
+	scheduler.wg.Wait()
+	if entry.running != 0 {
+		t.Error("Expected the entry not running after the run")
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "schedule 0 3 * * *\n" {
+		t.Errorf("Expected a single run with the trigger, got %q", string(data))
+	}
+
+	scheduler.fire(context.Background(), entry, scheduled)
+	scheduler.wg.Wait()
+	if data, _ = os.ReadFile(out); strings.Count(string(data), "schedule") != 2 {
+		t.Errorf("Expected the entry runs again, got %q", string(data))
+	}
+
+	t.Run("NotSkipped", func(t *testing.T) {
+		_ = os.Remove(out)
+		watched := &trigger{description: "watch create /uploads/a.csv", path: "/uploads/a.csv", event: "create"}
+		scheduler.fire(context.Background(), entry, watched)
+		scheduler.fire(context.Background(), entry, watched)
+		scheduler.wg.Wait()
+		if data, _ = os.ReadFile(out); strings.Count(string(data), "watch create") != 2 {
+			t.Errorf("Expected the watch runs not skipped, got %q", string(data))
+		}
+	})
+}
+
+func TestScheduler_Run(t *testing.T) {
+	t.Run("Cancelled", func(t *testing.T) {
+		daily, _ := cron.Parse("0 3 * * *")
+		entry := &Entry{File: "backup.yml", Kind: KindWorkflow, Schedules: []*cron.Schedule{daily}}
+		scheduler := NewScheduler([]*Entry{entry}, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if err := scheduler.Run(ctx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if entry.next.IsZero() {
+			t.Error("Expected the entry planned")
+		}
+	})
+
+	t.Run("Fires", func(t *testing.T) {
+		minutely, _ := cron.Parse("* * * * *")
+		entry := &Entry{File: "backup.yml", Kind: KindWorkflow, Schedules: []*cron.Schedule{minutely}}
+		fired := make(chan struct{}, 1)
+		scheduler := NewScheduler([]*Entry{entry}, func(ctx context.Context, entry *Entry) *exec.Cmd {
+			fired <- struct{}{}
+			return exec.CommandContext(ctx, "true")
+		})
+		// start right before the next minute
+		start := time.Now()
+		scheduler.now = func() time.Time {
+			return time.Now().Add(start.Truncate(time.Minute).Add(time.Minute - 50*time.Millisecond).Sub(start))
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			_ = scheduler.Run(ctx)
+			close(done)
+		}()
+		select {
+		case <-fired:
+		case <-time.After(5 * time.Second):
+			t.Error("Expected the entry fired")
+		}
+		cancel()
+		<-done
+	})
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"nadleeh/pkg/fswatch"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchTarget is a watched path of an entry, the files of dir whose name matches the pattern are watched
+type watchTarget struct {
+	entry    *Entry
+	dir      string
+	pattern  string
+	events   []string
+	debounce time.Duration
+}
+
+func (t *watchTarget) matches(event fswatch.Event) bool {
+	if filepath.Dir(event.Path) != t.dir || !slices.Contains(t.events, event.Op) {
+		return false
+	}
+	matched, _ := filepath.Match(t.pattern, filepath.Base(event.Path))
+	return matched
+}
+
+// watchChange is a debounced change of a watched path which fires the entry
+type watchChange struct {
+	entry   *Entry
+	trigger *trigger
+}
+
+// pendingChange is a change which waits for the debounce, gen tells the latest timer of the path
+type pendingChange struct {
+	target *watchTarget
+	event  fswatch.Event
+	gen    int
+}
+
+// watchTargets returns the watched paths of the entries, a path is a directory or a file which may be a glob
+func (s *Scheduler) watchTargets() ([]*watchTarget, error) {
+	var targets []*watchTarget
+	for _, entry := range s.entries {
+		if entry.Watch == nil {
+			continue
+		}
+		debounce, err := entry.Watch.GetDebounce()
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range entry.watchPaths() {
+			target := &watchTarget{entry: entry, dir: path, pattern: "*", events: entry.Watch.GetEvents(), debounce: debounce}
+			if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+				target.dir, target.pattern = filepath.Split(path)
+				target.dir = filepath.Clean(target.dir)
+			}
+			if _, err = filepath.Match(target.pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid watch path %s of %s: %w", path, entry.File, err)
+			}
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+// watch watches the paths of the entries, the changes are sent once the path is quiet for the debounce. It returns a
+// nil channel if no entry has on.watch.
+func (s *Scheduler) watch(ctx context.Context) (<-chan *watchChange, error) {
+	targets, err := s.watchTargets()
+	if err != nil || len(targets) == 0 {
+		return nil, err
+	}
+	watcher, err := fswatch.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		if err = watcher.Add(target.dir); err != nil {
+			_ = watcher.Close()
+			return nil, fmt.Errorf("failed to watch %s of %s: %w", target.dir, target.entry.File, err)
+		}
+		log.Infof("%s watches %s in %s", target.entry.File, target.pattern, target.dir)
+	}
+
+	changes := make(chan *watchChange)
+	go func() {
+		defer watcher.Close()
+		pending := make(map[string]*pendingChange)
+		type expiry struct {
+			key string
+			gen int
+		}
+		expired := make(chan expiry)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-watcher.Errors:
+				log.Error(err)
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				for _, target := range targets {
+					if !target.matches(event) {
+						continue
+					}
+					key := target.entry.File + "\x00" + event.Path
+					change, ok := pending[key]
+					if !ok {
+						// the first event of the changes is reported, e.g. create of a new file which is written then
+						change = &pendingChange{target: target, event: event}
+						pending[key] = change
+					}
+					change.gen++
+					gen := change.gen
+					time.AfterFunc(target.debounce, func() {
+						select {
+						case expired <- expiry{key: key, gen: gen}:
+						case <-ctx.Done():
+						}
+					})
+				}
+			case e := <-expired:
+				change, ok := pending[e.key]
+				if !ok || change.gen != e.gen {
+					continue
+				}
+				delete(pending, e.key)
+				select {
+				case changes <- &watchChange{entry: change.target.entry, trigger: &trigger{
+					description: fmt.Sprintf("watch %s %s", change.event.Op, change.event.Path),
+					path:        change.event.Path,
+					event:       change.event.Op,
+				}}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
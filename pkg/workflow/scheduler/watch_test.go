@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"context"
+	"nadleeh/pkg/workflow/history"
+	workflow "nadleeh/pkg/workflow/model"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Watch(t *testing.T) {
+	dir := t.TempDir()
+	uploads := filepath.Join(dir, "uploads")
+	if err := os.Mkdir(uploads, 0755); err != nil {
+		t.Fatal(err)
+	}
+	entry := &Entry{File: filepath.Join(dir, "import.yml"), Kind: KindWorkflow, Watch: &workflow.WorkflowWatch{
+		Paths:    []string{"uploads/*.csv"},
+		Debounce: "200ms",
+	}}
+	fired := make(chan string, 10)
+	scheduler := NewScheduler([]*Entry{entry}, func(ctx context.Context, entry *Entry) *exec.Cmd {
+		return exec.CommandContext(ctx, "true")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := scheduler.watch(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	go func() {
+		for change := range changes {
+			fired <- strings.Join(change.trigger.envs(), " ")
+		}
+	}()
+
+	file := filepath.Join(uploads, "orders.csv")
+	for i := 0; i < 5; i++ {
+		// the writes within the debounce fire once
+		_ = os.WriteFile(file, []byte(strings.Repeat("a,b\n", i+1)), 0644)
+		time.Sleep(20 * time.Millisecond)
+	}
+	_ = os.WriteFile(filepath.Join(uploads, "readme.txt"), []byte("skipped"), 0644)
+	select {
+	case envs := <-fired:
+		expected := history.TriggerEnv + "=watch create " + file + " " + history.TriggerPathEnv + "=" + file + " " +
+			history.TriggerEventEnv + "=create"
+		if envs != expected {
+			t.Errorf("Expected %s, got %s", expected, envs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the watch fired")
+	}
+	select {
+	case envs := <-fired:
+		t.Errorf("Expected a single fire, got %s", envs)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	t.Run("InvalidPath", func(t *testing.T) {
+		entry := &Entry{File: filepath.Join(dir, "import.yml"), Watch: &workflow.WorkflowWatch{Paths: []string{"missing/*.csv"}}}
+		if _, err := NewScheduler([]*Entry{entry}, nil).watch(ctx); err == nil {
+			t.Error("Expected an error of a missing dir")
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package schema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowSchema is the JSON Schema of the workflow files
+//
+//go:embed workflow.schema.json
+var WorkflowSchema string
+
+var compileSchema = sync.OnceValues(func() (*jsonschema.Schema, error) {
+	return jsonschema.CompileString("workflow.schema.json", WorkflowSchema)
+})
+
+var (
+	yamlErrorPattern  = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+	quotedNamePattern = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+)
+
+// Error is a validation error at the line and column of the file, the column is 0 if it's unknown
+type Error struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	position := fmt.Sprintf("%s:%d", e.File, e.Line)
+	if e.Column > 0 {
+		position = fmt.Sprintf("%s:%d", position, e.Column)
+	}
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("%s: %s", position, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", position, e.Path, e.Message)
+}
+
+// ValidateWorkflow validates the workflow file content against the workflow schema, the errors are sorted by their
+// position. An invalid yaml is reported as an error of its line.
+func ValidateWorkflow(file string, content []byte) ([]*Error, error) {
+	schema, err := compileSchema()
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow schema: %w", err)
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return []*Error{yamlError(file, err)}, nil
+	}
+	if len(doc.Content) == 0 {
+		return []*Error{{File: file, Line: 1, Message: "workflow is empty"}}, nil
+	}
+	root := doc.Content[0]
+	instance, err := toJSON(root)
+	if err != nil {
+		return nil, err
+	}
+	err = schema.Validate(instance)
+	var validationErr *jsonschema.ValidationError
+	if err == nil {
+		return nil, nil
+	} else if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+
+	var errs []*Error
+	seen := make(map[string]bool)
+	for _, leaf := range leafErrors(validationErr) {
+		for _, e := range toErrors(file, root, leaf) {
+			if key := e.Error(); !seen[key] {
+				seen[key] = true
+				errs = append(errs, e)
+			}
+		}
+	}
+	slices.SortStableFunc(errs, func(a, b *Error) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+	return errs, nil
+}
+
+// yamlError returns the error of the invalid yaml, the line is taken from the yaml error message
+func yamlError(file string, err error) *Error {
+	e := &Error{File: file, Line: 1, Message: err.Error()}
+	message := strings.TrimPrefix(err.Error(), "yaml: unmarshal errors:\n  ")
+	if matches := yamlErrorPattern.FindStringSubmatch(message); matches != nil {
+		e.Line, _ = strconv.Atoi(matches[1])
+		e.Message = matches[2]
+	}
+	return e
+}
+
+// leafErrors returns the errors which have no causes, they tell what's wrong
+func leafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
+
+// toErrors converts the schema error to the errors at the yaml positions, an unknown key is reported at the key
+func toErrors(file string, root *yaml.Node, err *jsonschema.ValidationError) []*Error {
+	tokens := pointerTokens(err.InstanceLocation)
+	key, value := lookup(root, tokens)
+	if strings.HasSuffix(err.KeywordLocation, "/additionalProperties") && value.Kind == yaml.MappingNode {
+		var errs []*Error
+		for _, match := range quotedNamePattern.FindAllStringSubmatch(err.Message, -1) {
+			name := strings.ReplaceAll(match[1], `\'`, `'`)
+			node := value
+			for i := 0; i+1 < len(value.Content); i += 2 {
+				if value.Content[i].Value == name {
+					node = value.Content[i]
+					break
+				}
+			}
+			errs = append(errs, &Error{File: file, Line: node.Line, Column: node.Column, Path: formatPath(tokens),
+				Message: fmt.Sprintf("unknown key %s", name)})
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+	}
+	node := value
+	if key != nil && value.Kind != yaml.ScalarNode {
+		node = key
+	}
+	return []*Error{{File: file, Line: node.Line, Column: node.Column, Path: formatPath(tokens), Message: err.Message}}
+}
+
+// pointerTokens splits the json pointer into its unescaped tokens
+func pointerTokens(pointer string) []string {
+	if len(pointer) == 0 || pointer == "/" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// lookup returns the node at the tokens and its key node, the key is nil if the node isn't a value of a map. The
+// deepest found node is returned if a token isn't found.
+func lookup(node *yaml.Node, tokens []string) (key *yaml.Node, value *yaml.Node) {
+	value = resolveAlias(node)
+	for _, token := range tokens {
+		switch value.Kind {
+		case yaml.MappingNode:
+			found := false
+			for i := 0; i+1 < len(value.Content); i += 2 {
+				if value.Content[i].Value == token {
+					key, value = value.Content[i], resolveAlias(value.Content[i+1])
+					found = true
+					break
+				}
+			}
+			if !found {
+				return key, value
+			}
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(value.Content) {
+				return key, value
+			}
+			key, value = nil, resolveAlias(value.Content[index])
+		default:
+			return key, value
+		}
+	}
+	return key, value
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// formatPath formats the tokens like jobs.build.steps[0].run
+func formatPath(tokens []string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		if _, err := strconv.Atoi(token); err == nil {
+			sb.WriteString("[" + token + "]")
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(token)
+	}
+	return sb.String()
+}
+
+// toJSON converts the yaml node to the json value which is validated by the schema
+func toJSON(node *yaml.Node) (any, error) {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yaml.MappingNode:
+		object := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := toJSON(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			object[node.Content[i].Value] = value
+		}
+		return object, nil
+	case yaml.SequenceNode:
+		array := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := toJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!bool":
+			var value bool
+			if err := node.Decode(&value); err != nil {
+				return nil, err
+			}
+			return value, nil
+		case "!!int", "!!float":
+			var value float64
+			if err := node.Decode(&value); err != nil {
+				return nil, err
+			}
+			return json.Number(strconv.FormatFloat(value, 'f', -1, 64)), nil
+		default:
+			return node.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("line %d: unsupported yaml node", node.Line)
+}
@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateWorkflow(t *testing.T) {
+	validate := func(t *testing.T, content string) []string {
+		t.Helper()
+		errs, err := ValidateWorkflow("test.yml", []byte(content))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var messages []string
+		for _, e := range errs {
+			messages = append(messages, e.Error())
+		}
+		return messages
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		messages := validate(t, `
+name: backup
+env:
+  PORT: 8080
+concurrency: backup
+on:
+  schedule:
+    - cron: "0 3 * * *"
+  watch:
+    paths: [uploads]
+    events: [create]
+jobs:
+  backup:
+    timeout-minutes: 1.5
+    strategy:
+      matrix:
+        db: [postgres, mysql]
+    steps:
+      - run: echo
+        continue-on-error: true
+        retry:
+          attempts: 3
+          backoff: exponential
+      - uses: telegram
+        with:
+          key: ${{ secure.decrypt('key') }}
+          channel: 123
+          message: done
+`)
+		if len(messages) > 0 {
+			t.Errorf("Expected no error, got %v", messages)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		messages := validate(t, `name: bad
+working_dir: /tmp
+jobs:
+  build:
+    timeout-minutes: soon
+    steps:
+      - name: a
+        continue_on_error: true
+      - uses: minio
+        with:
+          url: http://minio
+          bucket: backup
+          secret-key: secret
+          acess-key: key
+`)
+		expected := []string{
+			"test.yml:2:1: unknown key working_dir",
+			"test.yml:5:22: jobs.build.timeout-minutes: expected number, but got string",
+			"test.yml:8:9: jobs.build.steps[0]: unknown key continue_on_error",
+			"test.yml:10:9: jobs.build.steps[1].with: missing properties: 'access-key'",
+			"test.yml:14:11: jobs.build.steps[1].with: unknown key acess-key",
+		}
+		if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Unexpected errors:\n%s", strings.Join(messages, "\n"))
+		}
+	})
+
+	t.Run("InvalidYaml", func(t *testing.T) {
+		messages := validate(t, "name: bad\njobs:\n  build: [\n")
+		if len(messages) != 1 || !strings.HasPrefix(messages[0], "test.yml:3: ") {
+			t.Errorf("Expected the yaml error at line 3, got %v", messages)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if messages := validate(t, ""); len(messages) != 1 || messages[0] != "test.yml:1: workflow is empty" {
+			t.Errorf("Expected an empty workflow error, got %v", messages)
+		}
+	})
+}
+
+func TestValidateFiles(t *testing.T) {
+	files, err := filepath.Glob("../../../examples/*.yml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Expected the example workflows, got %v %v", files, err)
+	}
+	var buf bytes.Buffer
+	count, err := ValidateFiles(&buf, files)
+	if err != nil || count != 0 {
+		t.Errorf("Expected the examples valid, got %d errors %v:\n%s", count, err, buf.String())
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.yml")
+	_ = os.WriteFile(invalid, []byte("jobs:\n  build:\n    step: []\n"), 0644)
+	buf.Reset()
+	if count, err = ValidateFiles(&buf, append(files, invalid)); err != nil || count != 1 {
+		t.Errorf("Expected an error, got %d %v", count, err)
+	}
+	if expected := invalid + ":3:5: jobs.build: unknown key step\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
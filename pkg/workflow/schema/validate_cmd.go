@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"nadleeh/internal/argument"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HandleValidate validates the workflow files or prints the workflow schema
+func HandleValidate(args *argument.ValidateArgs) {
+	if args.PrintSchema {
+		_, _ = fmt.Fprint(os.Stdout, WorkflowSchema)
+		return
+	}
+	count, err := ValidateFiles(os.Stdout, args.Files)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if count > 0 {
+		log.Fatalf("%d problems found in the workflow files", count)
+	}
+	log.Infof("the workflow files are valid")
+}
+
+// ValidateFiles writes the validation errors of the workflow files, it returns the number of the errors
+func ValidateFiles(w io.Writer, files []string) (int, error) {
+	count := 0
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return count, err
+		}
+		errs, err := ValidateWorkflow(file, content)
+		if err != nil {
+			return count, err
+		}
+		for _, e := range errs {
+			_, _ = fmt.Fprintln(w, e)
+		}
+		count += len(errs)
+	}
+	return count, nil
+}
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Hub-Signature-256"
+	// TimestampHeader is the unix seconds when the request is signed, the signature of a request with it is the HMAC
+	// of <timestamp>.<body>, so the request can't be replayed
+	TimestampHeader = "X-Nadleeh-Timestamp"
+	signaturePrefix = "sha256="
+	// replayWindow is how far the timestamp of a signed request may be from now
+	replayWindow = 5 * time.Minute
+)
+
+// Verifier verifies the requests by the GitHub style X-Hub-Signature-256 HMAC of the body or the bearer token, a
+// request is verified if either of the configured ones matches. A signature without the timestamp header can be
+// replayed, since it's only the HMAC of the body.
+type Verifier struct {
+	Secret []byte
+	Token  []byte
+
+	mu sync.Mutex
+	// seen is the expiry of the timestamped signatures which are verified, they're rejected until it's over
+	seen map[string]time.Time
+}
+
+// NewVerifier reads the HMAC secret and the bearer token from the files, an empty file name disables it
+func NewVerifier(secretFile string, tokenFile string) (*Verifier, error) {
+	verifier := &Verifier{}
+	var err error
+	if verifier.Secret, err = readSecretFile(secretFile); err != nil {
+		return nil, err
+	}
+	if verifier.Token, err = readSecretFile(tokenFile); err != nil {
+		return nil, err
+	}
+	if len(verifier.Secret) == 0 && len(verifier.Token) == 0 {
+		return nil, fmt.Errorf("secret or token is required to verify the requests")
+	}
+	return verifier, nil
+}
+
+func readSecretFile(file string) ([]byte, error) {
+	if len(file) == 0 {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%s is empty", file)
+	}
+	return []byte(secret), nil
+}
+
+// Verify returns whether the request with the body is signed by the secret or has the bearer token
+func (v *Verifier) Verify(r *http.Request, body []byte) bool {
+	return v.verifySignature(r, body) || v.verifyToken(r)
+}
+
+// VerifyRead verifies the requests of the read-only endpoints by the bearer token, they're verified by the signature
+// of the empty body only if the token isn't configured
+func (v *Verifier) VerifyRead(r *http.Request) bool {
+	if len(v.Token) > 0 {
+		return v.verifyToken(r)
+	}
+	return v.verifySignature(r, nil)
+}
+
+func (v *Verifier) verifySignature(r *http.Request, body []byte) bool {
+	if len(v.Secret) == 0 {
+		return false
+	}
+	signature, ok := strings.CutPrefix(r.Header.Get(SignatureHeader), signaturePrefix)
+	if !ok {
+		return false
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	timestamp := r.Header.Get(TimestampHeader)
+	if len(timestamp) == 0 {
+		return hmac.Equal(actual, Sign(v.Secret, body))
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	signedAt := time.Unix(seconds, 0)
+	now := time.Now()
+	if now.Sub(signedAt).Abs() > replayWindow {
+		return false
+	}
+	if !hmac.Equal(actual, Sign(v.Secret, append([]byte(timestamp+"."), body...))) {
+		return false
+	}
+	return v.markSeen(signature, signedAt.Add(replayWindow), now)
+}
+
+// markSeen records the signature until expiry, it returns false if the signature is already seen
+func (v *Verifier) markSeen(signature string, expiry time.Time, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.seen == nil {
+		v.seen = make(map[string]time.Time)
+	}
+	for seen, seenExpiry := range v.seen {
+		if now.After(seenExpiry) {
+			delete(v.seen, seen)
+		}
+	}
+	if _, ok := v.seen[signature]; ok {
+		return false
+	}
+	v.seen[signature] = expiry
+	return true
+}
+
+func (v *Verifier) verifyToken(r *http.Request) bool {
+	if len(v.Token) == 0 {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), v.Token) == 1
+}
+
+// Sign returns the HMAC SHA256 of the body, the header value is sha256= followed by its hex
+func Sign(secret []byte, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	body := []byte(`{"args":{"version":"1.2.0"}}`)
+	signature := "sha256=" + hex.EncodeToString(Sign([]byte("secret"), body))
+	verifier := &Verifier{Secret: []byte("secret"), Token: []byte("token")}
+	for name, tc := range map[string]struct {
+		header   string
+		value    string
+		expected bool
+	}{
+		"Signature":        {SignatureHeader, signature, true},
+		"WrongSignature":   {SignatureHeader, "sha256=" + hex.EncodeToString(Sign([]byte("other"), body)), false},
+		"InvalidSignature": {SignatureHeader, "sha256=xyz", false},
+		"NoPrefix":         {SignatureHeader, strings.TrimPrefix(signature, "sha256="), false},
+		"Token":            {"Authorization", "Bearer token", true},
+		"WrongToken":       {"Authorization", "Bearer other", false},
+		"BasicAuth":        {"Authorization", "Basic token", false},
+		"None":             {"X-Other", "token", false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/hooks/deploy", nil)
+			r.Header.Set(tc.header, tc.value)
+			if verified := verifier.Verify(r, body); verified != tc.expected {
+				t.Errorf("Expected verified %v, got %v", tc.expected, verified)
+			}
+		})
+	}
+
+	t.Run("Timestamp", func(t *testing.T) {
+		verifier := &Verifier{Secret: []byte("secret")}
+		sign := func(timestamp string, payload []byte) *http.Request {
+			r := httptest.NewRequest("POST", "/hooks/deploy", nil)
+			r.Header.Set(TimestampHeader, timestamp)
+			r.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(Sign([]byte("secret"), payload)))
+			return r
+		}
+		now := strconv.FormatInt(time.Now().Unix(), 10)
+		signed := sign(now, append([]byte(now+"."), body...))
+		if !verifier.Verify(signed, body) {
+			t.Error("Expected the timestamped signature verified")
+		}
+		if verifier.Verify(signed, body) {
+			t.Error("Expected the replayed request rejected")
+		}
+		if verifier.Verify(sign(now, body), body) {
+			t.Error("Expected the signature without the timestamp rejected once the timestamp header is set")
+		}
+		stale := strconv.FormatInt(time.Now().Add(-replayWindow-time.Minute).Unix(), 10)
+		if verifier.Verify(sign(stale, append([]byte(stale+"."), body...)), body) {
+			t.Error("Expected the stale timestamp rejected")
+		}
+		if verifier.Verify(sign("now", append([]byte("now."), body...)), body) {
+			t.Error("Expected the invalid timestamp rejected")
+		}
+	})
+
+	t.Run("TokenOnly", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/hooks/deploy", nil)
+		r.Header.Set(SignatureHeader, signature)
+		if (&Verifier{Token: []byte("token")}).Verify(r, body) {
+			t.Error("Expected the signature not verified without a secret")
+		}
+	})
+}
+
+func TestVerifier_VerifyRead(t *testing.T) {
+	signature := "sha256=" + hex.EncodeToString(Sign([]byte("secret"), nil))
+	for name, tc := range map[string]struct {
+		verifier *Verifier
+		header   string
+		value    string
+		expected bool
+	}{
+		"Token":                 {&Verifier{Secret: []byte("secret"), Token: []byte("token")}, "Authorization", "Bearer token", true},
+		"WrongToken":            {&Verifier{Secret: []byte("secret"), Token: []byte("token")}, "Authorization", "Bearer other", false},
+		"SignatureWithToken":    {&Verifier{Secret: []byte("secret"), Token: []byte("token")}, SignatureHeader, signature, false},
+		"SignatureWithoutToken": {&Verifier{Secret: []byte("secret")}, SignatureHeader, signature, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/runs/1", nil)
+			r.Header.Set(tc.header, tc.value)
+			if verified := tc.verifier.VerifyRead(r); verified != tc.expected {
+				t.Errorf("Expected verified %v, got %v", tc.expected, verified)
+			}
+		})
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	emptyFile := filepath.Join(dir, "empty")
+	_ = os.WriteFile(secretFile, []byte("secret\n"), 0600)
+	_ = os.WriteFile(emptyFile, []byte("\n"), 0600)
+
+	verifier, err := NewVerifier(secretFile, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(verifier.Secret) != "secret" || verifier.Token != nil {
+		t.Errorf("Unexpected verifier %+v", verifier)
+	}
+	if _, err = NewVerifier(emptyFile, ""); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("Expected empty file error, got %v", err)
+	}
+	if _, err = NewVerifier("", ""); err == nil {
+		t.Error("Expected an error without secret and token")
+	}
+}
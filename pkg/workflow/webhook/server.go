@@ -0,0 +1,357 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"nadleeh/internal/argument"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/history"
+	workflow "nadleeh/pkg/workflow/model"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// maxBodySize is the max size of the request body, the body only holds the args
+const maxBodySize = 1 << 20
+
+// launchTTL is how long a launch which exited before nadleeh saved the run is kept, so its failure can be queried
+const launchTTL = time.Hour
+
+var argNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// Hook is a wf config file of the serve directory, it's run by POST /hooks/<name>
+type Hook struct {
+	Name string
+	File string
+}
+
+// HookRequest is the json body of POST /hooks/<name>, the args of the wf config file can't be overridden
+type HookRequest struct {
+	Args map[string]string `json:"args"`
+}
+
+// HookResponse is the json response of POST /hooks/<name>
+type HookResponse struct {
+	Id   string `json:"id"`
+	Hook string `json:"hook"`
+	Url  string `json:"url"`
+}
+
+// LoadHooks loads the wf config files of dir, the hook name is the file name without the extension
+func LoadHooks(dir string) (map[string]*Hook, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read serve dir %s: %w", dir, err)
+	}
+	hooks := make(map[string]*Hook)
+	for _, fi := range files {
+		ext := filepath.Ext(fi.Name())
+		if fi.IsDir() || ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		hook := &Hook{Name: strings.TrimSuffix(fi.Name(), ext), File: filepath.Join(dir, fi.Name())}
+		if isConfig, err := isWorkflowConfig(hook.File); err != nil {
+			log.Errorf("failed to load %s, it's not served: %v", hook.File, err)
+			continue
+		} else if !isConfig {
+			log.Debugf("%s isn't a wf config file", hook.File)
+			continue
+		}
+		if existing, ok := hooks[hook.Name]; ok {
+			log.Errorf("%s is not served, hook %s is %s", hook.File, hook.Name, existing.File)
+			continue
+		}
+		hooks[hook.Name] = hook
+	}
+	return hooks, nil
+}
+
+func isWorkflowConfig(file string) (bool, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+	var config workflow.WorkflowConfig
+	if err = yaml.Unmarshal(content, &config); err != nil {
+		return false, err
+	}
+	return len(config.Workflow) > 0, nil
+}
+
+// launch is a run started by the server, it's tracked until nadleeh saves the run into the history
+type launch struct {
+	hook  *Hook
+	start time.Time
+	end   time.Time
+	done  bool
+	err   error
+}
+
+// Server runs the hooks by nadleeh wf and returns the runs from the history
+type Server struct {
+	hooks    map[string]*Hook
+	verifier *Verifier
+	store    *history.Store
+	command  func(ctx context.Context, hook *Hook, args map[string]string) *exec.Cmd
+	// ctx cancels the running workflows once the server stops
+	ctx context.Context
+
+	mu       sync.Mutex
+	launches map[string]*launch
+	wg       sync.WaitGroup
+}
+
+// NewServer creates the server which runs a hook by the command, the runs are cancelled once ctx is done
+func NewServer(ctx context.Context, hooks map[string]*Hook, verifier *Verifier, store *history.Store,
+	command func(ctx context.Context, hook *Hook, args map[string]string) *exec.Cmd) *Server {
+	return &Server{
+		hooks:    hooks,
+		verifier: verifier,
+		store:    store,
+		command:  command,
+		ctx:      ctx,
+		launches: make(map[string]*launch),
+	}
+}
+
+// Handler returns the http handler of the hooks and runs
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/{name}", s.handleHook)
+	mux.HandleFunc("GET /runs/{id}", s.handleRun)
+	return mux
+}
+
+// Wait waits for the running workflows
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Server) handleHook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("failed to read the body: %v", err))
+		return
+	}
+	if !s.verifier.Verify(r, body) {
+		writeError(w, http.StatusUnauthorized, "invalid signature or token")
+		return
+	}
+	hook, ok := s.hooks[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("hook %s not found", r.PathValue("name")))
+		return
+	}
+	var request HookRequest
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err = json.Unmarshal(body, &request); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body, it must be {\"args\": {...}}: %v", err))
+			return
+		}
+	}
+	for name := range request.Args {
+		if !argNamePattern.MatchString(name) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid arg name %s", name))
+			return
+		}
+	}
+
+	id, err := s.start(hook, request.Args)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	url := "/runs/" + id
+	w.Header().Set("Location", url)
+	writeJSON(w, http.StatusAccepted, &HookResponse{Id: id, Hook: hook.Name, Url: url})
+}
+
+// start runs the hook with the run id which is returned before nadleeh saves the run
+func (s *Server) start(hook *Hook, args map[string]string) (string, error) {
+	start := time.Now()
+	id := history.NewRunId(start)
+	cmd := s.command(s.ctx, hook, args)
+	cmd.Env = append(cmd.Environ(),
+		fmt.Sprintf("%s=webhook %s", history.TriggerEnv, hook.Name),
+		fmt.Sprintf("%s=%s", history.RunIdEnv, id))
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to run hook %s: %w", hook.Name, err)
+	}
+	log.Infof("run %s of hook %s", id, hook.Name)
+
+	s.mu.Lock()
+	s.pruneLaunches(start)
+	s.launches[id] = &launch{hook: hook, start: start}
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			log.Infof("run %s of hook %s succeeded", id, hook.Name)
+		case errors.As(err, &exitErr):
+			log.Errorf("run %s of hook %s failed with exit code %d", id, hook.Name, exitErr.ExitCode())
+		default:
+			log.Errorf("run %s of hook %s failed: %v", id, hook.Name, err)
+		}
+		_, loadErr := s.store.Load(id)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if loadErr == nil {
+			delete(s.launches, id)
+			return
+		}
+		// nadleeh exited before it saved the run, e.g. the workflow can't be loaded
+		s.launches[id].done = true
+		s.launches[id].end = time.Now()
+		s.launches[id].err = err
+	}()
+	return id, nil
+}
+
+// pruneLaunches removes the launches which exited launchTTL before now, it must be called with s.mu held
+func (s *Server) pruneLaunches(now time.Time) {
+	for id, started := range s.launches {
+		if started.done && now.Sub(started.end) > launchTTL {
+			delete(s.launches, id)
+		}
+	}
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if !s.verifier.VerifyRead(r) {
+		writeError(w, http.StatusUnauthorized, "invalid signature or token")
+		return
+	}
+	id := r.PathValue("id")
+	if !history.IsRunId(id) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid run id %s", id))
+		return
+	}
+	if record, err := s.store.Load(id); err == nil {
+		writeJSON(w, http.StatusOK, record)
+		return
+	}
+	s.mu.Lock()
+	var started launch
+	existing, ok := s.launches[id]
+	if ok {
+		started = *existing
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("run %s not found", id))
+		return
+	}
+	record := &history.RunRecord{
+		Id:        id,
+		Workflow:  started.hook.File,
+		StartTime: started.start,
+		Status:    core.NotStart,
+		Trigger:   "webhook " + started.hook.Name,
+	}
+	if started.done {
+		record.Status = core.Fail
+		record.Reason = fmt.Sprintf("nadleeh exited before the run started: %v", started.err)
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("failed to write the response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// NadleehCommand returns the nadleeh wf command which runs the hook with the args, the command is terminated by
+// SIGTERM once ctx is done, so the workflow is cancelled gracefully. The args are passed by the env instead of the
+// command line, which any user can read, since they may be secrets.
+func NadleehCommand(ctx context.Context, executable string, dir string, hook *Hook, args map[string]string) *exec.Cmd {
+	cmdArgs := []string{"wf", hook.File}
+	if argument.Verbose {
+		cmdArgs = append(cmdArgs, "-v")
+	}
+	cmd := exec.CommandContext(ctx, executable, cmdArgs...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), argument.EncodeArgsEnv(args))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// HandleServe serves the hooks of the serve directory until ctx is done, then it waits for the running workflows
+func HandleServe(ctx context.Context, args *argument.ServeArgs) {
+	hooks, err := LoadHooks(args.Dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(hooks) == 0 {
+		log.Fatalf("no wf config file found in %s", args.Dir)
+	}
+	verifier, err := NewVerifier(args.SecretFile, args.TokenFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := history.NewDefaultStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := NewServer(ctx, hooks, verifier, store, func(ctx context.Context, hook *Hook, hookArgs map[string]string) *exec.Cmd {
+		return NadleehCommand(ctx, executable, args.Dir, hook, hookArgs)
+	})
+	httpServer := &http.Server{
+		Addr:              args.Addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		log.Infof("server stopped: %v, waiting for the running workflows", context.Cause(ctx))
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+	for _, name := range slices.Sorted(maps.Keys(hooks)) {
+		log.Infof("serve POST /hooks/%s by %s", name, hooks[name].File)
+	}
+	log.Infof("listening on %s", args.Addr)
+	if err = httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	server.Wait()
+}
@@ -0,0 +1,192 @@
+package webhook
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"nadleeh/internal/argument"
+	"nadleeh/pkg/workflow/core"
+	"nadleeh/pkg/workflow/history"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadHooks(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "deploy.yml"), []byte("workflow: deploy-workflow.yml\n"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "deploy.yaml"), []byte("workflow: other.yml\n"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "deploy-workflow.yml"), []byte("name: deploy\njobs: {}\n"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "broken.yml"), []byte("workflow: [\n"), 0644)
+
+	hooks, err := LoadHooks(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(hooks) != 1 || hooks["deploy"] == nil || hooks["deploy"].File != filepath.Join(dir, "deploy.yaml") {
+		t.Errorf("Expected the deploy hook only, got %v", hooks)
+	}
+}
+
+type testServer struct {
+	*Server
+	store *history.Store
+	out   string
+}
+
+func newTestServer(t *testing.T, script string) *testServer {
+	t.Helper()
+	dir := t.TempDir()
+	ts := &testServer{store: history.NewStore(filepath.Join(dir, "runs")), out: filepath.Join(dir, "out")}
+	hooks := map[string]*Hook{"deploy": {Name: "deploy", File: filepath.Join(dir, "deploy.yml")}}
+	verifier := &Verifier{Secret: []byte("secret"), Token: []byte("token")}
+	ts.Server = NewServer(context.Background(), hooks, verifier, ts.store,
+		func(ctx context.Context, hook *Hook, args map[string]string) *exec.Cmd {
+			return exec.CommandContext(ctx, "sh", "-c", script, ts.out, args["version"])
+		})
+	return ts
+}
+
+func (ts *testServer) do(t *testing.T, method string, url string, body string, header string, value string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, url, strings.NewReader(body))
+	if len(header) > 0 {
+		r.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	ts.Handler().ServeHTTP(w, r)
+	return w
+}
+
+func TestServer_Hook(t *testing.T) {
+	ts := newTestServer(t, `echo "$1 $NADLEEH_TRIGGER $NADLEEH_RUN_ID" > "$0"`)
+	body := `{"args": {"version": "1.2.0"}}`
+	signature := "sha256=" + hex.EncodeToString(Sign([]byte("secret"), []byte(body)))
+
+	w := ts.do(t, "POST", "/hooks/deploy", body, SignatureHeader, signature)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d %s", w.Code, w.Body.String())
+	}
+	var response HookResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !history.IsRunId(response.Id) || response.Hook != "deploy" || response.Url != "/runs/"+response.Id {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	ts.Wait()
+	data, _ := os.ReadFile(ts.out)
+	if string(data) != "1.2.0 webhook deploy "+response.Id+"\n" {
+		t.Errorf("Unexpected args and env of the run: %q", string(data))
+	}
+
+	for name, tc := range map[string]struct {
+		url      string
+		body     string
+		token    string
+		expected int
+	}{
+		"Unauthorized":   {"/hooks/deploy", body, "other", http.StatusUnauthorized},
+		"NotFound":       {"/hooks/backup", body, "token", http.StatusNotFound},
+		"InvalidBody":    {"/hooks/deploy", `{"args": {"version": 1}}`, "token", http.StatusBadRequest},
+		"InvalidArgName": {"/hooks/deploy", `{"args": {"a-b": "1"}}`, "token", http.StatusBadRequest},
+		"EmptyBody":      {"/hooks/deploy", "", "token", http.StatusAccepted},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if w = ts.do(t, "POST", tc.url, tc.body, "Authorization", "Bearer "+tc.token); w.Code != tc.expected {
+				t.Errorf("Expected %d, got %d %s", tc.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+	ts.Wait()
+}
+
+func TestServer_Run(t *testing.T) {
+	ts := newTestServer(t, `sleep 0.3; exit 3`)
+	getRun := func(t *testing.T, id string) *history.RunRecord {
+		t.Helper()
+		w := ts.do(t, "GET", "/runs/"+id, "", "Authorization", "Bearer token")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d %s", w.Code, w.Body.String())
+		}
+		var record history.RunRecord
+		if err := json.Unmarshal(w.Body.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		return &record
+	}
+
+	w := ts.do(t, "POST", "/hooks/deploy", "", "Authorization", "Bearer token")
+	var response HookResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if record := getRun(t, response.Id); record.Status != core.NotStart || record.Trigger != "webhook deploy" {
+		t.Errorf("Expected a run not started yet, got %+v", record)
+	}
+	ts.Wait()
+	if record := getRun(t, response.Id); record.Status != core.Fail || !strings.Contains(record.Reason, "exit status 3") {
+		t.Errorf("Expected a failed run which wasn't saved, got %+v", record)
+	}
+	ts.mu.Lock()
+	ts.launches[response.Id].end = time.Now().Add(-launchTTL - time.Minute)
+	ts.mu.Unlock()
+	ts.do(t, "POST", "/hooks/deploy", "", "Authorization", "Bearer token")
+	ts.Wait()
+	if w = ts.do(t, "GET", "/runs/"+response.Id, "", "Authorization", "Bearer token"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the expired launch removed, got %d %s", w.Code, w.Body.String())
+	}
+
+	saved := history.NewRunRecord("deploy.yml", nil, nil)
+	saved.Finish(core.NewRunnableStatus("deploy", "workflow"))
+	if err := ts.store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	if record := getRun(t, saved.Id); record.Id != saved.Id || record.Workflow != "deploy.yml" {
+		t.Errorf("Expected the saved run, got %+v", record)
+	}
+
+	for name, tc := range map[string]struct {
+		id       string
+		token    string
+		expected int
+	}{
+		"Unauthorized": {saved.Id, "other", http.StatusUnauthorized},
+		"InvalidId":    {"2024", "token", http.StatusBadRequest},
+		"NotFound":     {history.NewRunId(time.Now()), "token", http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if w = ts.do(t, "GET", "/runs/"+tc.id, "", "Authorization", "Bearer "+tc.token); w.Code != tc.expected {
+				t.Errorf("Expected %d, got %d %s", tc.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNadleehCommand(t *testing.T) {
+	cmd := NadleehCommand(context.Background(), "nadleeh", t.TempDir(), &Hook{Name: "deploy", File: "deploy.yml"},
+		map[string]string{"db": "hunter2", "host": "example.com"})
+	if strings.Contains(strings.Join(cmd.Args, " "), "hunter2") {
+		t.Errorf("Expected the args not in the command line, got %v", cmd.Args)
+	}
+	var argsEnv string
+	for _, entry := range cmd.Env {
+		if value, ok := strings.CutPrefix(entry, argument.ArgsEnv+"="); ok {
+			argsEnv = value
+		}
+	}
+	t.Setenv(argument.ArgsEnv, argsEnv)
+	args, err := argument.CreateArgsEnv([]string{"host=localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Get("db") != "hunter2" || args.Get("host") != "localhost" {
+		t.Errorf("Expected the args of the env overridden by the command line, got %v", args.GetAll())
+	}
+	if _, ok := os.LookupEnv(argument.ArgsEnv); ok {
+		t.Errorf("Expected %s to be unset for the steps", argument.ArgsEnv)
+	}
+}